@@ -0,0 +1,148 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Fixed-point amount of money, held as a whole number of cents to match the DECIMAL(18,2) columns.
+// Used for prices, balances and amounts, so sums and order book keys don't drift the way float64 does.
+// It is a plain int64 underneath, so +, -, == and < work as normal.
+type Money int64
+
+const Scale = 100 // cents per unit
+const decimalPlaces = 2
+
+func FromCents(cents int64) Money {
+	return Money(cents)
+}
+
+// Rounds to the nearest cent. Only use this at the edges, where a float is all we are given.
+func FromFloat(value float64) Money {
+	return Money(math.Round(value * Scale))
+}
+
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / Scale
+}
+
+// The amount for a number of shares at this price.
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/Scale, cents%Scale)
+}
+
+// Parses a decimal string such as "12", "12.5" or "-0.05" without going through a float.
+// Anything past the second decimal place is rounded half away from zero.
+func Parse(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	digits := value
+	if negative || strings.HasPrefix(value, "+") {
+		digits = value[1:] // one sign at most
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if (whole == "" && fraction == "") || !onlyDigits(whole) || !onlyDigits(fraction) {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money value %q: %v", value, err)
+	}
+	if units > (math.MaxInt64-Scale)/Scale {
+		return 0, fmt.Errorf("money value %q is out of range", value)
+	}
+	roundUp := false
+	if len(fraction) > decimalPlaces {
+		roundUp = fraction[decimalPlaces] >= '5'
+		fraction = fraction[:decimalPlaces]
+	}
+	fraction += strings.Repeat("0", decimalPlaces-len(fraction))
+	cents, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money value %q: %v", value, err)
+	}
+	total := units*Scale + cents
+	if roundUp {
+		total++
+	}
+	if negative {
+		total = -total
+	}
+	return Money(total), nil
+}
+
+// Written as a JSON number, so clients that send and read plain numbers keep working.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Accepts a JSON number or a quoted decimal string. Exponent form, e.g. 1e3, is refused like any other value Parse can't read.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" || value == "" {
+		*m = 0
+		return nil
+	}
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Stored as a decimal string, so postgres never sees a float.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case float64:
+		*m = FromFloat(v)
+	case int64:
+		*m = Money(v * Scale)
+	case []byte:
+		return m.UnmarshalJSON(v)
+	case string:
+		return m.UnmarshalJSON([]byte(v))
+	default:
+		return fmt.Errorf("cannot scan %T into money", value)
+	}
+	return nil
+}
+
+func (Money) GormDataType() string {
+	return "decimal(18,2)"
+}
+
+func onlyDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
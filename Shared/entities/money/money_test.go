@@ -0,0 +1,79 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	cases := map[string]string{
+		"12":     "12.00",
+		"12.5":   "12.50",
+		"0.1":    "0.10",
+		"-0.05":  "-0.05",
+		"1.005":  "1.01",
+		"19.994": "19.99",
+		".5":     "0.50",
+	}
+	for input, want := range cases {
+		m, err := Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", input, err)
+		}
+		if m.String() != want {
+			t.Errorf("Parse(%q) = %s, wanted %s", input, m, want)
+		}
+	}
+	for _, input := range []string{"abc", "1.-5", "1.+5", "--5", "+-3", "-+3", "-", "1.2.3", "1 .5", "1_0", "1e3",
+		"99999999999999999999", "92233720368547758.07"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("expected an error parsing %q", input)
+		}
+	}
+}
+
+func TestSumsDoNotDrift(t *testing.T) {
+	var total Money
+	for i := 0; i < 1000; i++ {
+		total += FromFloat(0.1)
+	}
+	if total != FromCents(10000) {
+		t.Errorf("expected 100.00, got %s", total)
+	}
+	if FromCents(1999).Mul(3) != FromCents(5997) {
+		t.Errorf("expected 59.97, got %s", FromCents(1999).Mul(3))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var body struct {
+		Price  Money `json:"price"`
+		Amount Money `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 7.35, "amount": "1350"}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Price != FromCents(735) || body.Amount != FromCents(135000) {
+		t.Errorf("unexpected values %s %s", body.Price, body.Amount)
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"price":7.35,"amount":1350.00}` {
+		t.Errorf("unexpected json %s", out)
+	}
+}
+
+func TestUnmarshalRefusesWhatItCantHold(t *testing.T) {
+	for _, input := range []string{`NaN`, `"NaN"`, `"Inf"`, `"-Inf"`, `1e30`, `99999999999999999999`, `"1_0"`, `0x10`} {
+		var m Money
+		if err := json.Unmarshal([]byte(input), &m); err == nil {
+			t.Errorf("expected an error unmarshalling %s, got %s", input, m)
+		}
+	}
+	var m Money
+	if err := json.Unmarshal([]byte(`92233720368547757.07`), &m); err != nil || m != FromCents(9223372036854775707) {
+		t.Errorf("expected the largest value in range to unmarshal, got %s, %v", m, err)
+	}
+}
@@ -2,13 +2,26 @@ package order
 
 import (
 	"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/stock"
 	"encoding/json"
+	"fmt"
+	"time"
 )
 
 const (
-	OrderTypeMarket = "MARKET"
-	OrderTypeLimit  = "LIMIT"
+	OrderTypeMarket    = "MARKET"
+	OrderTypeLimit     = "LIMIT"
+	OrderTypeStop      = "STOP"       // becomes a MARKET order once the last trade price reaches the stop price
+	OrderTypeStopLimit = "STOP_LIMIT" // becomes a LIMIT order at Price once the last trade price reaches the stop price
+)
+
+// Time in force. How long an order may stay in the book.
+const (
+	TimeInForceGTC = "GTC" // good till cancelled. The default
+	TimeInForceIOC = "IOC" // immediate or cancel. Whatever doesn't fill straight away is cancelled
+	TimeInForceFOK = "FOK" // fill or kill. Fills completely straight away, or is cancelled without trading
+	TimeInForceGTD = "GTD" // good till date. Cancelled once ExpiresAt passes
 )
 
 // // Set here so we can make sure we keep the price as something usable as both math and a key.
@@ -29,27 +42,47 @@ type StockOrderInterface interface {
 	GetIsBuy() bool
 	SetIsBuy(isBuy bool)
 	GetOrderType() string
+	IsStop() bool
+	GetStopPrice() money.Money
+	GetQueuedAt() time.Time
+	Trigger(triggeredAt time.Time)
+	Requeue(queuedAt time.Time)
+	GetTimePriority() time.Time
 	GetQuantity() int
 	SetQuantity(quantity int)
-	GetPrice() float64
-	SetPrice(price float64)
+	GetPrice() money.Money
+	SetPrice(price money.Money)
 	GetParentStockOrderID() string
 	SetParentStockOrderID(parentStockOrderID string)
 	GetUserID() string
 	SetUserID(userID string)
-	CreateChildOrder(parent StockOrderInterface, partner StockOrderInterface) StockOrderInterface
+	GetTimeInForce() string
+	GetExpiresAt() time.Time
+	IsExpired(now time.Time) bool
+	IsIceberg() bool
+	GetDisplayQuantity() int
+	GetVisibleQuantity() int
+	SetVisibleQuantity(visibleQuantity int)
+	RefreshDisplay(queuedAt time.Time)
+	CreateChildOrder(parent StockOrderInterface, quantity int) StockOrderInterface
 	ToParams() NewStockOrderParams
 	entity.EntityInterface
 }
 
 type StockOrder struct {
-	StockID            string  `json:"stock_id" gorm:"not null"` // use this or Stock
-	ParentStockOrderID string  `json:"ParentStockOrderID"`
-	IsBuy              bool    `json:"is_buy" gorm:"not null"`
-	OrderType          string  `json:"order_type" gorm:"not null"` // MARKET or LIMIT. This can't be changed later.
-	Quantity           int     `json:"quantity" gorm:"not null"`
-	Price              float64 `json:"price" gorm:"not null"`
-	UserID             string  `json:"user_id" gorm:"not null"`
+	StockID            string      `json:"stock_id" gorm:"not null"` // use this or Stock
+	ParentStockOrderID string      `json:"ParentStockOrderID"`
+	IsBuy              bool        `json:"is_buy" gorm:"not null"`
+	OrderType          string      `json:"order_type" gorm:"not null"` // MARKET, LIMIT, STOP or STOP_LIMIT. Only changes when a stop order is triggered.
+	Quantity           int         `json:"quantity" gorm:"not null"`
+	Price              money.Money `json:"price" gorm:"not null"`
+	UserID             string      `json:"user_id" gorm:"not null"`
+	TimeInForce        string      `json:"time_in_force"`    // GTC, IOC, FOK or GTD. Empty is GTC. This can't be changed later.
+	ExpiresAt          time.Time   `json:"expires_at"`       // Only used by GTD orders
+	StopPrice          money.Money `json:"stop_price"`       // Only used by stop orders
+	QueuedAt           time.Time   `json:"queued_at"`        // When the order last joined the book, if that was after it was placed: a stop triggering or an amend re-queueing it
+	DisplayQuantity    int         `json:"display_quantity"` // Iceberg orders only. How much of the order is shown in the book at a time. 0 shows all of it
+	VisibleQuantity    int         `json:"visible_quantity"` // Iceberg orders only. What is left of the slice currently shown
 	// Price     `gorm:"embedded"`
 	// If you need to access a property, please use the Get and Set functions, not the property itself. It is only exposed in case you need to interact with it when altering internal functions.
 	// Internal Functions should not be interacted with directly. if you need to change functionality, set a new function to the existing internal function.
@@ -83,6 +116,45 @@ func (so *StockOrder) GetOrderType() string {
 	return so.OrderType
 }
 
+// True until a stop order has been triggered.
+func (so *StockOrder) IsStop() bool {
+	return so.OrderType == OrderTypeStop || so.OrderType == OrderTypeStopLimit
+}
+
+func (so *StockOrder) GetStopPrice() money.Money {
+	return so.StopPrice
+}
+
+func (so *StockOrder) GetQueuedAt() time.Time {
+	return so.QueuedAt
+}
+
+// Turns a stop order into the market or limit order it releases into the book.
+func (so *StockOrder) Trigger(triggeredAt time.Time) {
+	switch so.OrderType {
+	case OrderTypeStop:
+		so.OrderType = OrderTypeMarket
+	case OrderTypeStopLimit:
+		so.OrderType = OrderTypeLimit
+	default:
+		return
+	}
+	so.QueuedAt = triggeredAt
+}
+
+// Puts the order to the back of the queue at its price, as of queuedAt. Used when an amend costs the order its time priority.
+func (so *StockOrder) Requeue(queuedAt time.Time) {
+	so.QueuedAt = queuedAt
+}
+
+// When the order joined the book. For a triggered stop or a re-queued order that is when it last joined, not when it was placed.
+func (so *StockOrder) GetTimePriority() time.Time {
+	if !so.QueuedAt.IsZero() {
+		return so.QueuedAt
+	}
+	return so.GetDateCreated()
+}
+
 func (so *StockOrder) GetQuantity() int {
 	//return so.GetQuantityInternal()
 	return so.Quantity
@@ -93,12 +165,12 @@ func (so *StockOrder) SetQuantity(quantity int) {
 	so.Quantity = quantity
 }
 
-func (so *StockOrder) GetPrice() float64 {
+func (so *StockOrder) GetPrice() money.Money {
 	//return so.GetPriceInternal()
 	return so.Price
 }
 
-func (so *StockOrder) SetPrice(price float64) {
+func (so *StockOrder) SetPrice(price money.Money) {
 	//so.SetPriceInternal(price)
 	so.Price = price
 }
@@ -131,19 +203,81 @@ func (so *StockOrder) SetUserID(userID string) {
 	so.UserID = userID
 }
 
-func (so *StockOrder) CreateChildOrder(parent StockOrderInterface, partner StockOrderInterface) StockOrderInterface {
+func (so *StockOrder) GetTimeInForce() string {
+	if so.TimeInForce == "" {
+		return TimeInForceGTC
+	}
+	return so.TimeInForce
+}
+
+func (so *StockOrder) GetExpiresAt() time.Time {
+	return so.ExpiresAt
+}
+
+func (so *StockOrder) IsExpired(now time.Time) bool {
+	return so.GetTimeInForce() == TimeInForceGTD && !now.Before(so.GetExpiresAt())
+}
+
+// An iceberg order only shows DisplayQuantity shares at a time, the rest is a hidden reserve.
+func (so *StockOrder) IsIceberg() bool {
+	return so.DisplayQuantity > 0
+}
+
+func (so *StockOrder) GetDisplayQuantity() int {
+	return so.DisplayQuantity
+}
+
+// How much of the order can trade at its place in the queue. All of it, unless it is an iceberg.
+func (so *StockOrder) GetVisibleQuantity() int {
+	if !so.IsIceberg() {
+		return so.Quantity
+	}
+	return min(so.VisibleQuantity, so.Quantity)
+}
+
+func (so *StockOrder) SetVisibleQuantity(visibleQuantity int) {
+	so.VisibleQuantity = visibleQuantity
+}
+
+// Shows the next slice from the reserve. The new slice loses its time priority, like any order joining the book at queuedAt.
+func (so *StockOrder) RefreshDisplay(queuedAt time.Time) {
+	so.VisibleQuantity = min(so.DisplayQuantity, so.Quantity)
+	so.QueuedAt = queuedAt
+}
+
+// Checks the time in force is one we know, and that a GTD order expires in the future.
+func ValidateTimeInForce(stockOrder StockOrderInterface, now time.Time) error {
+	switch stockOrder.GetTimeInForce() {
+	case TimeInForceGTC, TimeInForceIOC, TimeInForceFOK:
+		return nil
+	case TimeInForceGTD:
+		if !stockOrder.GetExpiresAt().After(now) {
+			return fmt.Errorf("GTD order needs an expires_at in the future")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown time in force %s", stockOrder.GetTimeInForce())
+}
+
+// The part of parent filled by one trade. Only split off when the trade leaves some of the parent open, so a child always means a partial fill.
+func (so *StockOrder) CreateChildOrder(parent StockOrderInterface, quantity int) StockOrderInterface {
 	// Create a new Stock Order
 	return New(NewStockOrderParams{
 		NewEntityParams: entity.NewEntityParams{
-			ID: parent.GetId(),
+			ID:          parent.GetId(),
+			DateCreated: parent.GetDateCreated(), // keeps the parent's time priority
 		},
 		StockID:            parent.GetStockID(),
-		Quantity:           partner.GetQuantity(),
+		Quantity:           quantity,
 		Price:              parent.GetPrice(),
 		OrderType:          parent.GetOrderType(),
 		IsBuy:              parent.GetIsBuy(),
 		ParentStockOrderID: parent.GetId(),
 		UserID:             parent.GetUserID(),
+		TimeInForce:        parent.GetTimeInForce(),
+		ExpiresAt:          parent.GetExpiresAt(),
+		StopPrice:          parent.GetStopPrice(),
+		QueuedAt:           parent.GetQueuedAt(),
 	})
 
 }
@@ -153,11 +287,17 @@ type NewStockOrderParams struct {
 	Stock                  stock.StockInterface // use this or StockID
 	StockID                string               `json:"stock_id"`
 	IsBuy                  bool                 `json:"is_buy"`
-	OrderType              string               `json:"order_type"` // MARKET or LIMIT. This can't be changed later.
+	OrderType              string               `json:"order_type"` // MARKET, LIMIT, STOP or STOP_LIMIT
 	Quantity               int                  `json:"quantity"`
-	Price                  float64              `json:"price"`
+	Price                  money.Money          `json:"price"`
 	ParentStockOrderID     string               `json:"ParentStockOrderID"`
 	UserID                 string               `json:"user_id"`
+	TimeInForce            string               `json:"time_in_force"` // leave empty for GTC
+	ExpiresAt              time.Time            `json:"expires_at"`    // GTD only
+	StopPrice              money.Money          `json:"stop_price"`    // STOP and STOP_LIMIT only
+	QueuedAt               time.Time            `json:"queued_at"`
+	DisplayQuantity        int                  `json:"display_quantity"` // LIMIT only. Leave 0 for a normal order
+	VisibleQuantity        int                  `json:"visible_quantity"` // leave 0 to show the first slice
 }
 
 func New(params NewStockOrderParams) *StockOrder {
@@ -178,6 +318,15 @@ func New(params NewStockOrderParams) *StockOrder {
 		Price:              params.Price,
 		ParentStockOrderID: params.ParentStockOrderID,
 		UserID:             params.UserID,
+		TimeInForce:        params.TimeInForce,
+		ExpiresAt:          params.ExpiresAt,
+		StopPrice:          params.StopPrice,
+		QueuedAt:           params.QueuedAt,
+		DisplayQuantity:    params.DisplayQuantity,
+		VisibleQuantity:    params.VisibleQuantity,
+	}
+	if so.IsIceberg() && so.VisibleQuantity == 0 {
+		so.VisibleQuantity = min(so.DisplayQuantity, so.Quantity)
 	}
 	return so
 }
@@ -211,7 +360,44 @@ func (so *StockOrder) ToParams() NewStockOrderParams {
 		Quantity:        so.GetQuantity(),
 		Price:           so.GetPrice(),
 		UserID:          so.GetUserID(),
+		TimeInForce:     so.GetTimeInForce(),
+		ExpiresAt:       so.GetExpiresAt(),
+		StopPrice:       so.GetStopPrice(),
+		QueuedAt:        so.GetQueuedAt(),
+		DisplayQuantity: so.GetDisplayQuantity(),
+		VisibleQuantity: so.VisibleQuantity,
+	}
+}
+
+// Only limit orders can be icebergs, and the display quantity has to hide something.
+func ValidateDisplayQuantity(stockOrder StockOrderInterface) error {
+	if stockOrder.GetDisplayQuantity() == 0 {
+		return nil
+	}
+	if stockOrder.GetDisplayQuantity() < 0 {
+		return fmt.Errorf("display_quantity can't be negative")
+	}
+	if stockOrder.GetOrderType() != OrderTypeLimit {
+		return fmt.Errorf("only LIMIT orders can have a display_quantity")
+	}
+	if stockOrder.GetDisplayQuantity() >= stockOrder.GetQuantity() {
+		return fmt.Errorf("display_quantity must be less than quantity")
+	}
+	return nil
+}
+
+// Stop orders need a stop price, and a stop limit order needs its limit price too.
+func ValidateStopOrder(stockOrder StockOrderInterface) error {
+	if !stockOrder.IsStop() {
+		return nil
+	}
+	if stockOrder.GetStopPrice() <= 0 {
+		return fmt.Errorf("%s order needs a stop_price", stockOrder.GetOrderType())
+	}
+	if stockOrder.GetOrderType() == OrderTypeStopLimit && stockOrder.GetPrice() <= 0 {
+		return fmt.Errorf("STOP_LIMIT order needs a price")
 	}
+	return nil
 }
 
 func (so *StockOrder) ToJSON() ([]byte, error) {
@@ -220,22 +406,38 @@ func (so *StockOrder) ToJSON() ([]byte, error) {
 
 type FakeStockOrder struct {
 	entity.FakeEntity
-	StockID   string  `json:"stockID"`
-	IsBuy     bool    `json:"isBuy"`
-	OrderType string  `json:"orderType"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	StockID     string      `json:"stockID"`
+	IsBuy       bool        `json:"isBuy"`
+	OrderType   string      `json:"orderType"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
+	TimeInForce string      `json:"timeInForce"`
+	ExpiresAt   time.Time   `json:"expiresAt"`
 }
 
 func (fso *FakeStockOrder) GetStockID() string            { return fso.StockID }
 func (fso *FakeStockOrder) GetIsBuy() bool                { return fso.IsBuy }
 func (fso *FakeStockOrder) GetOrderType() string          { return fso.OrderType }
 func (fso *FakeStockOrder) GetQuantity() int              { return fso.Quantity }
-func (fso *FakeStockOrder) GetPrice() float64             { return float64(fso.Price) }
+func (fso *FakeStockOrder) GetPrice() money.Money         { return fso.Price }
 func (fso *FakeStockOrder) SetStockID(stockID string)     { fso.StockID = stockID }
 func (fso *FakeStockOrder) SetIsBuy(isBuy bool)           { fso.IsBuy = isBuy }
 func (fso *FakeStockOrder) SetOrderType(orderType string) { fso.OrderType = orderType }
 func (fso *FakeStockOrder) SetQuantity(quantity int)      { fso.Quantity = quantity }
-func (fso *FakeStockOrder) SetPrice(price float64)        { fso.Price = float64(price) }
+func (fso *FakeStockOrder) SetPrice(price money.Money)    { fso.Price = price }
+func (fso *FakeStockOrder) GetTimeInForce() string        { return fso.TimeInForce }
+func (fso *FakeStockOrder) GetExpiresAt() time.Time       { return fso.ExpiresAt }
+func (fso *FakeStockOrder) IsExpired(now time.Time) bool  { return false }
+func (fso *FakeStockOrder) IsStop() bool                  { return false }
+func (fso *FakeStockOrder) GetStopPrice() money.Money     { return 0 }
+func (fso *FakeStockOrder) GetQueuedAt() time.Time        { return time.Time{} }
+func (fso *FakeStockOrder) Trigger(triggeredAt time.Time) {}
+func (fso *FakeStockOrder) Requeue(queuedAt time.Time)    {}
+func (fso *FakeStockOrder) GetTimePriority() time.Time    { return fso.DateCreated }
+func (fso *FakeStockOrder) IsIceberg() bool               { return false }
+func (fso *FakeStockOrder) GetDisplayQuantity() int       { return 0 }
+func (fso *FakeStockOrder) GetVisibleQuantity() int       { return fso.Quantity }
+func (fso *FakeStockOrder) SetVisibleQuantity(int)        {}
+func (fso *FakeStockOrder) RefreshDisplay(time.Time)      {}
 func (fso *FakeStockOrder) ToParams() NewStockOrderParams { return NewStockOrderParams{} }
 func (fso *FakeStockOrder) ToJSON() ([]byte, error)       { return []byte{}, nil }
@@ -0,0 +1,169 @@
+package stock
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/money"
+	"encoding/json"
+	"time"
+)
+
+// Candle intervals, and how long each one covers.
+const (
+	CandleInterval1m = "1m"
+	CandleInterval5m = "5m"
+	CandleInterval1h = "1h"
+	CandleInterval1d = "1d"
+)
+
+var CandleIntervals = map[string]time.Duration{
+	CandleInterval1m: time.Minute,
+	CandleInterval5m: 5 * time.Minute,
+	CandleInterval1h: time.Hour,
+	CandleInterval1d: 24 * time.Hour,
+}
+
+// Start of the candle a trade at this time falls in. Days start at midnight UTC.
+func CandleStart(interval string, timestamp time.Time) time.Time {
+	return timestamp.UTC().Truncate(CandleIntervals[interval])
+}
+
+type CandleInterface interface {
+	GetStockID() string
+	GetInterval() string
+	GetStartTime() time.Time
+	GetOpen() money.Money
+	GetHigh() money.Money
+	GetLow() money.Money
+	GetClose() money.Money
+	GetVolume() int
+	AddTrade(price money.Money, quantity int)
+	ToParams() NewCandleParams
+	entity.EntityInterface
+}
+
+// OHLCV of one stock over one interval.
+type Candle struct {
+	StockID       string      `json:"stock_id" gorm:"not null;index:idx_candle,unique"`
+	Interval      string      `json:"interval" gorm:"column:candle_interval;not null;index:idx_candle,unique"` // interval is a postgres keyword
+	StartTime     time.Time   `json:"start_time" gorm:"not null;index:idx_candle,unique"`
+	Open          money.Money `json:"open"`
+	High          money.Money `json:"high"`
+	Low           money.Money `json:"low"`
+	Close         money.Money `json:"close"`
+	Volume        int         `json:"volume"`
+	entity.Entity `json:"Entity" gorm:"embedded"`
+}
+
+func (c *Candle) GetStockID() string {
+	return c.StockID
+}
+
+func (c *Candle) GetInterval() string {
+	return c.Interval
+}
+
+func (c *Candle) GetStartTime() time.Time {
+	return c.StartTime
+}
+
+func (c *Candle) GetOpen() money.Money {
+	return c.Open
+}
+
+func (c *Candle) GetHigh() money.Money {
+	return c.High
+}
+
+func (c *Candle) GetLow() money.Money {
+	return c.Low
+}
+
+func (c *Candle) GetClose() money.Money {
+	return c.Close
+}
+
+func (c *Candle) GetVolume() int {
+	return c.Volume
+}
+
+// Trades must be added in the order they happened, the last one added is the close.
+func (c *Candle) AddTrade(price money.Money, quantity int) {
+	if c.Volume == 0 {
+		c.Open = price
+		c.High = price
+		c.Low = price
+	}
+	if price > c.High {
+		c.High = price
+	}
+	if price < c.Low {
+		c.Low = price
+	}
+	c.Close = price
+	c.Volume += quantity
+}
+
+type NewCandleParams struct {
+	entity.NewEntityParams `json:"Entity"`
+	StockID                string      `json:"stock_id"`
+	Interval               string      `json:"interval"`
+	StartTime              time.Time   `json:"start_time"`
+	Open                   money.Money `json:"open"`
+	High                   money.Money `json:"high"`
+	Low                    money.Money `json:"low"`
+	Close                  money.Money `json:"close"`
+	Volume                 int         `json:"volume"`
+}
+
+func NewCandle(params NewCandleParams) *Candle {
+	e := entity.NewEntity(params.NewEntityParams)
+	return &Candle{
+		StockID:   params.StockID,
+		Interval:  params.Interval,
+		StartTime: params.StartTime,
+		Open:      params.Open,
+		High:      params.High,
+		Low:       params.Low,
+		Close:     params.Close,
+		Volume:    params.Volume,
+		Entity:    *e,
+	}
+}
+
+func ParseCandle(jsonBytes []byte) (*Candle, error) {
+	var c NewCandleParams
+	if err := json.Unmarshal(jsonBytes, &c); err != nil {
+		return nil, err
+	}
+	return NewCandle(c), nil
+}
+
+func ParseCandleList(jsonBytes []byte) (*[]*Candle, error) {
+	var cl []NewCandleParams
+	if err := json.Unmarshal(jsonBytes, &cl); err != nil {
+		return nil, err
+	}
+	candles := make([]*Candle, len(cl))
+	for i, c := range cl {
+		candles[i] = NewCandle(c)
+	}
+	return &candles, nil
+}
+
+func (c *Candle) ToParams() NewCandleParams {
+	return NewCandleParams{
+		NewEntityParams: c.EntityToParams(),
+		StockID:         c.GetStockID(),
+		Interval:        c.GetInterval(),
+		StartTime:       c.GetStartTime(),
+		Open:            c.GetOpen(),
+		High:            c.GetHigh(),
+		Low:             c.GetLow(),
+		Close:           c.GetClose(),
+		Volume:          c.GetVolume(),
+	}
+}
+
+func (c *Candle) ToJSON() ([]byte, error) {
+	return json.Marshal(c.ToParams())
+}
@@ -0,0 +1,32 @@
+package stock
+
+import (
+	"Shared/entities/money"
+	"testing"
+	"time"
+)
+
+func TestCandleAddTrade(t *testing.T) {
+	candle := NewCandle(NewCandleParams{Interval: CandleInterval5m})
+	for _, price := range []int64{1000, 1200, 900, 1100} {
+		candle.AddTrade(money.FromCents(price), 10)
+	}
+	if candle.GetOpen() != 1000 || candle.GetHigh() != 1200 || candle.GetLow() != 900 || candle.GetClose() != 1100 || candle.GetVolume() != 40 {
+		t.Errorf("unexpected candle %+v", candle.ToParams())
+	}
+}
+
+func TestCandleStart(t *testing.T) {
+	timestamp := time.Date(2024, 3, 5, 14, 37, 12, 0, time.UTC)
+	want := map[string]time.Time{
+		CandleInterval1m: time.Date(2024, 3, 5, 14, 37, 0, 0, time.UTC),
+		CandleInterval5m: time.Date(2024, 3, 5, 14, 35, 0, 0, time.UTC),
+		CandleInterval1h: time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC),
+		CandleInterval1d: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+	}
+	for interval, start := range want {
+		if got := CandleStart(interval, timestamp); !got.Equal(start) {
+			t.Errorf("%s: got %v, wanted %v", interval, got, start)
+		}
+	}
+}
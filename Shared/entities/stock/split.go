@@ -0,0 +1,44 @@
+package stock
+
+import (
+	"Shared/entities/money"
+	"fmt"
+)
+
+// NewShares for every OldShares held. A reverse split has fewer new shares than old.
+type Split struct {
+	NewShares int `json:"new_shares"`
+	OldShares int `json:"old_shares"`
+}
+
+func (s Split) Validate() error {
+	if s.NewShares <= 0 || s.OldShares <= 0 || s.NewShares == s.OldShares {
+		return fmt.Errorf("a split needs a positive, different number of new and old shares, got %d for %d", s.NewShares, s.OldShares)
+	}
+	return nil
+}
+
+// Whether a holding can end up with part of a share.
+func (s Split) LeavesFractions() bool {
+	return s.NewShares%s.OldShares != 0
+}
+
+// The whole new shares a quantity of old shares becomes, and what is left over, in OldShares'ths of a new share.
+func (s Split) Shares(quantity int) (int, int) {
+	return quantity * s.NewShares / s.OldShares, quantity * s.NewShares % s.OldShares
+}
+
+// What a price per old share becomes per new share, to the nearest cent.
+func (s Split) Price(price money.Money) money.Money {
+	return money.FromCents(divRound(price.Cents()*int64(s.OldShares), int64(s.NewShares)))
+}
+
+// Pays for the part of a new share Shares left over, at a price per old share.
+func (s Split) CashInLieu(leftover int, price money.Money) money.Money {
+	return money.FromCents(divRound(int64(leftover)*price.Cents(), int64(s.NewShares)))
+}
+
+// Rounds half up. Only for amounts that aren't negative.
+func divRound(a int64, b int64) int64 {
+	return (2*a + b) / (2 * b)
+}
@@ -3,17 +3,48 @@ package stock
 import (
 	"Shared/entities/entity"
 	"encoding/json"
+	"fmt"
+)
+
+// How the orders resting at one price are filled by an incoming order.
+const (
+	AllocationFIFO            = "FIFO"               // oldest first
+	AllocationProRata         = "PRO_RATA"           // in proportion to each order's size
+	AllocationProRataTopOrder = "PRO_RATA_TOP_ORDER" // the oldest order fills first, the rest is shared pro-rata
+)
+
+// An empty policy is FIFO.
+func ValidateAllocationPolicy(policy string) error {
+	switch policy {
+	case "", AllocationFIFO, AllocationProRata, AllocationProRataTopOrder:
+		return nil
+	}
+	return fmt.Errorf("unknown allocation policy %s", policy)
+}
+
+// Where a stock is in its life. Only an active stock takes new orders.
+const (
+	StatusActive    = "ACTIVE"
+	StatusSuspended = "SUSPENDED" // halted with its resting orders kept, until it is reinstated
+	StatusDelisted  = "DELISTED"  // its orders were cancelled and it has no matching engine. For good
 )
 
 type StockInterface interface {
 	GetName() string
 	SetName(name string)
+	GetAllocationPolicy() string
+	SetAllocationPolicy(policy string)
+	GetStatus() string
+	SetStatus(status string)
+	IsActive() bool
 	ToParams() NewStockParams
 	entity.EntityInterface
 }
 
 type Stock struct {
-	Name string `json:"stock_name" gorm:"not null"`
+	Name             string `json:"stock_name" gorm:"not null"`
+	AllocationPolicy string `json:"allocation_policy" gorm:"not null;default:FIFO"`
+	Status           string `json:"status" gorm:"not null;default:ACTIVE"`
 	// If you need to access a property, please use the Get and Set functions, not the property itself. It is only exposed in case you need to interact with it when altering internal functions.
 	// Internal Functions should not be interacted with directly. if you need to change functionality, set a new function to the existing internal function.
 	// Instead, interact with the functions through the Stock Interface.
@@ -32,16 +63,46 @@ func (s *Stock) SetName(name string) {
 	s.Name = name
 }
 
+func (s *Stock) GetAllocationPolicy() string {
+	if s.AllocationPolicy == "" {
+		return AllocationFIFO
+	}
+	return s.AllocationPolicy
+}
+
+func (s *Stock) SetAllocationPolicy(policy string) {
+	s.AllocationPolicy = policy
+}
+
+func (s *Stock) GetStatus() string {
+	if s.Status == "" {
+		return StatusActive
+	}
+	return s.Status
+}
+
+func (s *Stock) SetStatus(status string) {
+	s.Status = status
+}
+
+func (s *Stock) IsActive() bool {
+	return s.GetStatus() == StatusActive
+}
+
 type NewStockParams struct {
 	entity.NewEntityParams `json:"Entity"`
 	Name                   string `json:"stock_name"`
+	AllocationPolicy       string `json:"allocation_policy"` // leave empty for FIFO
+	Status                 string `json:"status"`            // leave empty for ACTIVE
 }
 
 func New(params NewStockParams) *Stock {
 	e := entity.NewEntity(params.NewEntityParams)
 	s := &Stock{
-		Name:   params.Name,
-		Entity: *e,
+		Name:             params.Name,
+		AllocationPolicy: params.AllocationPolicy,
+		Status:           params.Status,
+		Entity:           *e,
 	}
 	return s
 }
@@ -68,8 +129,10 @@ func ParseList(jsonBytes []byte) (*[]*Stock, error) {
 
 func (s *Stock) ToParams() NewStockParams {
 	return NewStockParams{
-		NewEntityParams: s.EntityToParams(),
-		Name:            s.GetName(),
+		NewEntityParams:  s.EntityToParams(),
+		Name:             s.GetName(),
+		AllocationPolicy: s.GetAllocationPolicy(),
+		Status:           s.GetStatus(),
 	}
 }
 
@@ -83,7 +146,12 @@ type FakeStock struct {
 	Name string `json:"name"`
 }
 
-func (fs *FakeStock) GetName() string          { return fs.Name }
-func (fs *FakeStock) SetName(name string)      { fs.Name = name }
-func (fs *FakeStock) ToParams() NewStockParams { return NewStockParams{} }
-func (fs *FakeStock) ToJSON() ([]byte, error)  { return []byte{}, nil }
+func (fs *FakeStock) GetName() string                   { return fs.Name }
+func (fs *FakeStock) SetName(name string)               { fs.Name = name }
+func (fs *FakeStock) GetAllocationPolicy() string       { return AllocationFIFO }
+func (fs *FakeStock) SetAllocationPolicy(policy string) {}
+func (fs *FakeStock) GetStatus() string                 { return StatusActive }
+func (fs *FakeStock) SetStatus(status string)           {}
+func (fs *FakeStock) IsActive() bool                    { return true }
+func (fs *FakeStock) ToParams() NewStockParams          { return NewStockParams{} }
+func (fs *FakeStock) ToJSON() ([]byte, error)           { return []byte{}, nil }
@@ -0,0 +1,54 @@
+package stock
+
+import (
+	"Shared/entities/money"
+	"testing"
+)
+
+func TestStockStatus(t *testing.T) {
+	listed := New(NewStockParams{Name: "Apple"})
+	if listed.GetStatus() != StatusActive || !listed.IsActive() {
+		t.Errorf("a new stock should be active, got %s", listed.GetStatus())
+	}
+	listed.SetStatus(StatusDelisted)
+	jsonBytes, err := listed.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(jsonBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.GetStatus() != StatusDelisted || parsed.IsActive() {
+		t.Errorf("got %s, wanted %s", parsed.GetStatus(), StatusDelisted)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	threeForTwo := Split{NewShares: 3, OldShares: 2}
+	if err := threeForTwo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Split{NewShares: 2, OldShares: 2}).Validate(); err == nil {
+		t.Error("a split changing nothing should be refused")
+	}
+	// 5 old shares are 7.5 new ones
+	whole, leftover := threeForTwo.Shares(5)
+	if whole != 7 || leftover != 1 {
+		t.Errorf("got %d and %d left over, wanted 7 and 1", whole, leftover)
+	}
+	// half a new share is a third of an old one
+	if cash := threeForTwo.CashInLieu(leftover, money.FromCents(3000)); cash != money.FromCents(1000) {
+		t.Errorf("got %s cash, wanted 10.00", cash.String())
+	}
+	if price := threeForTwo.Price(money.FromCents(1001)); price != money.FromCents(667) {
+		t.Errorf("got %s, wanted 6.67", price.String())
+	}
+	oneForTen := Split{NewShares: 1, OldShares: 10}
+	if price := oneForTen.Price(money.FromCents(123)); price != money.FromCents(1230) {
+		t.Errorf("got %s, wanted 12.30", price.String())
+	}
+	if whole, leftover := oneForTen.Shares(25); whole != 2 || leftover != 5 {
+		t.Errorf("got %d and %d left over, wanted 2 and 5", whole, leftover)
+	}
+}
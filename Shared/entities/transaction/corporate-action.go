@@ -0,0 +1,207 @@
+package transaction
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/money"
+	"Shared/entities/stock"
+	"encoding/json"
+	"time"
+)
+
+// Kinds of corporate action
+const (
+	CorporateActionSplit    = "SPLIT" // reverse splits too
+	CorporateActionDividend = "DIVIDEND"
+)
+
+// Where a corporate action is. A dividend is pending until its record date, then paid. A split is pending until its holdings and books are rescaled.
+const (
+	CorporateActionPending   = "PENDING"
+	CorporateActionCompleted = "COMPLETED"
+)
+
+type CorporateActionInterface interface {
+	GetStockID() string
+	SetStockID(stockID string)
+	GetActionType() string
+	SetActionType(actionType string)
+	GetSplit() stock.Split
+	SetSplit(split stock.Split)
+	GetPrice() money.Money
+	SetPrice(price money.Money)
+	GetCashPaid() money.Money
+	SetCashPaid(cashPaid money.Money)
+	GetHolders() int
+	SetHolders(holders int)
+	GetRecordDate() time.Time
+	SetRecordDate(recordDate time.Time)
+	GetStatus() string
+	SetStatus(status string)
+	GetTimestamp() time.Time
+	SetTimestamp(timestamp time.Time)
+	ToParams() NewCorporateActionParams
+	entity.EntityInterface
+}
+
+// Audit record of something done to every holder of a stock at once.
+type CorporateAction struct {
+	StockID       string      `json:"stock_id" gorm:"not null;index"`
+	ActionType    string      `json:"action_type" gorm:"not null"`
+	NewShares     int         `json:"new_shares"` // splits only
+	OldShares     int         `json:"old_shares"`
+	Price         money.Money `json:"price"`     // per share. What a split paid parts of an old share out at, or the dividend
+	CashPaid      money.Money `json:"cash_paid"` // to every holder together
+	Holders       int         `json:"holders"`
+	RecordDate    time.Time   `json:"record_date"` // dividends only, whoever holds the stock then is paid
+	Status        string      `json:"status"`
+	Timestamp     time.Time   `json:"time_stamp"`
+	entity.Entity `json:"Entity" gorm:"embedded"`
+}
+
+func (ca *CorporateAction) GetStockID() string {
+	return ca.StockID
+}
+
+func (ca *CorporateAction) SetStockID(stockID string) {
+	ca.StockID = stockID
+}
+
+func (ca *CorporateAction) GetActionType() string {
+	return ca.ActionType
+}
+
+func (ca *CorporateAction) SetActionType(actionType string) {
+	ca.ActionType = actionType
+}
+
+func (ca *CorporateAction) GetSplit() stock.Split {
+	return stock.Split{NewShares: ca.NewShares, OldShares: ca.OldShares}
+}
+
+func (ca *CorporateAction) SetSplit(split stock.Split) {
+	ca.NewShares = split.NewShares
+	ca.OldShares = split.OldShares
+}
+
+func (ca *CorporateAction) GetPrice() money.Money {
+	return ca.Price
+}
+
+func (ca *CorporateAction) SetPrice(price money.Money) {
+	ca.Price = price
+}
+
+func (ca *CorporateAction) GetCashPaid() money.Money {
+	return ca.CashPaid
+}
+
+func (ca *CorporateAction) SetCashPaid(cashPaid money.Money) {
+	ca.CashPaid = cashPaid
+}
+
+func (ca *CorporateAction) GetHolders() int {
+	return ca.Holders
+}
+
+func (ca *CorporateAction) SetHolders(holders int) {
+	ca.Holders = holders
+}
+
+func (ca *CorporateAction) GetRecordDate() time.Time {
+	return ca.RecordDate
+}
+
+func (ca *CorporateAction) SetRecordDate(recordDate time.Time) {
+	ca.RecordDate = recordDate
+}
+
+// Empty is completed, splits recorded before they had a status have none.
+func (ca *CorporateAction) GetStatus() string {
+	if ca.Status == "" {
+		return CorporateActionCompleted
+	}
+	return ca.Status
+}
+
+func (ca *CorporateAction) SetStatus(status string) {
+	ca.Status = status
+}
+
+func (ca *CorporateAction) GetTimestamp() time.Time {
+	return ca.Timestamp
+}
+
+func (ca *CorporateAction) SetTimestamp(timestamp time.Time) {
+	ca.Timestamp = timestamp
+}
+
+type NewCorporateActionParams struct {
+	entity.NewEntityParams `json:"Entity"`
+	StockID                string      `json:"stock_id"`
+	ActionType             string      `json:"action_type"`
+	NewShares              int         `json:"new_shares"`
+	OldShares              int         `json:"old_shares"`
+	Price                  money.Money `json:"price"`
+	CashPaid               money.Money `json:"cash_paid"`
+	Holders                int         `json:"holders"`
+	RecordDate             time.Time   `json:"record_date"`
+	Status                 string      `json:"status"`
+	Timestamp              time.Time   `json:"time_stamp"`
+}
+
+func NewCorporateAction(params NewCorporateActionParams) *CorporateAction {
+	e := entity.NewEntity(params.NewEntityParams)
+	return &CorporateAction{
+		Entity:     *e,
+		StockID:    params.StockID,
+		ActionType: params.ActionType,
+		NewShares:  params.NewShares,
+		OldShares:  params.OldShares,
+		Price:      params.Price,
+		CashPaid:   params.CashPaid,
+		Holders:    params.Holders,
+		RecordDate: params.RecordDate,
+		Status:     params.Status,
+		Timestamp:  params.Timestamp,
+	}
+}
+
+func ParseCorporateAction(jsonBytes []byte) (*CorporateAction, error) {
+	var params NewCorporateActionParams
+	if err := json.Unmarshal(jsonBytes, &params); err != nil {
+		return nil, err
+	}
+	return NewCorporateAction(params), nil
+}
+
+func ParseCorporateActionList(jsonBytes []byte) (*[]*CorporateAction, error) {
+	var params []NewCorporateActionParams
+	if err := json.Unmarshal(jsonBytes, &params); err != nil {
+		return nil, err
+	}
+	list := make([]*CorporateAction, len(params))
+	for i, p := range params {
+		list[i] = NewCorporateAction(p)
+	}
+	return &list, nil
+}
+
+func (ca *CorporateAction) ToParams() NewCorporateActionParams {
+	return NewCorporateActionParams{
+		NewEntityParams: ca.EntityToParams(),
+		StockID:         ca.GetStockID(),
+		ActionType:      ca.GetActionType(),
+		NewShares:       ca.NewShares,
+		OldShares:       ca.OldShares,
+		Price:           ca.GetPrice(),
+		CashPaid:        ca.GetCashPaid(),
+		Holders:         ca.GetHolders(),
+		RecordDate:      ca.GetRecordDate(),
+		Status:          ca.GetStatus(),
+		Timestamp:       ca.GetTimestamp(),
+	}
+}
+
+func (ca *CorporateAction) ToJSON() ([]byte, error) {
+	return json.Marshal(ca.ToParams())
+}
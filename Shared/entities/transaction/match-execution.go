@@ -0,0 +1,134 @@
+package transaction
+
+import (
+	"Shared/entities/entity"
+	"encoding/json"
+)
+
+// Where the execution of a match is. A match stays running if the executor stops part way through it.
+const (
+	MatchExecutionRunning   = "RUNNING"
+	MatchExecutionCompleted = "COMPLETED"
+)
+
+type MatchExecutionInterface interface {
+	GetMatchID() string
+	SetMatchID(matchID string)
+	GetStockID() string
+	SetStockID(stockID string)
+	GetStatus() string
+	SetStatus(status string)
+	GetIsBuyFailure() bool
+	SetIsBuyFailure(isBuyFailure bool)
+	GetIsSellFailure() bool
+	SetIsSellFailure(isSellFailure bool)
+	ToParams() NewMatchExecutionParams
+	entity.EntityInterface
+}
+
+// The order executor's record of a match the matching engine sent, and what it answered once it was done.
+// A match sent again gets the same answer instead of trading twice, even after the executor restarts.
+type MatchExecution struct {
+	MatchID       string `json:"match_id" gorm:"not null;uniqueIndex"`
+	StockID       string `json:"stock_id"`
+	Status        string `json:"status" gorm:"not null"`
+	IsBuyFailure  bool   `json:"is_buy_failure"`
+	IsSellFailure bool   `json:"is_sell_failure"`
+	entity.Entity `json:"Entity" gorm:"embedded"`
+}
+
+func (me *MatchExecution) GetMatchID() string {
+	return me.MatchID
+}
+
+func (me *MatchExecution) SetMatchID(matchID string) {
+	me.MatchID = matchID
+}
+
+func (me *MatchExecution) GetStockID() string {
+	return me.StockID
+}
+
+func (me *MatchExecution) SetStockID(stockID string) {
+	me.StockID = stockID
+}
+
+func (me *MatchExecution) GetStatus() string {
+	return me.Status
+}
+
+func (me *MatchExecution) SetStatus(status string) {
+	me.Status = status
+}
+
+func (me *MatchExecution) GetIsBuyFailure() bool {
+	return me.IsBuyFailure
+}
+
+func (me *MatchExecution) SetIsBuyFailure(isBuyFailure bool) {
+	me.IsBuyFailure = isBuyFailure
+}
+
+func (me *MatchExecution) GetIsSellFailure() bool {
+	return me.IsSellFailure
+}
+
+func (me *MatchExecution) SetIsSellFailure(isSellFailure bool) {
+	me.IsSellFailure = isSellFailure
+}
+
+type NewMatchExecutionParams struct {
+	entity.NewEntityParams `json:"Entity"`
+	MatchID                string `json:"match_id"`
+	StockID                string `json:"stock_id"`
+	Status                 string `json:"status"`
+	IsBuyFailure           bool   `json:"is_buy_failure"`
+	IsSellFailure          bool   `json:"is_sell_failure"`
+}
+
+func NewMatchExecution(params NewMatchExecutionParams) *MatchExecution {
+	e := entity.NewEntity(params.NewEntityParams)
+	return &MatchExecution{
+		Entity:        *e,
+		MatchID:       params.MatchID,
+		StockID:       params.StockID,
+		Status:        params.Status,
+		IsBuyFailure:  params.IsBuyFailure,
+		IsSellFailure: params.IsSellFailure,
+	}
+}
+
+func ParseMatchExecution(jsonBytes []byte) (*MatchExecution, error) {
+	var params NewMatchExecutionParams
+	if err := json.Unmarshal(jsonBytes, &params); err != nil {
+		return nil, err
+	}
+	return NewMatchExecution(params), nil
+}
+
+func ParseMatchExecutionList(jsonBytes []byte) (*[]*MatchExecution, error) {
+	var params []NewMatchExecutionParams
+	if err := json.Unmarshal(jsonBytes, &params); err != nil {
+		return nil, err
+	}
+	list := make([]*MatchExecution, len(params))
+	for i, p := range params {
+		list[i] = NewMatchExecution(p)
+	}
+	return &list, nil
+}
+
+func (me *MatchExecution) ToParams() NewMatchExecutionParams {
+	return NewMatchExecutionParams{
+		NewEntityParams: me.EntityToParams(),
+		MatchID:         me.GetMatchID(),
+		StockID:         me.GetStockID(),
+		Status:          me.GetStatus(),
+		IsBuyFailure:    me.GetIsBuyFailure(),
+		IsSellFailure:   me.GetIsSellFailure(),
+	}
+}
+
+func (me *MatchExecution) ToJSON() ([]byte, error) {
+	return json.Marshal(me.ToParams())
+}
@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/order"
 	"Shared/entities/stock"
 	"encoding/json"
@@ -20,8 +21,8 @@ type StockTransactionInterface interface {
 	GetIsBuy() bool
 	SetIsBuy(isBuy bool)
 	GetOrderType() string
-	GetStockPrice() float64
-	SetStockPrice(stockPrice float64)
+	GetStockPrice() money.Money
+	SetStockPrice(stockPrice money.Money)
 	GetQuantity() int
 	SetQuantity(quantity int)
 	GetTimestamp() time.Time
@@ -29,22 +30,28 @@ type StockTransactionInterface interface {
 	SetStockTXID()
 	GetUserID() string
 	SetUserID(userID string)
+	GetTimeInForce() string
+	GetExpiresAt() time.Time
+	GetDisplayQuantity() int
 	ToParams() NewStockTransactionParams
 	entity.EntityInterface
 }
 
 type StockTransaction struct {
-	StockTXID                string    `json:"stock_tx_id" gorm:"-"` // Stock Transaction ID
-	StockID                  string    `json:"stock_id" gorm:"not null"`
-	ParentStockTransactionID string    `json:"parent_stock_tx_id"`
-	WalletTransactionID      string    `json:"wallet_tx_id"`
-	OrderStatus              string    `json:"order_status" gorm:"not null"`
-	IsBuy                    bool      `json:"is_buy" gorm:"not null"`
-	OrderType                string    `json:"order_type" gorm:"not null"`
-	StockPrice               float64   `json:"stock_price" gorm:"not null"`
-	Quantity                 int       `json:"quantity" gorm:"not null"`
-	Timestamp                time.Time `json:"time_stamp"`
-	UserID                   string    `json:"user_id" gorm:"not null"`
+	StockTXID                string      `json:"stock_tx_id" gorm:"-"` // Stock Transaction ID
+	StockID                  string      `json:"stock_id" gorm:"not null"`
+	ParentStockTransactionID string      `json:"parent_stock_tx_id"`
+	WalletTransactionID      string      `json:"wallet_tx_id"`
+	OrderStatus              string      `json:"order_status" gorm:"not null"`
+	IsBuy                    bool        `json:"is_buy" gorm:"not null"`
+	OrderType                string      `json:"order_type" gorm:"not null"`
+	StockPrice               money.Money `json:"stock_price" gorm:"not null"`
+	Quantity                 int         `json:"quantity" gorm:"not null"`
+	Timestamp                time.Time   `json:"time_stamp"`
+	UserID                   string      `json:"user_id" gorm:"not null"`
+	TimeInForce              string      `json:"time_in_force"`
+	ExpiresAt                time.Time   `json:"expires_at"`
+	DisplayQuantity          int         `json:"display_quantity"` // iceberg orders only, Quantity is always the full order
 	// Internal Functions (commented out)
 	// GetStockIDInternal                  func() string                         `gorm:"-"`
 	// SetStockIDInternal                  func(stockID string)                  `gorm:"-"`
@@ -119,12 +126,12 @@ func (st *StockTransaction) GetOrderType() string {
 	return st.OrderType
 }
 
-func (st *StockTransaction) GetStockPrice() float64 {
+func (st *StockTransaction) GetStockPrice() money.Money {
 	// return st.GetStockPriceInternal()
 	return st.StockPrice
 }
 
-func (st *StockTransaction) SetStockPrice(stockPrice float64) {
+func (st *StockTransaction) SetStockPrice(stockPrice money.Money) {
 	// st.SetStockPriceInternal(stockPrice)
 	st.StockPrice = stockPrice
 }
@@ -159,18 +166,33 @@ func (st *StockTransaction) SetUserID(userID string) {
 	st.UserID = userID
 }
 
+func (st *StockTransaction) GetTimeInForce() string {
+	return st.TimeInForce
+}
+
+func (st *StockTransaction) GetExpiresAt() time.Time {
+	return st.ExpiresAt
+}
+
+func (st *StockTransaction) GetDisplayQuantity() int {
+	return st.DisplayQuantity
+}
+
 type NewStockTransactionParams struct {
 	entity.NewEntityParams   `json:"entity"`
-	StockID                  string    `json:"stock_id"`
-	ParentStockTransactionID string    `json:"parent_stock_tx_id"`
-	WalletTransactionID      string    `json:"wallet_tx_id"`
-	OrderStatus              string    `json:"order_status"`
-	IsBuy                    bool      `json:"is_buy"`
-	OrderType                string    `json:"order_type"`
-	StockPrice               float64   `json:"stock_price"`
-	Quantity                 int       `json:"quantity"`
-	TimeStamp                time.Time `json:"time_stamp"`
-	UserID                   string    `json:"user_id"`
+	StockID                  string      `json:"stock_id"`
+	ParentStockTransactionID string      `json:"parent_stock_tx_id"`
+	WalletTransactionID      string      `json:"wallet_tx_id"`
+	OrderStatus              string      `json:"order_status"`
+	IsBuy                    bool        `json:"is_buy"`
+	OrderType                string      `json:"order_type"`
+	StockPrice               money.Money `json:"stock_price"`
+	Quantity                 int         `json:"quantity"`
+	TimeStamp                time.Time   `json:"time_stamp"`
+	UserID                   string      `json:"user_id"`
+	TimeInForce              string      `json:"time_in_force"`
+	ExpiresAt                time.Time   `json:"expires_at"`
+	DisplayQuantity          int         `json:"display_quantity"`
 
 	WalletTransaction WalletTransactionInterface // use this or WalletTransactionID or ParentStockTransaction
 	//use one of the following
@@ -189,9 +211,12 @@ func NewStockTransaction(params NewStockTransactionParams) *StockTransaction {
 	var walletTransactionID string
 	var isBuy bool
 	var orderType string
-	var stockPrice float64
+	var stockPrice money.Money
 	var quantity int
 	var userID string
+	var timeInForce string
+	var expiresAt time.Time
+	var displayQuantity int
 	if params.ParentStockTransaction != nil {
 		stockID = params.ParentStockTransaction.GetStockID()
 		parentStockTransactionID = params.ParentStockTransaction.GetId()
@@ -201,6 +226,9 @@ func NewStockTransaction(params NewStockTransactionParams) *StockTransaction {
 		stockPrice = params.ParentStockTransaction.GetStockPrice()
 		quantity = params.ParentStockTransaction.GetQuantity()
 		userID = params.ParentStockTransaction.GetUserID()
+		timeInForce = params.ParentStockTransaction.GetTimeInForce()
+		expiresAt = params.ParentStockTransaction.GetExpiresAt()
+		displayQuantity = params.ParentStockTransaction.GetDisplayQuantity()
 	} else {
 		parentStockTransactionID = params.ParentStockTransactionID
 		if params.StockOrder != nil {
@@ -211,6 +239,9 @@ func NewStockTransaction(params NewStockTransactionParams) *StockTransaction {
 			stockPrice = params.StockOrder.GetPrice()
 			quantity = params.StockOrder.GetQuantity()
 			userID = params.StockOrder.GetUserID()
+			timeInForce = params.StockOrder.GetTimeInForce()
+			expiresAt = params.StockOrder.GetExpiresAt()
+			displayQuantity = params.StockOrder.GetDisplayQuantity()
 		} else {
 			if params.Stock != nil {
 				stockID = params.Stock.GetId()
@@ -222,6 +253,9 @@ func NewStockTransaction(params NewStockTransactionParams) *StockTransaction {
 			stockPrice = params.StockPrice
 			quantity = params.Quantity
 			userID = params.UserID
+			timeInForce = params.TimeInForce
+			expiresAt = params.ExpiresAt
+			displayQuantity = params.DisplayQuantity
 		}
 	}
 
@@ -242,6 +276,9 @@ func NewStockTransaction(params NewStockTransactionParams) *StockTransaction {
 		Quantity:                 quantity,
 		Timestamp:                params.TimeStamp,
 		UserID:                   userID,
+		TimeInForce:              timeInForce,
+		ExpiresAt:                expiresAt,
+		DisplayQuantity:          displayQuantity,
 		Entity:                   *e,
 	}
 	return st
@@ -280,6 +317,9 @@ func (st *StockTransaction) ToParams() NewStockTransactionParams {
 		Quantity:                 st.GetQuantity(),
 		TimeStamp:                st.GetTimestamp(),
 		UserID:                   st.GetUserID(),
+		TimeInForce:              st.GetTimeInForce(),
+		ExpiresAt:                st.GetExpiresAt(),
+		DisplayQuantity:          st.GetDisplayQuantity(),
 	}
 }
 
@@ -295,7 +335,7 @@ type FakeStockTransaction struct {
 	OrderStatus              string `json:"orderStatus"`
 	IsBuy                    bool   `json:"isBuy"`
 	OrderType                string `json:"orderType"`
-	StockPrice               float64
+	StockPrice               money.Money
 	Quantity                 int
 }
 
@@ -311,17 +351,17 @@ func (fst *FakeStockTransaction) GetWalletTransactionID() string { return fst.Wa
 func (fst *FakeStockTransaction) SetWalletTransactionID(walletTransactionID string) {
 	fst.WalletTransactionID = walletTransactionID
 }
-func (fst *FakeStockTransaction) GetOrderStatus() string            { return fst.OrderStatus }
-func (fst *FakeStockTransaction) SetOrderStatus(orderStatus string) { fst.OrderStatus = orderStatus }
-func (fst *FakeStockTransaction) GetIsBuy() bool                    { return fst.IsBuy }
-func (fst *FakeStockTransaction) SetIsBuy(isBuy bool)               { fst.IsBuy = isBuy }
-func (fst *FakeStockTransaction) GetOrderType() string              { return fst.OrderType }
-func (fst *FakeStockTransaction) SetOrderType(orderType string)     { fst.OrderType = orderType }
-func (fst *FakeStockTransaction) GetStockPrice() float64            { return fst.StockPrice }
-func (fst *FakeStockTransaction) SetStockPrice(stockPrice float64)  { fst.StockPrice = stockPrice }
-func (fst *FakeStockTransaction) GetQuantity() int                  { return fst.Quantity }
-func (fst *FakeStockTransaction) SetQuantity(quantity int)          { fst.Quantity = quantity }
+func (fst *FakeStockTransaction) GetOrderStatus() string               { return fst.OrderStatus }
+func (fst *FakeStockTransaction) SetOrderStatus(orderStatus string)    { fst.OrderStatus = orderStatus }
+func (fst *FakeStockTransaction) GetIsBuy() bool                       { return fst.IsBuy }
+func (fst *FakeStockTransaction) SetIsBuy(isBuy bool)                  { fst.IsBuy = isBuy }
+func (fst *FakeStockTransaction) GetOrderType() string                 { return fst.OrderType }
+func (fst *FakeStockTransaction) SetOrderType(orderType string)        { fst.OrderType = orderType }
+func (fst *FakeStockTransaction) GetStockPrice() money.Money           { return fst.StockPrice }
+func (fst *FakeStockTransaction) SetStockPrice(stockPrice money.Money) { fst.StockPrice = stockPrice }
+func (fst *FakeStockTransaction) GetQuantity() int                     { return fst.Quantity }
+func (fst *FakeStockTransaction) SetQuantity(quantity int)             { fst.Quantity = quantity }
 func (fst *FakeStockTransaction) ToParams() NewStockTransactionParams {
 	return NewStockTransactionParams{}
 }
-func (fst *FakeStockTransaction) ToJSON() ([]byte, error) { return []byte{}, nil }
\ No newline at end of file
+func (fst *FakeStockTransaction) ToJSON() ([]byte, error) { return []byte{}, nil }
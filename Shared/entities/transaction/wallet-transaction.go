@@ -2,11 +2,19 @@ package transaction
 
 import (
 	"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/wallet"
 	"encoding/json"
 	"time"
 )
 
+// What moved money in or out of a wallet
+const (
+	WalletTransactionTrade      = "TRADE"
+	WalletTransactionCashInLieu = "CASH_IN_LIEU" // paid for the part of a share a split left over
+	WalletTransactionDividend   = "DIVIDEND"
+)
+
 type WalletTransactionInterface interface {
 	GetWalletID() string
 	SetWalletID(walletID string)
@@ -14,25 +22,31 @@ type WalletTransactionInterface interface {
 	SetStockTransactionID(stockTransactionID string)
 	GetIsDebit() bool
 	SetIsDebit(isDebit bool)
-	GetAmount() float64
-	SetAmount(amount float64)
+	GetAmount() money.Money
+	SetAmount(amount money.Money)
 	GetTimestamp() time.Time
 	SetTimestamp(timestamp time.Time)
 	SetWalletTXID()
 	GetUserID() string
 	SetUserID(userID string)
+	GetType() string
+	SetType(transactionType string)
+	GetCorporateActionID() string
+	SetCorporateActionID(corporateActionID string)
 	ToParams() NewWalletTransactionParams
 	entity.EntityInterface
 }
 
 type WalletTransaction struct {
-	WalletID           string    `json:"wallet_id" gorm:"not null"`
-	WalletTXID         string    `json:"wallet_tx_id" gorm:"-"`
-	StockTransactionID string    `json:"stock_tx_id" gorm:"not null"`
-	IsDebit            bool      `json:"is_debit" gorm:"not null"`
-	Amount             float64   `json:"amount" gorm:"not null"`
-	Timestamp          time.Time `json:"time_stamp"`
-	UserID             string    `json:"user_id" gorm:"not null"`
+	WalletID           string      `json:"wallet_id" gorm:"not null"`
+	WalletTXID         string      `json:"wallet_tx_id" gorm:"-"`
+	StockTransactionID string      `json:"stock_tx_id" gorm:"not null"`
+	IsDebit            bool        `json:"is_debit" gorm:"not null"`
+	Amount             money.Money `json:"amount" gorm:"not null"`
+	Timestamp          time.Time   `json:"time_stamp"`
+	UserID             string      `json:"user_id" gorm:"not null"`
+	Type               string      `json:"type" gorm:"not null;default:TRADE"`
+	CorporateActionID  string      `json:"corporate_action_id"` // set when a corporate action paid it, the stock transaction ID is empty then
 	// Internal functions have been commented out.
 	// GetWalletIDInternal           func() string                   `gorm:"-"`
 	// SetWalletIDInternal           func(walletID string)           `gorm:"-"`
@@ -69,11 +83,11 @@ func (wt *WalletTransaction) SetIsDebit(isDebit bool) {
 	wt.IsDebit = isDebit
 }
 
-func (wt *WalletTransaction) GetAmount() float64 {
+func (wt *WalletTransaction) GetAmount() money.Money {
 	return wt.Amount
 }
 
-func (wt *WalletTransaction) SetAmount(amount float64) {
+func (wt *WalletTransaction) SetAmount(amount money.Money) {
 	wt.Amount = amount
 }
 
@@ -97,28 +111,50 @@ func (st *WalletTransaction) SetUserID(userID string) {
 	st.UserID = userID
 }
 
+// Empty is a trade, like every transaction from before there were types.
+func (wt *WalletTransaction) GetType() string {
+	if wt.Type == "" {
+		return WalletTransactionTrade
+	}
+	return wt.Type
+}
+
+func (wt *WalletTransaction) SetType(transactionType string) {
+	wt.Type = transactionType
+}
 
+func (wt *WalletTransaction) GetCorporateActionID() string {
+	return wt.CorporateActionID
+}
+
+func (wt *WalletTransaction) SetCorporateActionID(corporateActionID string) {
+	wt.CorporateActionID = corporateActionID
+}
 
 type NewWalletTransactionParams struct {
 	entity.NewEntityParams `json:"Entity"`
-	WalletID               string    `json:"wallet_id" gorm:"not null"`
-	StockTransactionID     string    `json:"stock_tx_id" gorm:"not null"`
-	IsDebit                bool      `json:"is_debit" gorm:"not null"`
-	Amount                 float64   `json:"amount" gorm:"not null"`
-	Timestamp              time.Time `json:"time_stamp"`
+	WalletID               string      `json:"wallet_id" gorm:"not null"`
+	StockTransactionID     string      `json:"stock_tx_id" gorm:"not null"`
+	IsDebit                bool        `json:"is_debit" gorm:"not null"`
+	Amount                 money.Money `json:"amount" gorm:"not null"`
+	Timestamp              time.Time   `json:"time_stamp"`
 	Wallet                 wallet.WalletInterface
 	StockTransaction       StockTransactionInterface
-	UserID                 string    `json:"user_id"`
+	UserID                 string `json:"user_id"`
+	Type                   string `json:"type"`
+	CorporateActionID      string `json:"corporate_action_id"`
 }
 
 func NewWalletTransaction(params NewWalletTransactionParams) *WalletTransaction {
 	e := entity.NewEntity(params.NewEntityParams)
 	wt := &WalletTransaction{
-		Entity:    *e,
-		IsDebit:   params.IsDebit,
-		Amount:    params.Amount,
-		Timestamp: params.Timestamp,
-		UserID:    params.UserID,
+		Entity:            *e,
+		IsDebit:           params.IsDebit,
+		Amount:            params.Amount,
+		Timestamp:         params.Timestamp,
+		UserID:            params.UserID,
+		Type:              params.Type,
+		CorporateActionID: params.CorporateActionID,
 	}
 	if params.Wallet != nil {
 		wt.WalletID = params.Wallet.GetId()
@@ -164,6 +200,8 @@ func (wt *WalletTransaction) ToParams() NewWalletTransactionParams {
 		Amount:             wt.GetAmount(),
 		Timestamp:          wt.GetTimestamp(),
 		UserID:             wt.GetUserID(),
+		Type:               wt.GetType(),
+		CorporateActionID:  wt.GetCorporateActionID(),
 	}
 }
 
@@ -171,13 +209,12 @@ func (wt *WalletTransaction) ToJSON() ([]byte, error) {
 	return json.Marshal(wt.ToParams())
 }
 
-
 type FakeWalletTransaction struct {
 	entity.FakeEntity
-	WalletID           string  `json:"walletID"`
-	StockTransactionID string  `json:"stockTransactionID"`
-	IsDebit            bool    `json:"isDebit"`
-	Amount             float64 `json:"amount"`
+	WalletID           string      `json:"walletID"`
+	StockTransactionID string      `json:"stockTransactionID"`
+	IsDebit            bool        `json:"isDebit"`
+	Amount             money.Money `json:"amount"`
 }
 
 func (fwt *FakeWalletTransaction) GetWalletID() string           { return fwt.WalletID }
@@ -186,10 +223,14 @@ func (fwt *FakeWalletTransaction) GetStockTransactionID() string { return fwt.St
 func (fwt *FakeWalletTransaction) SetStockTransactionID(stockTransactionID string) {
 	fwt.StockTransactionID = stockTransactionID
 }
-func (fwt *FakeWalletTransaction) GetIsDebit() bool         { return fwt.IsDebit }
-func (fwt *FakeWalletTransaction) SetIsDebit(isDebit bool)  { fwt.IsDebit = isDebit }
-func (fwt *FakeWalletTransaction) GetAmount() float64       { return fwt.Amount }
-func (fwt *FakeWalletTransaction) SetAmount(amount float64) { fwt.Amount = amount }
+func (fwt *FakeWalletTransaction) GetIsDebit() bool                              { return fwt.IsDebit }
+func (fwt *FakeWalletTransaction) SetIsDebit(isDebit bool)                       { fwt.IsDebit = isDebit }
+func (fwt *FakeWalletTransaction) GetAmount() money.Money                        { return fwt.Amount }
+func (fwt *FakeWalletTransaction) SetAmount(amount money.Money)                  { fwt.Amount = amount }
+func (fwt *FakeWalletTransaction) GetType() string                               { return WalletTransactionTrade }
+func (fwt *FakeWalletTransaction) SetType(transactionType string)                {}
+func (fwt *FakeWalletTransaction) GetCorporateActionID() string                  { return "" }
+func (fwt *FakeWalletTransaction) SetCorporateActionID(corporateActionID string) {}
 func (fwt *FakeWalletTransaction) ToParams() NewWalletTransactionParams {
 	return NewWalletTransactionParams{}
 }
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/user"
 	"encoding/json"
 )
@@ -9,15 +10,15 @@ import (
 type WalletInterface interface {
 	GetUserID() string
 	SetUserID(userID string)
-	GetBalance() float64
-	SetBalance(balance float64)
+	GetBalance() money.Money
+	SetBalance(balance money.Money)
 	ToParams() NewWalletParams
 	entity.EntityInterface
 }
 
 type Wallet struct {
-	UserID  string  `json:"user_id" gorm:"not null"`
-	Balance float64 `json:"balance" gorm:"not null"`
+	UserID  string      `json:"user_id" gorm:"not null"`
+	Balance money.Money `json:"balance" gorm:"not null"`
 	// The internal function fields have been commented out,
 	// and the getters/setters below operate directly on the properties.
 	/*
@@ -29,11 +30,11 @@ type Wallet struct {
 	entity.Entity `json:"Entity" gorm:"embedded"`
 }
 
-func (w *Wallet) GetBalance() float64 {
+func (w *Wallet) GetBalance() money.Money {
 	return w.Balance
 }
 
-func (w *Wallet) SetBalance(balance float64) {
+func (w *Wallet) SetBalance(balance money.Money) {
 	w.Balance = balance
 }
 
@@ -48,7 +49,7 @@ func (w *Wallet) SetUserID(userID string) {
 type NewWalletParams struct {
 	entity.NewEntityParams `json:"Entity"`
 	UserID                 string             `json:"user_id" gorm:"not null"`
-	Balance                float64            `json:"balance" gorm:"not null"`
+	Balance                money.Money        `json:"balance" gorm:"not null"`
 	User                   user.UserInterface // use this or UserId
 }
 
@@ -107,18 +108,18 @@ func (w *Wallet) ToJSON() ([]byte, error) {
 type FakeWallet struct {
 	entity.FakeEntity
 	UserID  string `json:"UserId"`
-	Balance float64
+	Balance money.Money
 }
 
-func (fw *FakeWallet) GetUserID() string          { return fw.UserID }
-func (fw *FakeWallet) SetUserID(userID string)    { fw.UserID = userID }
-func (fw *FakeWallet) GetBalance() float64        { return fw.Balance }
-func (fw *FakeWallet) SetBalance(balance float64) { fw.Balance = balance }
-func (fw *FakeWallet) ToParams() NewWalletParams  { return NewWalletParams{} }
-func (fw *FakeWallet) ToJSON() ([]byte, error)    { return []byte{}, nil }
+func (fw *FakeWallet) GetUserID() string              { return fw.UserID }
+func (fw *FakeWallet) SetUserID(userID string)        { fw.UserID = userID }
+func (fw *FakeWallet) GetBalance() money.Money        { return fw.Balance }
+func (fw *FakeWallet) SetBalance(balance money.Money) { fw.Balance = balance }
+func (fw *FakeWallet) ToParams() NewWalletParams      { return NewWalletParams{} }
+func (fw *FakeWallet) ToJSON() ([]byte, error)        { return []byte{}, nil }
 
 func (w *Wallet) SetDefaults() {
 	if w.Balance == 0 {
-		w.Balance = 0.00
+		w.Balance = money.FromCents(0)
 	}
 }
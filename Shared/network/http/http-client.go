@@ -257,6 +257,12 @@ type contextKey string
 // userIDKey is the key used for storing user ID in the context.
 var userIDKey = contextKey("userID")
 
+// The user ID TokenAuthMiddleware put on the request, for handlers registered outside of AddHandleFuncProtected.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey).(string)
+	return userID, ok
+}
+
 func TokenAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := r.Header.Get("token")
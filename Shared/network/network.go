@@ -137,6 +137,15 @@ type HandlerParams struct {
 	RequestType string
 }
 
+// Routing key for a queue message about one stock, so it reaches the matching engine instance that owns the stock.
+// A trailing / stays on the end, so "deleteOrder/" gives "deleteOrder.<stock ID>/".
+func StockRoute(route string, stockID string) string {
+	if strings.HasSuffix(route, "/") {
+		return strings.TrimSuffix(route, "/") + "." + stockID + "/"
+	}
+	return route + "." + stockID
+}
+
 func CreateNetworkEntityHandlers[T entity.EntityInterface](network NetworkInterface, entityName string, databaseManager databaseService.EntityDataInterface[T], Parse func(jsonBytes []byte) (T, error), ParseList func(jsonBytes []byte) (*[]T, error)) {
 	defaults := func(responseWriter ResponseWriter, data []byte, queryParams url.Values, requestType string) {
 		fmt.Println("-----------------\nRequest:")
@@ -15,8 +15,13 @@ import (
 type QueueClusterInterface interface {
 	QueueConnectionInterface
 	SpawnQueue()
+	Bind(routingKey string) error
+	Unbind(routingKey string) error
 }
 
+// Added to the query params of every message, so a handler can tell a message sent twice from two that are the same
+const CorrelationIDParam = "correlationID"
+
 type QueueCluster struct {
 	QueueConnectionInterface
 	network.HandlerParams
@@ -31,6 +36,8 @@ type QueueCluster struct {
 	ConsumeNoLocal   bool
 	ConsumeNoWait    bool
 	ConsumeArgs      map[string]interface{}
+	queueName        string
+	ready            chan struct{} // closed once the queue is declared and bound
 }
 
 type NewQueueClusterParams struct {
@@ -62,6 +69,7 @@ func NewQueueCluster(exchangeKey string, handler network.HandlerParams, params *
 		Exclusive:                params.Exclusive,
 		NoWait:                   params.NoWait,
 		Args:                     params.Args,
+		ready:                    make(chan struct{}),
 	}
 }
 
@@ -112,6 +120,8 @@ func (n *QueueCluster) SpawnQueue() {
 	)
 	failOnError(err, "Failed to bind a queue")
 	println("Queue Bound: ", q.Name)
+	n.queueName = q.Name
+	close(n.ready)
 
 	msg, err := ch.Consume(
 		q.Name, // queue
@@ -143,12 +153,35 @@ func (n *QueueCluster) SpawnQueue() {
 			for k, v := range data.Headers {
 				queryParams.Add(k, v)
 			}
+			queryParams.Set(CorrelationIDParam, d.CorrelationId)
 			n.HandlerParams.Handler(responseHandler, payload, queryParams, data.MessageType)
 		}
 	}()
 	<-make(chan struct{})
 }
 
+// Routes messages with routingKey to this queue as well as its pattern. Waits for the queue to be spawned.
+func (n *QueueCluster) Bind(routingKey string) error {
+	<-n.ready
+	exchangeParams := ExchangeParamsDefaults()
+	exchangeParams.Name = n.ExchangeKey
+	ch := n.SpawnChannel(exchangeParams)
+	defer n.CloseChannel(ch)
+	println("Binding: ", routingKey, " to queue: ", n.queueName)
+	return ch.QueueBind(n.queueName, routingKey, n.ExchangeKey, false, nil)
+}
+
+// Messages with routingKey stop coming to this queue. Ones already in it are still handled.
+func (n *QueueCluster) Unbind(routingKey string) error {
+	<-n.ready
+	exchangeParams := ExchangeParamsDefaults()
+	exchangeParams.Name = n.ExchangeKey
+	ch := n.SpawnChannel(exchangeParams)
+	defer n.CloseChannel(ch)
+	println("Unbinding: ", routingKey, " from queue: ", n.queueName)
+	return ch.QueueUnbind(n.queueName, routingKey, n.ExchangeKey, nil)
+}
+
 type QueueResponseHandler struct {
 	d  amqp091.Delivery
 	ch *amqp.Channel
@@ -2,10 +2,17 @@ package networkQueue
 
 import (
 	"Shared/network"
+	"fmt"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// Lets a service change which routing keys reach its handlers while it runs
+type QueueRouterInterface interface {
+	Bind(pattern string, routingKey string) error
+	Unbind(pattern string, routingKey string) error
+}
+
 type NetworkQueue struct {
 	network.BaseNetworkInterface
 	QueueConnectionInterface
@@ -60,6 +67,23 @@ func (n *NetworkQueue) AddHandleFuncProtected(params network.HandlerParams) {
 	panic("Internal Queues should not be used for External Requests")
 }
 
+// Sends messages with routingKey to the handler for pattern, on top of the pattern itself.
+func (n *NetworkQueue) Bind(pattern string, routingKey string) error {
+	cluster, ok := n.QueueClusters[pattern]
+	if !ok {
+		return fmt.Errorf("no handler for pattern %s", pattern)
+	}
+	return cluster.Bind(routingKey)
+}
+
+func (n *NetworkQueue) Unbind(pattern string, routingKey string) error {
+	cluster, ok := n.QueueClusters[pattern]
+	if !ok {
+		return fmt.Errorf("no handler for pattern %s", pattern)
+	}
+	return cluster.Unbind(routingKey)
+}
+
 func (n *NetworkQueue) Listen() {
 	println("Listening")
 	println("Queue clusters: ", len(n.QueueClusters))
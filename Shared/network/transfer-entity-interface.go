@@ -1,23 +1,271 @@
 package network
 
+import (
+	"Shared/entities/money"
+	"Shared/entities/stock"
+	"time"
+)
+
 type MatchingEngineToExecutionJSON struct {
-	BuyerID       string  `json:"buyer_id"`
-	SellerID      string  `json:"seller_id"`
-	StockID       string  `json:"stock_id"`
-	BuyOrderID    string  `json:"buy_order_id"`
-	SellOrderID   string  `json:"sell_order_id"`
-	IsBuyPartial  bool    `json:"is_buy_partial"`
-	IsSellPartial bool    `json:"is_sell_partial"`
-	StockPrice    float64 `json:"stock_price"`
-	Quantity      int     `json:"quantity"`
+	BuyerID       string      `json:"buyer_id"`
+	SellerID      string      `json:"seller_id"`
+	StockID       string      `json:"stock_id"`
+	BuyOrderID    string      `json:"buy_order_id"`
+	SellOrderID   string      `json:"sell_order_id"`
+	IsBuyPartial  bool        `json:"is_buy_partial"`
+	IsSellPartial bool        `json:"is_sell_partial"`
+	StockPrice    money.Money `json:"stock_price"`
+	Quantity      int         `json:"quantity"`
+	MatchID       string      `json:"match_id"` // the same match sent again, e.g. after a timeout, gets the first answer instead of trading twice
 }
 
+type StockPrice struct {
+	StockID    string      `json:"stock_id"`
+	StockName  string      `json:"stock_name"`
+	Price      money.Money `json:"current_price"`
+	Halted     bool        `json:"halted"`
+	HaltReason string      `json:"halt_reason,omitempty"`
+	// Only filled in when a quote is asked for
+	LastPrice *money.Money `json:"last_price,omitempty"`
+	BidPrice  *money.Money `json:"bid_price,omitempty"`
+	AskPrice  *money.Money `json:"ask_price,omitempty"`
+}
 
+// One execution on a stock's trade tape. Aggressor side is BUY or SELL.
+type Trade struct {
+	Price         money.Money `json:"price"`
+	Quantity      int         `json:"quantity"`
+	Timestamp     time.Time   `json:"time_stamp"`
+	AggressorSide string      `json:"aggressor_side"`
+}
 
-type StockPrice struct {
-	StockID   string  `json:"stock_id"`
-	StockName string  `json:"stock_name"`
-	Price     float64 `json:"current_price"`
+type StockTrades struct {
+	StockID string  `json:"stock_id"`
+	Trades  []Trade `json:"trades"`
+}
+
+// Sent by the matching engine for every trade, to build the price history.
+type StockTrade struct {
+	StockID string `json:"stock_id"`
+	Trade   Trade  `json:"trade"`
+}
+
+// Changes a resting order in place. Quantity is the new open quantity and Price the new limit price. Leave either 0 to keep it.
+type AmendStockOrder struct {
+	StockTxID string      `json:"stock_tx_id"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+}
+
+// The order after an amend. QuantityDelta is how much the open quantity changed by.
+type AmendStockOrderResult struct {
+	StockTxID     string      `json:"stock_tx_id"`
+	Quantity      int         `json:"quantity"`
+	Price         money.Money `json:"price"`
+	QuantityDelta int         `json:"quantity_delta"`
+	Requeued      bool        `json:"requeued"` // false if the order kept its time priority
+}
+
+// Best bid and ask of a stock. Prices and quantities are 0 when that side is empty.
+type BookTop struct {
+	StockID     string      `json:"stock_id"`
+	BidPrice    money.Money `json:"bid_price"`
+	BidQuantity int         `json:"bid_quantity"`
+	AskPrice    money.Money `json:"ask_price"`
+	AskQuantity int         `json:"ask_quantity"`
+}
+
+// Pushed to the owner of an order whenever it fills, partially fills or is cancelled.
+type OrderStatusUpdate struct {
+	StockTxID         string `json:"stock_tx_id"`
+	StockID           string `json:"stock_id"`
+	OrderStatus       string `json:"order_status"`
+	FilledQuantity    int    `json:"filled_quantity"`
+	RemainingQuantity int    `json:"remaining_quantity"`
+}
+
+// Whether trading in a stock is halted. Orders placed while halted are queued and match once it resumes.
+type HaltStatus struct {
+	StockID        string      `json:"stock_id"`
+	Halted         bool        `json:"halted"`
+	InAuction      bool        `json:"in_auction"` // orders are collecting for a call auction
+	Reason         string      `json:"reason,omitempty"`
+	HaltedAt       *time.Time  `json:"halted_at,omitempty"`
+	ReferencePrice money.Money `json:"reference_price"` // the price band is measured from this. 0 until the stock trades
+	BandPercent    float64     `json:"band_percent"`    // 0 when there is no price band
+}
+
+// How many orders are waiting for a stock's matching loop. Once Queued reaches Capacity new orders are turned away until it catches up.
+type EngineIntake struct {
+	StockID  string `json:"stock_id"`
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	Rejected int    `json:"rejected"` // turned away since the engine started
+}
+
+// What a call auction uncrossed at. The trades themselves are executed straight after.
+type AuctionResult struct {
+	StockID       string      `json:"stock_id"`
+	ClearingPrice money.Money `json:"clearing_price"`
+	Volume        int         `json:"volume"` // 0 when nothing crossed
+}
+
+// Body of the halt, resume and auction endpoints.
+type HaltStock struct {
+	StockID string `json:"stock_id"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// A match the executor didn't answer, held out of the book until it is resolved.
+type QuarantinedMatch struct {
+	MatchID       string      `json:"match_id"`
+	StockID       string      `json:"stock_id"`
+	BuyOrderID    string      `json:"buy_order_id"`
+	SellOrderID   string      `json:"sell_order_id"`
+	BuyerID       string      `json:"buyer_id"`
+	SellerID      string      `json:"seller_id"`
+	Quantity      int         `json:"quantity"`
+	StockPrice    money.Money `json:"stock_price"`
+	Attempts      int         `json:"attempts"`
+	LastError     string      `json:"last_error"`
+	QuarantinedAt time.Time   `json:"quarantined_at"`
+	NextAttemptAt *time.Time  `json:"next_attempt_at,omitempty"` // nil once retries have run out, it waits for an operator
+}
+
+// Body of the release endpoint. Action is RETRY, REQUEUE or CANCEL.
+type ReleaseQuarantinedMatch struct {
+	StockID string `json:"stock_id"`
+	MatchID string `json:"match_id"`
+	Action  string `json:"action"`
+}
+
+// Body of the rebalance endpoint. Every matching engine instance that should own stocks once it is done, by name.
+type MatchingEngineInstances struct {
+	Instances []string `json:"instances"`
+}
+
+// What delisting a stock did with the orders resting in its books.
+type DelistedStock struct {
+	StockID         string   `json:"stock_id"`
+	CancelledOrders []string `json:"cancelled_orders"`        // stock transaction IDs
+	FailedOrders    []string `json:"failed_orders,omitempty"` // couldn't be cancelled, they need cancelling by hand
+}
+
+// Where a stock is in its life, one of the stock statuses.
+type StockStatus struct {
+	StockID string `json:"stock_id"`
+	Status  string `json:"status"`
+}
+
+// Expected by setup/splitStock. The stock must be suspended.
+type StockSplit struct {
+	StockID string `json:"stock_id"`
+	stock.Split
+	CashPrice money.Money `json:"cash_price,omitempty"` // per old share, pays for parts of a share left over. leave 0 for the last trade price
+}
+
+// Rescales every holding of a stock. Escrowed is each seller's shares resting in sell orders before the split, EscrowedAfter after it.
+// A holding is split with its escrowed shares, which then stay in escrow. The same CorporateActionID is only ever split once.
+type UserStockSplit struct {
+	CorporateActionID string         `json:"corporate_action_id"`
+	StockID           string         `json:"stock_id"`
+	Split             stock.Split    `json:"split"`
+	CashPrice         money.Money    `json:"cash_price"`
+	Escrowed          map[string]int `json:"escrowed"`
+	EscrowedAfter     map[string]int `json:"escrowed_after"`
+}
+
+// One holder's shares, escrowed ones included, across a split. Cash is what they were paid for the part of a share left over.
+type SplitHolding struct {
+	UserID   string      `json:"user_id"`
+	WalletID string      `json:"wallet_id,omitempty"`
+	Before   int         `json:"before"`
+	After    int         `json:"after"`
+	Cash     money.Money `json:"cash"`
+}
+
+// What a split did to the stock's holders and resting orders.
+type StockSplitResult struct {
+	CorporateActionID string `json:"corporate_action_id"`
+	StockID           string `json:"stock_id"`
+	stock.Split
+	CashPrice       money.Money    `json:"cash_price"`
+	Holdings        []SplitHolding `json:"holdings"`
+	RescaledOrders  []string       `json:"rescaled_orders"`         // stock transaction IDs
+	CancelledOrders []string       `json:"cancelled_orders"`        // left with less than a share
+	FailedOrders    []string       `json:"failed_orders,omitempty"` // their records couldn't be updated, they need fixing by hand
+}
+
+// Expected by setup/declareDividend. Amount is paid per share to whoever holds the stock on the record date.
+type DividendDeclaration struct {
+	StockID    string      `json:"stock_id"`
+	Amount     money.Money `json:"amount"`
+	RecordDate time.Time   `json:"record_date"` // leave out to pay straight away
+}
+
+// Pays a dividend to every holder of a stock. Escrowed is each seller's shares resting in sell orders, which they still own.
+// Paying the same corporate action again gives back what was paid the first time.
+type DividendPayout struct {
+	CorporateActionID string         `json:"corporate_action_id"`
+	StockID           string         `json:"stock_id"`
+	Amount            money.Money    `json:"amount"`
+	Escrowed          map[string]int `json:"escrowed"`
+}
+
+// What one holder was paid.
+type DividendPayment struct {
+	UserID   string      `json:"user_id"`
+	WalletID string      `json:"wallet_id"`
+	Shares   int         `json:"shares"`
+	Cash     money.Money `json:"cash"`
+}
+
+// A declared dividend. Payments are empty until it is paid on the record date.
+type DividendResult struct {
+	CorporateActionID string            `json:"corporate_action_id"`
+	StockID           string            `json:"stock_id"`
+	Amount            money.Money       `json:"amount"`
+	RecordDate        time.Time         `json:"record_date"`
+	Status            string            `json:"status"`
+	Payments          []DividendPayment `json:"payments"`
+}
+
+// A stock's book moving from one matching engine instance to another.
+type StockHandoff struct {
+	StockID string `json:"stock_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Error   string `json:"error,omitempty"` // the book stayed where it was
+}
+
+type Candle struct {
+	StartTime time.Time   `json:"start_time"`
+	Open      money.Money `json:"open"`
+	High      money.Money `json:"high"`
+	Low       money.Money `json:"low"`
+	Close     money.Money `json:"close"`
+	Volume    int         `json:"volume"`
+}
+
+// Candles are oldest first.
+type StockPriceHistory struct {
+	StockID  string   `json:"stock_id"`
+	Interval string   `json:"interval"`
+	Candles  []Candle `json:"candles"`
+}
+
+// One aggregated price level of a stock's order book.
+type PriceLevel struct {
+	Price      money.Money `json:"price"`
+	Quantity   int         `json:"quantity"`
+	OrderCount int         `json:"order_count"`
+}
+
+// Bids are highest price first, asks lowest price first.
+type StockOrderBook struct {
+	StockID string       `json:"stock_id"`
+	Bids    []PriceLevel `json:"bids"`
+	Asks    []PriceLevel `json:"asks"`
 }
 
 type StockID struct {
@@ -29,7 +277,7 @@ type StockTransactionID struct {
 }
 
 type WalletBalance struct {
-	Balance float64 `json:"balance"`
+	Balance money.Money `json:"balance"`
 }
 
 type AddStock struct {
@@ -46,12 +294,19 @@ type ExecutorToMatchingEngineJSON struct {
 	IsSellFailure bool `json:"is_sell_failed"`
 }
 
+// The executor's answer to one match of a batch. Error is set if the match wasn't run, so it can be sent again.
+type MatchExecutionResult struct {
+	MatchID string `json:"match_id"`
+	ExecutorToMatchingEngineJSON
+	Error string `json:"error,omitempty"`
+}
+
 type ReturnJSON struct {
 	Success bool `json:"success"`
 	Data    any  `json:"data"`
 }
 
-/* 
+/*
 	// Create custom response structure
 	type StockPortfolioResponse struct {
 		StockID       string    `json:"stock_id"`
@@ -69,4 +324,4 @@ type ReturnJSON struct {
 			QuantityOwned: stock.GetQuantity(),
 			UpdatedAt:     stock.GetUpdatedAt(),
 		})
-	} */
\ No newline at end of file
+	} */
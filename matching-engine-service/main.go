@@ -6,14 +6,22 @@ import (
 	networkQueue "Shared/network/queue"
 	"databaseAccessStock"
 	"databaseAccessStockOrder"
+	"databaseAccessTransaction"
+	"databaseAccessUserManagement"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 )
 
 //"Shared/network"
 
 func main() {
 	//Need to upgrade to use my entity class stuff and the new services.
+	if os.Getenv("JOURNAL_REPLAY") != "" {
+		replayJournals(os.Getenv("JOURNAL_REPLAY"))
+		return
+	}
 
 	networkHttpManager := networkHttp.NewNetworkHttp()
 	networkQueueManager := networkQueue.NewNetworkQueue(nil, os.Getenv("MATCHING_ENGINE_HOST")+":"+os.Getenv("MATCHING_ENGINE_PORT"))
@@ -21,13 +29,50 @@ func main() {
 	_databaseAccess := databaseAccessStock.NewDatabaseAccess(&databaseAccessStock.NewDatabaseAccessParams{
 		Network: networkHttpManager,
 	})
+	_userDatabaseAccess := databaseAccessUserManagement.NewDatabaseAccess(&databaseAccessUserManagement.NewDatabaseAccessParams{
+		Network: networkHttpManager,
+	})
+	_transactionDatabaseAccess := databaseAccessTransaction.NewDatabaseAccess(&databaseAccessTransaction.NewDatabaseAccessParams{
+		Network: networkHttpManager,
+	})
 	stockList, err := _databaseAccess.GetStockIDs()
 	if err != nil {
 		panic(err)
 	}
 
-	go matchingEngine.InitalizeHandlers(stockList, networkHttpManager, networkQueueManager, _databaseManager, _databaseAccess)
+	go matchingEngine.InitalizeHandlers(stockList, networkHttpManager, networkQueueManager, _databaseManager, _databaseAccess, _userDatabaseAccess, _transactionDatabaseAccess)
 	fmt.Println("Matching Engine Service Started")
 
 	networkHttpManager.Listen()
 }
+
+// Replay mode. Rebuilds the books from a journal file, or every journal in a directory, prints them as JSON and exits.
+// Nothing is connected to, so a copy of production's journals can be replayed anywhere.
+// JOURNAL_REPLAY_UNTIL stops each replay after that sequence number.
+func replayJournals(path string) {
+	var untilSequence uint64
+	if os.Getenv("JOURNAL_REPLAY_UNTIL") != "" {
+		parsed, err := strconv.ParseUint(os.Getenv("JOURNAL_REPLAY_UNTIL"), 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		untilSequence = parsed
+	}
+	var results []*matchingEngine.ReplayResult
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		results, err = matchingEngine.ReplayJournals(path, untilSequence)
+	} else if err == nil {
+		var result *matchingEngine.ReplayResult
+		result, err = matchingEngine.ReplayJournal(&matchingEngine.ReplayParams{Path: path, UntilSequence: untilSequence})
+		results = append(results, result)
+	}
+	if err != nil {
+		panic(err)
+	}
+	output, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(output))
+}
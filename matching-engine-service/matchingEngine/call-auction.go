@@ -0,0 +1,81 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/network"
+	"errors"
+)
+
+var ErrNoAuction = errors.New("stock is not in an auction")
+
+type uncrossRequest struct {
+	done   chan network.AuctionResult
+	failed chan error
+}
+
+// Starts a call auction, for a market open or close. Orders collect in the book without matching until Uncross.
+// Returns false if an auction is already running.
+func (me *MatchingEngine) StartAuction() bool {
+	me.haltMutex.Lock()
+	defer me.haltMutex.Unlock()
+	if me.inAuction {
+		return false
+	}
+	println("Starting call auction for stock: ", me.StockId)
+	me.inAuction = true
+	me.journal(matchingEngineStructures.JournalEntry{Type: matchingEngineStructures.JournalStartAuction})
+	return true
+}
+
+func (me *MatchingEngine) InAuction() bool {
+	me.haltMutex.Lock()
+	defer me.haltMutex.Unlock()
+	return me.inAuction
+}
+
+// Ends the auction and returns the clearing price. The matching loop then executes every crossing order at that price
+// through the executor, before going back to continuous matching. A halted stock can't uncross until it resumes.
+func (me *MatchingEngine) Uncross() (network.AuctionResult, error) {
+	if !me.InAuction() {
+		return network.AuctionResult{}, ErrNoAuction
+	}
+	request := &uncrossRequest{
+		done:   make(chan network.AuctionResult, 1),
+		failed: make(chan error, 1),
+	}
+	me.uncrossChannel <- request
+	select {
+	case result := <-request.done:
+		return result, nil
+	case err := <-request.failed:
+		return network.AuctionResult{}, err
+	}
+}
+
+// Only ever run from the matching loop, so the book can't change while the price is worked out.
+func (me *MatchingEngine) uncross(request *uncrossRequest) {
+	if me.IsHalted() {
+		request.failed <- errors.New("trading is halted")
+		return
+	}
+	if !me.InAuction() {
+		request.failed <- ErrNoAuction
+		return
+	}
+	price, volume := matchingEngineStructures.ClearingPrice(me.BuyOrderBook.GetOrders(), me.SellOrderBook.GetOrders(), me.GetLastPrice())
+	println("Uncrossing auction for stock: ", me.StockId, " at ", price.String(), " for ", volume, " shares")
+	me.auctionPrice = price
+	me.auctionVolume = volume
+	// the band starts again from the auction price
+	me.PriceBand.Reset()
+	me.haltMutex.Lock()
+	me.inAuction = false
+	me.haltMutex.Unlock()
+	// journaled from the loop, ahead of the trades it leads to
+	me.journal(matchingEngineStructures.JournalEntry{Type: matchingEngineStructures.JournalUncross, Quantity: volume, Price: price})
+	request.done <- network.AuctionResult{
+		StockID:       me.StockId,
+		ClearingPrice: price,
+		Volume:        volume,
+	}
+}
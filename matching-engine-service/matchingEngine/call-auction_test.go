@@ -0,0 +1,35 @@
+package matchingEngine
+
+import (
+	"testing"
+)
+
+func TestUncrossTradesEveryCrossingOrderAtTheClearingPrice(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	me.StartAuction()
+	addOrders(t, me,
+		testOrder("buy-1", "bob", true, 5, 1020),
+		testOrder("buy-2", "carol", true, 3, 1000),
+		testOrder("sell-1", "dave", false, 4, 980),
+		testOrder("sell-2", "erin", false, 6, 1010),
+	)
+	expectStrings(t, "matches during the auction", executor.matched())
+
+	// 5 shares trade at 10.10 or 10.20 with the same imbalance, and the lower wins
+	result, err := me.Uncross()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ClearingPrice != 1010 || result.Volume != 5 {
+		t.Errorf("got %d at %s, wanted 5 at 10.10", result.Volume, result.ClearingPrice)
+	}
+	me.settle()
+	// sell-1 trades at the clearing price rather than either limit, buy-2 can't trade at it
+	expectStrings(t, "matches", executor.matched(), "buy-1/sell-1 4@10.10", "buy-1/sell-2 1@10.10")
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()), "buy-2:3")
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-2:5")
+
+	if _, err := me.Uncross(); err != ErrNoAuction {
+		t.Errorf("got %v uncrossing again, wanted %v", err, ErrNoAuction)
+	}
+}
@@ -0,0 +1,357 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/entities/stock"
+	"Shared/entities/transaction"
+	"Shared/network"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrNotSuspended = errors.New("suspend the stock before splitting it, so no order trades part way through")
+var errSplitQuarantined = errors.New("stock has quarantined matches, release them before splitting it")
+var errNoCashPrice = errors.New("the split leaves parts of a share and the stock has no last trade price to pay them at, give a cash price")
+
+var _corporateActionsMutex = &sync.Mutex{} // splits and dividend payments on this instance run one at a time
+
+type splitRequest struct {
+	corporateActionID string
+	split             stock.Split
+	cashPrice         money.Money
+	done              chan *SplitResult
+}
+
+// What a split did to a stock's books. Cancelled orders were left with less than a share, their escrow was split with the seller's holding.
+type SplitResult struct {
+	Holdings  []network.SplitHolding
+	Rescaled  []RescaledOrder
+	Cancelled []order.StockOrderInterface
+	Err       error
+}
+
+// An order as it rests after a split, and its open quantity before it.
+type RescaledOrder struct {
+	Order       order.StockOrderInterface
+	OldQuantity int
+}
+
+// Rescales every resting order of a suspended stock, once AdjustHoldings has rescaled what its holders own.
+// Nothing changes if that fails. The price band starts over, and the trades kept are adjusted so they compare with the trades after.
+// corporateActionID is the split's record, the holdings are only rescaled once for it.
+func (me *MatchingEngine) Split(corporateActionID string, split stock.Split, cashPrice money.Money) *SplitResult {
+	err := split.Validate()
+	if err != nil {
+		return &SplitResult{Err: err}
+	}
+	request := &splitRequest{
+		corporateActionID: corporateActionID,
+		split:             split,
+		cashPrice:         cashPrice,
+		done:              make(chan *SplitResult, 1),
+	}
+	me.splitChannel <- request
+	return <-request.done
+}
+
+// Takes the input mutex from the matching loop while the stock isn't trading. An input holding it may be waiting for the loop
+// to take its order off the intake, so queued orders are taken while it waits. Once it has the mutex no order can be queued,
+// and the ones already queued are taken too, so every stop order is in the trigger book.
+func (me *MatchingEngine) lockInput() {
+	locked := make(chan struct{})
+	go func() {
+		me.inputMutex.Lock()
+		close(locked)
+	}()
+	for {
+		select {
+		case <-locked:
+			for me.queued() > 0 {
+				me.holdOrder(<-me.orderChannel)
+			}
+			return
+		case stockOrder := <-me.orderChannel:
+			me.holdOrder(stockOrder)
+		}
+	}
+}
+
+// Only ever run from the matching loop.
+func (me *MatchingEngine) splitBooks(request *splitRequest) {
+	if me.GetHaltStatus().Reason != HaltReasonSuspended {
+		request.done <- &SplitResult{Err: ErrNotSuspended}
+		return
+	}
+	// an order out with the executor is in no book, so it would miss the split
+	me.drainSettlements()
+	me.lockInput()
+	defer me.inputMutex.Unlock()
+	if len(me.Quarantine.GetMatches()) > 0 {
+		request.done <- &SplitResult{Err: errSplitQuarantined}
+		return
+	}
+	println("Splitting stock: ", me.StockId, " ", request.split.NewShares, " for ", request.split.OldShares)
+
+	result := &SplitResult{}
+	holdings := &network.UserStockSplit{
+		CorporateActionID: request.corporateActionID,
+		StockID:           me.StockId,
+		Split:             request.split,
+		CashPrice:         request.cashPrice,
+		Escrowed:          map[string]int{},
+		EscrowedAfter:     map[string]int{},
+	}
+	rescale := func(orders []order.StockOrderInterface) []order.StockOrderInterface {
+		kept := []order.StockOrderInterface{}
+		for _, stockOrder := range orders {
+			rescaled := rescaleOrder(stockOrder, request.split)
+			if !stockOrder.GetIsBuy() {
+				holdings.Escrowed[stockOrder.GetUserID()] += stockOrder.GetQuantity()
+				holdings.EscrowedAfter[stockOrder.GetUserID()] += rescaled.GetQuantity()
+			}
+			if rescaled.GetQuantity() == 0 {
+				result.Cancelled = append(result.Cancelled, stockOrder)
+				continue
+			}
+			result.Rescaled = append(result.Rescaled, RescaledOrder{Order: rescaled, OldQuantity: stockOrder.GetQuantity()})
+			kept = append(kept, rescaled)
+		}
+		return kept
+	}
+	buyOrders := me.BuyOrderBook.GetOrders()
+	sellOrders := me.SellOrderBook.GetOrders()
+	stopOrders := me.TriggerBook.GetOrders()
+	keptBuys := rescale(buyOrders)
+	keptSells := rescale(sellOrders)
+	keptStops := rescale(stopOrders)
+
+	if me.AdjustHoldings != nil {
+		var err error
+		result.Holdings, err = me.AdjustHoldings(holdings)
+		if err != nil {
+			println("Error splitting holdings of stock: ", me.StockId, " ", err.Error())
+			request.done <- &SplitResult{Err: err}
+			return
+		}
+	}
+	me.journal(matchingEngineStructures.JournalEntry{Type: matchingEngineStructures.JournalSplit, Split: &request.split})
+
+	// put back in the order they were in, so every order keeps its time priority
+	for _, stockOrder := range buyOrders {
+		me.BuyOrderBook.RemoveOrder(&matchingEngineStructures.RemoveParams{OrderID: stockOrder.GetId(), PriceKey: stockOrder.GetPrice()})
+	}
+	for _, stockOrder := range sellOrders {
+		me.SellOrderBook.RemoveOrder(&matchingEngineStructures.RemoveParams{OrderID: stockOrder.GetId(), PriceKey: stockOrder.GetPrice()})
+	}
+	for _, stockOrder := range stopOrders {
+		me.TriggerBook.RemoveOrder(stockOrder.GetId())
+	}
+	for _, stockOrder := range keptBuys {
+		me.BuyOrderBook.AddOrder(stockOrder)
+	}
+	for _, stockOrder := range keptSells {
+		me.SellOrderBook.AddOrder(stockOrder)
+	}
+	for _, stockOrder := range keptStops {
+		me.TriggerBook.AddOrder(stockOrder)
+	}
+	for _, stockOrder := range result.Cancelled {
+		me.publishOrderStatus(stockOrder, "CANCELLED", 0)
+	}
+	me.TradeTape.Adjust(func(trade matchingEngineStructures.Trade) matchingEngineStructures.Trade {
+		trade.Price = request.split.Price(trade.Price)
+		trade.Quantity, _ = request.split.Shares(trade.Quantity)
+		return trade
+	})
+	me.PriceBand.Reset()
+	me.publishBookTop()
+	println("Split stock: ", me.StockId, " Rescaled orders: ", len(result.Rescaled), " Cancelled orders: ", len(result.Cancelled))
+	request.done <- result
+}
+
+// The order as it rests after a split. Its quantity is 0 if it was left with less than a share.
+// An iceberg shows a fresh slice of its new size.
+func rescaleOrder(stockOrder order.StockOrderInterface, split stock.Split) order.StockOrderInterface {
+	params := stockOrder.ToParams()
+	params.Quantity, _ = split.Shares(params.Quantity)
+	params.Price = split.Price(params.Price)
+	params.StopPrice = split.Price(params.StopPrice)
+	if params.DisplayQuantity > 0 {
+		displayQuantity, _ := split.Shares(params.DisplayQuantity)
+		params.DisplayQuantity = max(displayQuantity, 1)
+		params.VisibleQuantity = 0
+	}
+	return order.New(params)
+}
+
+// Expected input is a network.StockSplit, for a suspended stock. Answers with a network.ReturnJSON holding the network.StockSplitResult.
+// Refused with a conflict unless the stock is suspended and has no quarantined matches.
+func SplitStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Splitting stock")
+	var split network.StockSplit
+	err := json.Unmarshal(data, &split)
+	if err == nil {
+		err = split.Split.Validate()
+	}
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	result, err := SplitStock(split)
+	if err != nil {
+		println("Error: ", err.Error())
+		switch {
+		case errors.Is(err, errStockNotListed):
+			responseWriter.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, ErrNotSuspended), errors.Is(err, errSplitQuarantined), errors.Is(err, errNoCashPrice):
+			responseWriter.WriteHeader(http.StatusConflict)
+		default:
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	writeReturnJSON(responseWriter, result)
+}
+
+// Splits the stock's holdings and books, then brings the orders' records and transactions in line and records the split.
+// Call holding the stock.
+func SplitStock(split network.StockSplit) (*network.StockSplitResult, error) {
+	_corporateActionsMutex.Lock()
+	defer _corporateActionsMutex.Unlock()
+	me, ok := stockEngine(split.StockID)
+	if !ok {
+		return nil, errStockNotListed
+	}
+	if split.CashPrice == 0 {
+		split.CashPrice = me.GetLastPrice()
+	}
+	if split.CashPrice == 0 && split.LeavesFractions() {
+		return nil, errNoCashPrice
+	}
+	// written first, so its ID keeps the user database from splitting the holdings twice
+	action, err := _transactionDatabaseAccess.CorporateAction().Create(transaction.NewCorporateAction(transaction.NewCorporateActionParams{
+		StockID:    split.StockID,
+		ActionType: transaction.CorporateActionSplit,
+		NewShares:  split.NewShares,
+		OldShares:  split.OldShares,
+		Price:      split.CashPrice,
+		Status:     transaction.CorporateActionPending,
+		Timestamp:  time.Now(),
+	}))
+	if err != nil {
+		return nil, err
+	}
+	result := me.Split(action.GetId(), split.Split, split.CashPrice)
+	if result.Err != nil {
+		if errors.Is(result.Err, ErrNotSuspended) || errors.Is(result.Err, errSplitQuarantined) {
+			// refused before the holdings were touched, so there is nothing to record
+			deleteErr := _transactionDatabaseAccess.CorporateAction().Delete(action.GetId())
+			if deleteErr != nil {
+				println("Error removing refused split: ", action.GetId(), " of stock: ", split.StockID, " ", deleteErr.Error())
+			}
+		} else {
+			println("Split: ", action.GetId(), " of stock: ", split.StockID, " is left pending, its holdings may have been split")
+		}
+		return nil, result.Err
+	}
+
+	splitResult := &network.StockSplitResult{
+		CorporateActionID: action.GetId(),
+		StockID:           split.StockID,
+		Split:             split.Split,
+		CashPrice:         split.CashPrice,
+		Holdings:          result.Holdings,
+		RescaledOrders:    []string{},
+		CancelledOrders:   []string{},
+	}
+	for _, rescaled := range result.Rescaled {
+		err := rescaleOrderRecords(rescaled)
+		if err != nil {
+			println("Error updating order: ", rescaled.Order.GetId(), " split from stock: ", split.StockID, " ", err.Error())
+			splitResult.FailedOrders = append(splitResult.FailedOrders, rescaled.Order.GetId())
+			continue
+		}
+		splitResult.RescaledOrders = append(splitResult.RescaledOrders, rescaled.Order.GetId())
+	}
+	for _, stockOrder := range result.Cancelled {
+		// its escrowed shares were split with the seller's holding, there is nothing to release
+		_, err := _networkHttpManager.Transactions().Put("cancelStockTransaction/"+stockOrder.GetId(), nil)
+		if err == nil {
+			err = _databaseManager.Delete(stockOrder.GetId())
+		}
+		if err != nil {
+			println("Error cancelling order: ", stockOrder.GetId(), " split from stock: ", split.StockID, " ", err.Error())
+			splitResult.FailedOrders = append(splitResult.FailedOrders, stockOrder.GetId())
+			continue
+		}
+		splitResult.CancelledOrders = append(splitResult.CancelledOrders, stockOrder.GetId())
+	}
+
+	err = recordSplit(action, splitResult)
+	if err != nil {
+		return nil, fmt.Errorf("stock %s was split, but split %s couldn't be recorded: %w", split.StockID, action.GetId(), err)
+	}
+	return splitResult, nil
+}
+
+// Keeps the order's record and its transaction in step with the order, like an amend does.
+func rescaleOrderRecords(rescaled RescaledOrder) error {
+	err := _databaseManager.Update(rescaled.Order)
+	if err != nil {
+		return err
+	}
+	stockTransaction, err := _transactionDatabaseAccess.StockTransaction().GetByID(rescaled.Order.GetId())
+	if err != nil {
+		return err
+	}
+	stockTransaction.SetQuantity(stockTransaction.GetQuantity() - rescaled.OldQuantity + rescaled.Order.GetQuantity())
+	if stockTransaction.GetOrderStatus() == "IN_PROGRESS" && stockTransaction.GetOrderType() != order.OrderTypeMarket {
+		stockTransaction.SetStockPrice(rescaled.Order.GetPrice())
+	}
+	return _transactionDatabaseAccess.StockTransaction().Update(stockTransaction)
+}
+
+// Writes a wallet transaction for every holder paid for part of a share, then completes the split's record.
+func recordSplit(action transaction.CorporateActionInterface, splitResult *network.StockSplitResult) error {
+	now := time.Now()
+	var cashPaid money.Money
+	for _, holding := range splitResult.Holdings {
+		cashPaid += holding.Cash
+		if holding.Cash == 0 {
+			continue
+		}
+		_, err := _transactionDatabaseAccess.WalletTransaction().Create(transaction.NewWalletTransaction(transaction.NewWalletTransactionParams{
+			WalletID:          holding.WalletID,
+			UserID:            holding.UserID,
+			Amount:            holding.Cash,
+			Timestamp:         now,
+			Type:              transaction.WalletTransactionCashInLieu,
+			CorporateActionID: action.GetId(),
+		}))
+		if err != nil {
+			return fmt.Errorf("couldn't record %s paid to user %s for part of a share: %w", holding.Cash.String(), holding.UserID, err)
+		}
+	}
+	action.SetStatus(transaction.CorporateActionCompleted)
+	action.SetCashPaid(cashPaid)
+	action.SetHolders(len(splitResult.Holdings))
+	err := _transactionDatabaseAccess.CorporateAction().Update(action)
+	if err != nil {
+		return err
+	}
+	println("Recorded split of stock: ", splitResult.StockID, " Paid: ", cashPaid.String(), " to holders: ", len(splitResult.Holdings))
+	return nil
+}
+
+// Sends the split to the user management database, which rescales every holding at once.
+func SplitHoldings(split *network.UserStockSplit) ([]network.SplitHolding, error) {
+	return _userDatabaseAccess.UserStock().SplitStock(split)
+}
@@ -0,0 +1,97 @@
+package matchingEngine
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/order"
+	"Shared/entities/stock"
+	"Shared/network"
+	"errors"
+	"testing"
+)
+
+func TestSplitRescalesBooksAndEscrow(t *testing.T) {
+	var adjusted *network.UserStockSplit
+	me, _ := newTestEngine(t, &NewMatchingEngineParams{
+		AdjustHoldingsFunc: func(split *network.UserStockSplit) ([]network.SplitHolding, error) {
+			adjusted = split
+			return nil, nil
+		},
+	})
+	addOrders(t, me,
+		testOrder("sell-bob", "bob", false, 3, 1500),
+		testOrder("sell-carol", "carol", false, 1, 1500),
+		testOrder("buy-dave", "dave", true, 4, 900),
+	)
+	me.Halt(HaltReasonSuspended)
+	// still queued when the split is asked for, it has to be split with the rest
+	err := me.AddOrder(order.New(order.NewStockOrderParams{
+		NewEntityParams: entity.NewEntityParams{ID: "stop-erin"},
+		StockID:         "stock",
+		UserID:          "erin",
+		OrderType:       order.OrderTypeStop,
+		Quantity:        2,
+		StopPrice:       1200,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := me.Split("split-1", stock.Split{NewShares: 3, OldShares: 2}, 1000)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	// 4.5 new shares rounds down to 4, 1.5 to 1
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-bob:4", "sell-carol:1")
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()), "buy-dave:6")
+	expectStrings(t, "stops", bookIDs(me.TriggerBook.GetOrders()), "stop-erin:3")
+	if me.SellOrderBook.GetBestPrice() != 1000 || me.BuyOrderBook.GetBestPrice() != 600 {
+		t.Errorf("got prices %s and %s, wanted 10.00 and 6.00", me.SellOrderBook.GetBestPrice(), me.BuyOrderBook.GetBestPrice())
+	}
+	if me.TriggerBook.GetOrders()[0].GetStopPrice() != 800 {
+		t.Errorf("got stop price %s, wanted 8.00", me.TriggerBook.GetOrders()[0].GetStopPrice())
+	}
+
+	// sellers' shares in escrow are split with their holdings, then what their orders still hold goes back in
+	if adjusted == nil {
+		t.Fatal("holdings weren't adjusted")
+	}
+	if adjusted.CorporateActionID != "split-1" {
+		t.Errorf("got corporate action %q, wanted the split's record so it is only applied once", adjusted.CorporateActionID)
+	}
+	want := map[string][2]int{"bob": {3, 4}, "carol": {1, 1}, "erin": {2, 3}}
+	for userID, escrow := range want {
+		if adjusted.Escrowed[userID] != escrow[0] || adjusted.EscrowedAfter[userID] != escrow[1] {
+			t.Errorf("%s: got %d escrowed before and %d after, wanted %d and %d", userID, adjusted.Escrowed[userID], adjusted.EscrowedAfter[userID], escrow[0], escrow[1])
+		}
+	}
+	if _, ok := adjusted.Escrowed["dave"]; ok {
+		t.Errorf("a buyer has nothing in escrow")
+	}
+}
+
+func TestSplitCancelsOrdersLeftWithLessThanAShare(t *testing.T) {
+	me, _ := newTestEngine(t, &NewMatchingEngineParams{})
+	addOrders(t, me,
+		testOrder("sell-1", "bob", false, 3, 1500),
+		testOrder("sell-2", "carol", false, 12, 1500),
+	)
+	me.Halt(HaltReasonSuspended)
+	result := me.Split("split-1", stock.Split{NewShares: 1, OldShares: 4}, 0)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if len(result.Cancelled) != 1 || result.Cancelled[0].GetId() != "sell-1" {
+		t.Errorf("expected only sell-1 to be cancelled, got %d", len(result.Cancelled))
+	}
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-2:3")
+}
+
+func TestSplitNeedsTheStockSuspended(t *testing.T) {
+	me, _ := newTestEngine(t, &NewMatchingEngineParams{})
+	addOrders(t, me, testOrder("sell-1", "bob", false, 3, 1500))
+	result := me.Split("split-1", stock.Split{NewShares: 2, OldShares: 1}, 0)
+	if !errors.Is(result.Err, ErrNotSuspended) {
+		t.Errorf("got %v, wanted %v", result.Err, ErrNotSuspended)
+	}
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:3")
+}
@@ -0,0 +1,139 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/network"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+var errStockNotListed = errors.New("no matching engine for the stock")
+var errStockQuarantined = errors.New("stock has quarantined matches, release them before delisting it")
+
+// Expected input is a network.StockID. Answers with a network.ReturnJSON holding the network.DelistedStock.
+// Refused with a conflict while the stock has quarantined matches.
+func DelistStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Delisting stock")
+	var stockID network.StockID
+	err := json.Unmarshal(data, &stockID)
+	if err != nil || stockID.StockID == "" {
+		println("Error: expected the stock ID")
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// it has to be stopped, so it can't be held like the other handlers hold it
+	if forwardToOwner(responseWriter, "delistStock", stockID.StockID, data, queryParams, requestType) {
+		return
+	}
+	delisted, err := DelistStock(stockID.StockID)
+	if err != nil {
+		println("Error: ", err.Error())
+		switch {
+		case errors.Is(err, errStockNotListed):
+			responseWriter.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, errStockQuarantined):
+			responseWriter.WriteHeader(http.StatusConflict)
+		default:
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	writeReturnJSON(responseWriter, delisted)
+}
+
+// Stops the stock's engine for good and cancels every order left in its books, giving sellers their escrowed shares back.
+// Buy orders hold no funds until they trade, so there is nothing to give back for them.
+// Call for a stock this instance has, without holding it.
+func DelistStock(stockID string) (*network.DelistedStock, error) {
+	_stocksMutex.Lock()
+	me, ok := _matchingEngineMap[stockID]
+	if !ok {
+		_stocksMutex.Unlock()
+		return nil, errStockNotListed
+	}
+	if len(me.GetQuarantinedMatches()) > 0 {
+		_stocksMutex.Unlock()
+		return nil, errStockQuarantined
+	}
+	// nothing can get hold of it from here
+	delete(_matchingEngineMap, stockID)
+	_stocksMutex.Unlock()
+	waitForStockUsers(stockID)
+
+	println("Stopping engine of delisted stock: ", stockID)
+	state := me.Stop()
+	if len(state.Quarantined) > 0 {
+		// the executor didn't answer a match made while stopping. The book goes back as it was
+		adoptStock(stockID, state)
+		return nil, errStockQuarantined
+	}
+	unbindStock(stockID)
+	// a restart doesn't load a delisted stock, but a snapshot left behind would be loaded if it was ever listed again
+	if os.Getenv("SNAPSHOT_DIR") != "" {
+		err := os.Remove(matchingEngineStructures.SnapshotPath(os.Getenv("SNAPSHOT_DIR"), stockID))
+		if err != nil && !os.IsNotExist(err) {
+			println("Error removing snapshot of stock: ", stockID, " ", err.Error())
+		}
+	}
+
+	delisted := &network.DelistedStock{StockID: stockID, CancelledOrders: []string{}}
+	for _, stockOrder := range state.Orders() {
+		err := CancelUnfilledOrder(stockOrder)
+		if err == nil {
+			err = _databaseManager.Delete(stockOrder.GetId())
+		}
+		if err != nil {
+			println("Error cancelling order: ", stockOrder.GetId(), " of delisted stock: ", stockID, " ", err.Error())
+			delisted.FailedOrders = append(delisted.FailedOrders, stockOrder.GetId())
+			continue
+		}
+		delisted.CancelledOrders = append(delisted.CancelledOrders, stockOrder.GetId())
+	}
+	println("Delisted stock: ", stockID, " Cancelled orders: ", len(delisted.CancelledOrders))
+	return delisted, nil
+}
+
+// Expected input is a network.StockID. Halts the stock until it is reinstated, keeping its orders. Answers with its network.HaltStatus.
+func SuspendStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Suspending stock")
+	me, ok := engineFor(responseWriter, data)
+	if !ok {
+		return
+	}
+	me.Halt(HaltReasonSuspended)
+	writeReturnJSON(responseWriter, me.GetHaltStatus())
+}
+
+// Expected input is a network.StockID. Ends a suspension, trading carries on. Answers with the stock's network.HaltStatus.
+func ReinstateStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Reinstating stock")
+	me, ok := engineFor(responseWriter, data)
+	if !ok {
+		return
+	}
+	if me.GetHaltStatus().Reason == HaltReasonSuspended {
+		me.Resume()
+	}
+	writeReturnJSON(responseWriter, me.GetHaltStatus())
+}
+
+// The engine of the stock in a network.StockID. Writes the error status if there isn't one.
+func engineFor(responseWriter network.ResponseWriter, data []byte) (MatchingEngineInterface, bool) {
+	var stockID network.StockID
+	err := json.Unmarshal(data, &stockID)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	me, ok := stockEngine(stockID.StockID)
+	if !ok {
+		println("Error: Matching engine not found for ID: ", stockID.StockID)
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	return me, true
+}
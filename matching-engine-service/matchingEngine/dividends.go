@@ -0,0 +1,208 @@
+package matchingEngine
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/transaction"
+	"Shared/network"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const dividendRetryInterval = time.Minute
+
+var errInvalidDividend = errors.New("a dividend needs an amount per share above 0")
+
+var _dividendsMutex = &sync.Mutex{}
+var _scheduledDividends = map[string]bool{} // corporate action IDs waiting on a timer in this instance
+
+// Expected input is a network.DividendDeclaration. Answers with a network.ReturnJSON holding the network.DividendResult,
+// already paid if the record date has passed.
+func DeclareDividendHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Declaring dividend")
+	var declaration network.DividendDeclaration
+	err := json.Unmarshal(data, &declaration)
+	if err == nil && declaration.Amount <= 0 {
+		err = errInvalidDividend
+	}
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	result, err := DeclareDividend(declaration)
+	if err != nil {
+		println("Error: ", err.Error())
+		if errors.Is(err, errStockNotListed) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	writeReturnJSON(responseWriter, result)
+}
+
+// Records the dividend, then pays it straight away if the record date has passed, or once it does.
+// Call holding the stock.
+func DeclareDividend(declaration network.DividendDeclaration) (*network.DividendResult, error) {
+	if _, ok := stockEngine(declaration.StockID); !ok {
+		return nil, errStockNotListed
+	}
+	now := time.Now()
+	if declaration.RecordDate.IsZero() {
+		declaration.RecordDate = now
+	}
+	action, err := _transactionDatabaseAccess.CorporateAction().Create(transaction.NewCorporateAction(transaction.NewCorporateActionParams{
+		StockID:    declaration.StockID,
+		ActionType: transaction.CorporateActionDividend,
+		Price:      declaration.Amount,
+		RecordDate: declaration.RecordDate,
+		Status:     transaction.CorporateActionPending,
+		Timestamp:  now,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	println("Declared dividend of stock: ", declaration.StockID, " Per share: ", declaration.Amount.String(), " Record date: ", declaration.RecordDate.String())
+	if declaration.RecordDate.After(now) {
+		scheduleDividend(action, declaration.RecordDate.Sub(now))
+		return dividendResult(action, nil), nil
+	}
+	payments, err := payDividend(action)
+	if err != nil {
+		return nil, err
+	}
+	return dividendResult(action, payments), nil
+}
+
+// Pays the dividend after delay, if this instance still has the stock then. Otherwise the instance that has it pays it.
+func scheduleDividend(action transaction.CorporateActionInterface, delay time.Duration) {
+	_dividendsMutex.Lock()
+	defer _dividendsMutex.Unlock()
+	if _scheduledDividends[action.GetId()] {
+		return
+	}
+	_scheduledDividends[action.GetId()] = true
+	time.AfterFunc(delay, func() {
+		_dividendsMutex.Lock()
+		delete(_scheduledDividends, action.GetId())
+		_dividendsMutex.Unlock()
+		// held so the stock can't be handed off part way through
+		_, ok := acquireStock(action.GetStockID())
+		if !ok {
+			println("Stock: ", action.GetStockID(), " moved before dividend: ", action.GetId(), " was paid")
+			return
+		}
+		defer releaseStock(action.GetStockID())
+		// read again, it may have been paid while this waited
+		current, err := _transactionDatabaseAccess.CorporateAction().GetByID(action.GetId())
+		if err == nil && current.GetStatus() != transaction.CorporateActionPending {
+			return
+		}
+		if err == nil {
+			_, err = payDividend(current)
+		}
+		if err != nil {
+			println("Error paying dividend: ", action.GetId(), " ", err.Error(), ". Trying again in ", dividendRetryInterval.String())
+			scheduleDividend(action, dividendRetryInterval)
+		}
+	})
+}
+
+// Picks up the stock's dividends declared before this instance had it.
+func resumeDividends(stockID string) {
+	actions, err := _transactionDatabaseAccess.CorporateAction().GetByForeignID("stock_id", stockID)
+	if err != nil {
+		println("Error reading corporate actions of stock: ", stockID, " ", err.Error())
+		return
+	}
+	for _, action := range *actions {
+		if action.GetActionType() == transaction.CorporateActionDividend && action.GetStatus() == transaction.CorporateActionPending {
+			scheduleDividend(action, time.Until(action.GetRecordDate()))
+		}
+	}
+}
+
+// Pays everyone holding the stock now, shares resting in sell orders included since the seller still owns them.
+// Then writes every holder a wallet transaction for it and marks the dividend paid. Safe to run again after an error:
+// the user database pays a dividend once, and holders that already have a wallet transaction for it don't get another.
+// Runs one corporate action at a time, so a split can't change the holdings while they are paid.
+func payDividend(action transaction.CorporateActionInterface) ([]network.DividendPayment, error) {
+	_corporateActionsMutex.Lock()
+	defer _corporateActionsMutex.Unlock()
+	stockOrders := _databaseManager.GetInitialStockOrdersForStock(action.GetStockID())
+	if stockOrders == nil {
+		return nil, fmt.Errorf("couldn't read the resting orders of stock %s", action.GetStockID())
+	}
+	escrowed := map[string]int{}
+	for _, stockOrder := range *stockOrders {
+		if !stockOrder.GetIsBuy() {
+			escrowed[stockOrder.GetUserID()] += stockOrder.GetQuantity()
+		}
+	}
+	payments, err := _userDatabaseAccess.UserStock().PayDividend(&network.DividendPayout{
+		CorporateActionID: action.GetId(),
+		StockID:           action.GetStockID(),
+		Amount:            action.GetPrice(),
+		Escrowed:          escrowed,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := map[string]bool{}
+	walletTransactions, err := _transactionDatabaseAccess.WalletTransaction().GetByForeignID("corporate_action_id", action.GetId())
+	if err != nil {
+		return nil, err
+	}
+	for _, walletTransaction := range *walletTransactions {
+		recorded[walletTransaction.GetUserID()] = true
+	}
+	now := time.Now()
+	var cashPaid money.Money
+	for _, payment := range payments {
+		cashPaid += payment.Cash
+		if recorded[payment.UserID] {
+			continue
+		}
+		_, err := _transactionDatabaseAccess.WalletTransaction().Create(transaction.NewWalletTransaction(transaction.NewWalletTransactionParams{
+			WalletID:          payment.WalletID,
+			UserID:            payment.UserID,
+			Amount:            payment.Cash,
+			Timestamp:         now,
+			Type:              transaction.WalletTransactionDividend,
+			CorporateActionID: action.GetId(),
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't record dividend of %s paid to user %s: %w", payment.Cash.String(), payment.UserID, err)
+		}
+	}
+	action.SetStatus(transaction.CorporateActionCompleted)
+	action.SetCashPaid(cashPaid)
+	action.SetHolders(len(payments))
+	err = _transactionDatabaseAccess.CorporateAction().Update(action)
+	if err != nil {
+		return nil, err
+	}
+	println("Paid dividend of stock: ", action.GetStockID(), " Paid: ", cashPaid.String(), " to holders: ", len(payments))
+	return payments, nil
+}
+
+func dividendResult(action transaction.CorporateActionInterface, payments []network.DividendPayment) *network.DividendResult {
+	if payments == nil {
+		payments = []network.DividendPayment{}
+	}
+	return &network.DividendResult{
+		CorporateActionID: action.GetId(),
+		StockID:           action.GetStockID(),
+		Amount:            action.GetPrice(),
+		RecordDate:        action.GetRecordDate(),
+		Status:            action.GetStatus(),
+		Payments:          payments,
+	}
+}
@@ -0,0 +1,18 @@
+package matchingEngine
+
+import (
+	"Shared/entities/order"
+)
+
+// Once an iceberg's visible slice has traded, the next slice is shown from the hidden reserve.
+// The new slice loses its time priority, so the caller has to move the order to the back of its price level.
+// Returns false if there was nothing to refresh.
+func (me *MatchingEngine) refreshIceberg(stockOrder order.StockOrderInterface) bool {
+	if !stockOrder.IsIceberg() || stockOrder.GetQuantity() == 0 || stockOrder.GetVisibleQuantity() > 0 {
+		return false
+	}
+	stockOrder.RefreshDisplay(me.now())
+	println("Showing next slice of iceberg order: ", stockOrder.GetId(), " Visible: ", stockOrder.GetVisibleQuantity(), " of ", stockOrder.GetQuantity())
+	me.saveOrder(stockOrder)
+	return true
+}
@@ -0,0 +1,23 @@
+package matchingEngine
+
+import (
+	"testing"
+)
+
+func TestIcebergSliceGoesToTheBackOfItsLevel(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	iceberg := testOrder("sell-ice", "alice", false, 10, 1000)
+	iceberg.DisplayQuantity = 3
+	iceberg.VisibleQuantity = 3
+	addOrders(t, me,
+		iceberg,
+		testOrder("sell-2", "bob", false, 5, 1000),
+		testOrder("buy-1", "carol", true, 4, 1000),
+	)
+	// only the visible 3 trade ahead of sell-2, the next slice waits behind it
+	expectStrings(t, "matches", executor.matched(), "buy-1/sell-ice 3@10.00", "buy-1/sell-2 1@10.00")
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-2:4", "sell-ice:7")
+	if iceberg.GetVisibleQuantity() != 3 {
+		t.Errorf("got %d shown, wanted a new slice of 3", iceberg.GetVisibleQuantity())
+	}
+}
@@ -0,0 +1,50 @@
+package matchingEngine
+
+import (
+	"Shared/entities/order"
+	"Shared/network"
+	"errors"
+)
+
+const defaultIntakeQueueSize = 1000
+
+var ErrEngineBusy = errors.New("matching engine is busy, its intake queue is full. Try again shortly")
+
+// Orders waiting for the matching loop. Resting ones are already in their book, stop and immediate ones aren't in any book until the loop has them.
+func (me *MatchingEngine) queued() int {
+	return len(me.orderChannel)
+}
+
+// How full the intake queue is, and how many orders it has turned away since the engine started.
+func (me *MatchingEngine) GetIntake() network.EngineIntake {
+	me.intakeMutex.Lock()
+	defer me.intakeMutex.Unlock()
+	return network.EngineIntake{
+		StockID:  me.StockId,
+		Queued:   me.queued(),
+		Capacity: cap(me.orderChannel),
+		Rejected: me.rejected,
+	}
+}
+
+// Call holding the input mutex, so no other order is added between the check and the send.
+func (me *MatchingEngine) intakeFull() bool {
+	if me.queued() < cap(me.orderChannel) {
+		return false
+	}
+	me.intakeMutex.Lock()
+	me.rejected++
+	me.intakeMutex.Unlock()
+	return true
+}
+
+// Every send to the intake goes through here, holding the input mutex, so the queue can't fill between a check and the send.
+// It never waits for the matching loop, a full queue returns false.
+func (me *MatchingEngine) queueOrder(stockOrder order.StockOrderInterface) bool {
+	select {
+	case me.orderChannel <- stockOrder:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package matchingEngine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFullIntakeTurnsOrdersAway(t *testing.T) {
+	me, _ := newIdleTestEngine(&NewMatchingEngineParams{IntakeQueueSize: 1})
+	err := me.AddOrder(testOrder("sell-1", "alice", false, 3, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing has taken sell-1 off the intake yet
+	err = me.AddOrder(testOrder("sell-2", "bob", false, 3, 1000))
+	if !errors.Is(err, ErrEngineBusy) {
+		t.Fatalf("got %v, wanted %v", err, ErrEngineBusy)
+	}
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:3")
+	intake := me.GetIntake()
+	if intake.Queued != 1 || intake.Capacity != 1 || intake.Rejected != 1 {
+		t.Errorf("got %+v, wanted 1 of 1 queued and 1 rejected", intake)
+	}
+
+	startTestEngine(t, me)
+	me.settle()
+	addOrders(t, me, testOrder("sell-3", "carol", false, 3, 1000))
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:3", "sell-3:3")
+}
+
+func TestAmendWithAFullIntakeDoesNotWaitForTheLoop(t *testing.T) {
+	me, _ := newIdleTestEngine(&NewMatchingEngineParams{IntakeQueueSize: 1})
+	err := me.AddOrder(testOrder("buy-1", "carol", true, 3, 950))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the intake is full and nothing is taking from it, a blocking send would hang here
+	result := me.amendOrder(&AmendParams{OrderID: "buy-1", PriceKey: 950, IsBuy: true, Price: 975})
+	if result.Err != nil || !result.Requeued {
+		t.Fatalf("got %+v, wanted it requeued", result)
+	}
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()), "buy-1:3")
+	intake := me.GetIntake()
+	if intake.Queued != 1 || intake.Rejected != 0 {
+		t.Errorf("got %+v, wanted the amend left for the loop's next pass rather than rejected", intake)
+	}
+}
@@ -0,0 +1,407 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/network"
+	"databaseAccessStockOrder"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Journal writes are logged rather than refused, the database still has the order if the journal disk fails.
+func (me *MatchingEngine) journal(entry matchingEngineStructures.JournalEntry) {
+	if me.Journal == nil {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = me.now()
+	}
+	_, err := me.Journal.Append(entry)
+	if err != nil {
+		println("Error writing to journal for stock: ", me.StockId, " ", err.Error())
+	}
+}
+
+// Records the book the engine starts from. Call before the matching loop starts, or while it is settled.
+func (me *MatchingEngine) journalStart() {
+	if me.Journal == nil {
+		return
+	}
+	me.journal(matchingEngineStructures.JournalEntry{
+		Type:  matchingEngineStructures.JournalStart,
+		Start: me.captureState(),
+	})
+}
+
+// The expiry sweep runs on a timer, which a replay doesn't have, so what it removes is journaled like a cancel.
+func (me *MatchingEngine) journalExpiry(stockOrder order.StockOrderInterface) {
+	me.journal(matchingEngineStructures.JournalEntry{
+		Type:     matchingEngineStructures.JournalRemoveOrder,
+		OrderID:  stockOrder.GetId(),
+		PriceKey: stockOrder.GetPrice(),
+		IsBuy:    stockOrder.GetIsBuy(),
+		Reason:   "EXPIRED",
+	})
+}
+
+// What a replay rebuilt. The orders are sorted by ID, so two replays can be diffed.
+type ReplayResult struct {
+	StockID      string                      `json:"stock_id"`
+	Entries      int                         `json:"entries"`
+	LastSequence uint64                      `json:"last_sequence"`
+	Divergences  []string                    `json:"divergences"` // where the replay didn't do what the journal says production did
+	Halted       bool                        `json:"halted"`
+	InAuction    bool                        `json:"in_auction"`
+	Bids         []network.PriceLevel        `json:"bids"`
+	Asks         []network.PriceLevel        `json:"asks"`
+	BuyOrders    []order.NewStockOrderParams `json:"buy_orders"`
+	SellOrders   []order.NewStockOrderParams `json:"sell_orders"`
+	StopOrders   []order.NewStockOrderParams `json:"stop_orders"`
+}
+
+type ReplayParams struct {
+	Path          string // one stock's journal file
+	UntilSequence uint64 // stop after this entry. leave 0 for the whole journal
+}
+
+// Rebuilds a stock's book from its journal, offline. The engine runs its own matching loop over the journaled inputs one at a time,
+// letting the book settle after each, and the executor is replaced with the match results the journal recorded.
+// Nothing outside the engine is touched: no database, executor, escrow or market data.
+// A match the engine makes that isn't the next one in the journal is reported as a divergence, which is usually the bug being chased.
+// A journal compacted after a snapshot starts from the snapshot's book, so only what came after it can be replayed.
+func ReplayJournal(params *ReplayParams) (*ReplayResult, error) {
+	entries, err := matchingEngineStructures.ReadJournal(params.Path)
+	if err != nil {
+		return nil, err
+	}
+	if params.UntilSequence > 0 {
+		for i, entry := range entries {
+			if entry.Sequence > params.UntilSequence {
+				entries = entries[:i]
+				break
+			}
+		}
+	}
+	r := newReplay(strings.TrimSuffix(filepath.Base(params.Path), filepath.Ext(params.Path)), entries)
+	for _, entry := range entries {
+		r.apply(entry)
+	}
+	if r.me == nil {
+		r.start(&matchingEngineStructures.BookState{})
+	}
+	result := r.result()
+	result.Entries = len(entries)
+	if len(entries) > 0 {
+		result.LastSequence = entries[len(entries)-1].Sequence
+	}
+	return result, nil
+}
+
+// Replays every journal in the directory.
+func ReplayJournals(directory string, untilSequence uint64) ([]*ReplayResult, error) {
+	paths, err := filepath.Glob(filepath.Join(directory, "*.journal"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	results := make([]*ReplayResult, 0, len(paths))
+	for _, path := range paths {
+		println("Replaying journal: ", path)
+		result, err := ReplayJournal(&ReplayParams{Path: path, UntilSequence: untilSequence})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+type replay struct {
+	stockID     string
+	me          *MatchingEngine
+	matches     []matchingEngineStructures.JournalEntry // the MATCH_RESULT entries the engine hasn't asked for yet
+	clock       time.Time
+	divergences []string
+	mutex       *sync.Mutex              // the clock and matches are shared with the matching loop
+	live        *NewMatchingEngineParams // set when recovering a live engine, which carries on with these once the journal runs out
+	liveOnce    *sync.Once
+}
+
+// Only the MATCH_RESULT entries are kept, for execute to answer with.
+func newReplay(stockID string, entries []matchingEngineStructures.JournalEntry) *replay {
+	r := &replay{
+		stockID:  stockID,
+		mutex:    &sync.Mutex{},
+		liveOnce: &sync.Once{},
+	}
+	for _, entry := range entries {
+		if entry.Type == matchingEngineStructures.JournalMatchResult {
+			r.matches = append(r.matches, entry)
+		}
+	}
+	return r
+}
+
+func (r *replay) now() time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.clock
+}
+
+func (r *replay) setClock(now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if now.After(r.clock) {
+		r.clock = now
+	}
+}
+
+func (r *replay) diverged(format string, args ...any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	divergence := fmt.Sprintf(format, args...)
+	println("Replay diverged: ", divergence)
+	r.divergences = append(r.divergences, divergence)
+}
+
+// Stands in for the executor. Answers with the result production got, as long as the engine asked for the same trade.
+func (r *replay) execute(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, stockPrice money.Money, matchID string) (network.ExecutorToMatchingEngineJSON, error) {
+	quantity := min(buyOrder.GetQuantity(), sellOrder.GetQuantity())
+	r.mutex.Lock()
+	if len(r.matches) == 0 && r.live != nil {
+		r.mutex.Unlock()
+		// whatever production did after its last journaled match was lost with it, so this match is new
+		r.goLive()
+		return r.me.SendToOrderExection(buyOrder, sellOrder, stockPrice, matchID)
+	}
+	if len(r.matches) == 0 {
+		r.mutex.Unlock()
+		r.diverged("matched %s with %s for %d at %s, but the journal has no more matches", buyOrder.GetId(), sellOrder.GetId(), quantity, stockPrice.String())
+		return network.ExecutorToMatchingEngineJSON{}, nil
+	}
+	next := r.matches[0]
+	r.matches = r.matches[1:]
+	r.mutex.Unlock()
+	r.setClock(next.Timestamp)
+	if next.BuyOrderID != buyOrder.GetId() || next.SellOrderID != sellOrder.GetId() || next.Quantity != quantity || next.Price != stockPrice {
+		r.diverged("sequence %d: journal matched %s with %s for %d at %s, replay matched %s with %s for %d at %s",
+			next.Sequence, next.BuyOrderID, next.SellOrderID, next.Quantity, next.Price.String(),
+			buyOrder.GetId(), sellOrder.GetId(), quantity, stockPrice.String())
+		return network.ExecutorToMatchingEngineJSON{}, nil
+	}
+	if next.Reason != "" {
+		// production's executor didn't answer, so the match was quarantined
+		return network.ExecutorToMatchingEngineJSON{}, errors.New(next.Reason)
+	}
+	if next.Result == nil {
+		return network.ExecutorToMatchingEngineJSON{}, nil
+	}
+	return *next.Result, nil
+}
+
+// Starts the engine over from a START entry, the way a restarted service builds it.
+// The old engine's loop is left waiting, it has nothing more to match.
+func (r *replay) start(state *matchingEngineStructures.BookState) {
+	r.me = r.build(state, &NewMatchingEngineParams{
+		StockID:             r.stockID,
+		SelfTradePrevention: state.SelfTradePrevention,
+		AllocationPolicy:    state.AllocationPolicy,
+		PriceBandPercent:    state.PriceBandPercent,
+		PriceBandWindow:     state.PriceBandWindow,
+	})
+	// the updates, trades and expiry loops aren't run, their inputs are applied straight from the journal
+	go r.me.RunMatchingEngineOrders()
+	r.me.settle()
+}
+
+// An engine holding the state's orders in the state's queue order, which touches nothing outside itself.
+func (r *replay) build(state *matchingEngineStructures.BookState, params *NewMatchingEngineParams) *MatchingEngine {
+	orders := state.Orders()
+	noop := func(stockOrder order.StockOrderInterface) error { return nil }
+	params.InitalOrders = &orders
+	params.InQueueOrder = true
+	params.SendToOrderExecutionFunc = r.execute
+	params.SendBatchToOrderExecutionFunc = nil
+	// production may have matched ahead of a batch that failed, which can't be told from the journal, so replay matches one at a time
+	params.SynchronousSettlement = true
+	params.CancelUnfilledOrderFunc = noop
+	params.AdjustEscrowFunc = func(stockOrder order.StockOrderInterface, delta int) error { return nil }
+	params.ReduceUnfilledOrderFunc = func(stockOrder order.StockOrderInterface, quantity int) error { return nil }
+	params.AdjustHoldingsFunc = nil
+	params.RecordTradeFunc = nil
+	params.MarketDataHub = nil
+	params.Journal = nil
+	params.DatabaseManager = &replayDatabase{}
+	me := newMatchingEngine(params)
+	me.clock = r.now
+	me.restoreState(state)
+	return me
+}
+
+// Hands a recovering engine back its real executor, database, escrow and market data, and the wall clock.
+// The journal is left off until the recovery is finished, so nothing replayed is journaled twice.
+func (r *replay) goLive() {
+	if r.live == nil {
+		return
+	}
+	r.liveOnce.Do(func() {
+		println("Recovered stock: ", r.stockID, " is live")
+		me := r.me
+		me.SendToOrderExection = r.live.SendToOrderExecutionFunc
+		me.SendBatchToOrderExecution = r.live.SendBatchToOrderExecutionFunc
+		me.SynchronousSettlement = r.live.SynchronousSettlement
+		me.CancelUnfilledOrder = r.live.CancelUnfilledOrderFunc
+		me.AdjustEscrow = r.live.AdjustEscrowFunc
+		me.ReduceUnfilledOrder = r.live.ReduceUnfilledOrderFunc
+		me.AdjustHoldings = r.live.AdjustHoldingsFunc
+		me.RecordTrade = r.live.RecordTradeFunc
+		if r.live.MarketDataHub != nil {
+			me.MarketData = r.live.MarketDataHub
+		}
+		me.DatabaseManager = r.live.DatabaseManager
+		me.clock = nil
+	})
+}
+
+func (r *replay) apply(entry matchingEngineStructures.JournalEntry) {
+	r.setClock(entry.Timestamp)
+	if entry.Type == matchingEngineStructures.JournalStart {
+		r.start(entry.Start)
+		return
+	}
+	if r.me == nil {
+		// journaling was switched on while the engine was already running
+		r.start(&matchingEngineStructures.BookState{})
+	}
+	me := r.me
+	switch entry.Type {
+	case matchingEngineStructures.JournalAddOrder:
+		err := me.AddOrder(order.New(*entry.Order))
+		if err != nil {
+			// the engine settles after every entry, so the queue is never full
+			r.diverged("sequence %d: add of %s failed: %s", entry.Sequence, entry.Order.ID, err.Error())
+		}
+	case matchingEngineStructures.JournalRemoveOrder:
+		me.removeOrder(entry.OrderID, entry.PriceKey, entry.IsBuy)
+	case matchingEngineStructures.JournalAmendOrder:
+		result := me.amendOrder(&AmendParams{
+			OrderID:  entry.OrderID,
+			PriceKey: entry.PriceKey,
+			IsBuy:    entry.IsBuy,
+			Quantity: entry.Quantity,
+			Price:    entry.Price,
+		})
+		if result.Err != nil {
+			r.diverged("sequence %d: amend of %s failed: %s", entry.Sequence, entry.OrderID, result.Err.Error())
+		}
+	case matchingEngineStructures.JournalHalt:
+		me.Halt(entry.Reason)
+	case matchingEngineStructures.JournalResume:
+		me.Resume()
+	case matchingEngineStructures.JournalStartAuction:
+		me.StartAuction()
+	case matchingEngineStructures.JournalUncross:
+		result, err := me.Uncross()
+		if err != nil {
+			r.diverged("sequence %d: uncross failed: %s", entry.Sequence, err.Error())
+		} else if result.ClearingPrice != entry.Price || result.Volume != entry.Quantity {
+			r.diverged("sequence %d: journal uncrossed %d at %s, replay uncrossed %d at %s",
+				entry.Sequence, entry.Quantity, entry.Price.String(), result.Volume, result.ClearingPrice.String())
+		}
+	case matchingEngineStructures.JournalResolveMatch:
+		r.resolve(entry)
+	case matchingEngineStructures.JournalSplit:
+		// replay has no holdings to adjust, so the split needs no record
+		result := me.Split("", *entry.Split, 0)
+		if result.Err != nil {
+			r.diverged("sequence %d: split failed: %s", entry.Sequence, result.Err.Error())
+		}
+	case matchingEngineStructures.JournalMatchResult:
+		// used by execute, when the engine asks for the trade
+		return
+	default:
+		r.diverged("sequence %d: unknown entry type %s", entry.Sequence, entry.Type)
+		return
+	}
+	me.settle()
+}
+
+// Match IDs are new to every engine, so the quarantined match is found by its orders.
+func (r *replay) resolve(entry matchingEngineStructures.JournalEntry) {
+	for _, match := range r.me.Quarantine.GetMatches() {
+		if match.BuyOrder.ID != entry.BuyOrderID || match.SellOrder.ID != entry.SellOrderID {
+			continue
+		}
+		resolution := &quarantineResolution{matchID: match.ID, action: entry.Reason}
+		if entry.Result != nil {
+			resolution.result = *entry.Result
+		}
+		r.me.resolve(resolution)
+		return
+	}
+	r.diverged("sequence %d: journal resolved the match of %s with %s, replay never quarantined it", entry.Sequence, entry.BuyOrderID, entry.SellOrderID)
+}
+
+func (r *replay) result() *ReplayResult {
+	me := r.me
+	me.settle()
+	bids, asks := me.GetDepth(0)
+	haltStatus := me.GetHaltStatus()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, unused := range r.matches {
+		r.divergences = append(r.divergences, fmt.Sprintf("sequence %d: journal matched %s with %s, replay never did", unused.Sequence, unused.BuyOrderID, unused.SellOrderID))
+	}
+	return &ReplayResult{
+		StockID:     r.stockID,
+		Divergences: r.divergences,
+		Halted:      haltStatus.Halted,
+		InAuction:   haltStatus.InAuction,
+		Bids:        toPriceLevelJSON(bids),
+		Asks:        toPriceLevelJSON(asks),
+		BuyOrders:   sortedOrderParams(me.BuyOrderBook.GetOrders()),
+		SellOrders:  sortedOrderParams(me.SellOrderBook.GetOrders()),
+		StopOrders:  sortedOrderParams(me.TriggerBook.GetOrders()),
+	}
+}
+
+func sortedOrderParams(orders []order.StockOrderInterface) []order.NewStockOrderParams {
+	sort.Slice(orders, func(i, j int) bool { return orders[i].GetId() < orders[j].GetId() })
+	return orderParams(orders)
+}
+
+// Stands in for the stock order database during a replay. Writes go nowhere and there is nothing to read.
+type replayDatabase struct {
+	databaseAccessStockOrder.DatabaseAccessInterface
+}
+
+func (d *replayDatabase) Create(stockOrder order.StockOrderInterface) (order.StockOrderInterface, error) {
+	return stockOrder, nil
+}
+func (d *replayDatabase) Update(stockOrder order.StockOrderInterface) error { return nil }
+func (d *replayDatabase) Delete(id string) error                            { return nil }
+
+// Opens the stock's journal in JOURNAL_DIR, or gives nil if journaling is off.
+func openJournal(stockID string) matchingEngineStructures.JournalInterface {
+	directory := os.Getenv("JOURNAL_DIR")
+	if directory == "" {
+		return nil
+	}
+	journal, err := matchingEngineStructures.NewJournal(&matchingEngineStructures.NewJournalParams{
+		Directory: directory,
+		StockID:   stockID,
+	})
+	if err != nil {
+		println("Error opening journal for stock: ", stockID, " ", err.Error(), ". Not journaling it")
+		return nil
+	}
+	return journal
+}
@@ -0,0 +1,188 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	networkHttp "Shared/network/http"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Comment lines sent while idle, so proxies don't close the stream
+const streamKeepAliveInterval = 15 * time.Second
+
+// Streams need the raw http.ResponseWriter to flush, so they are registered directly rather than through the network manager.
+// Browsers can't set headers on an EventSource, so the token may also be passed as a query param. It is still checked by TokenAuthMiddleware.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("token") == "" && r.URL.Query().Get("token") != "" {
+			r.Header.Set("token", r.URL.Query().Get("token"))
+		}
+		networkHttp.TokenAuthMiddleware(next).ServeHTTP(w, r)
+	})
+}
+
+// Server-sent events. Query params: stock_id, once for each stock to follow.
+// Sends book_top and trade events for those stocks, plus order_status events for the logged in user's own orders.
+// The current book top of every stock is sent as soon as the stream opens.
+// Events are only published on the instance that has the stock, so the stream is also opened on every other instance,
+// for the stocks it has and the user's orders there, and what they send is passed on. If one of those ends, so does this stream
+// and the client reconnects.
+func StreamMarketDataHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	userID, _ := networkHttp.UserIDFromContext(r.Context())
+	stockIDs := r.URL.Query()["stock_id"]
+	forwarded := r.URL.Query().Get(forwardedParam) != ""
+	engines := []MatchingEngineInterface{}
+	remoteStockIDs := map[string][]string{} // by the instance that has them
+	for _, stockID := range stockIDs {
+		me, ok := acquireStock(stockID)
+		if ok {
+			releaseStock(stockID)
+			engines = append(engines, me)
+			continue
+		}
+		owner := ownerOf(stockID)
+		if forwarded || owner == "" || owner == _instance {
+			println("Error: matching engine not found for ID: ", stockID)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		remoteStockIDs[owner] = append(remoteStockIDs[owner], stockID)
+	}
+
+	upstreams := []*http.Response{}
+	defer func() {
+		for _, upstream := range upstreams {
+			upstream.Body.Close()
+		}
+	}()
+	if !forwarded {
+		instances := otherInstances()
+		for owner := range remoteStockIDs {
+			if !slices.Contains(instances, owner) {
+				instances = append(instances, owner)
+			}
+		}
+		for _, instance := range instances {
+			upstream, err := openUpstream(r, instance, remoteStockIDs[instance])
+			if err != nil {
+				println("Error streaming market data from instance: ", instance, " ", err.Error())
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			upstreams = append(upstreams, upstream)
+		}
+	}
+
+	subscription := _marketDataHub.Subscribe(stockIDs, userID)
+	defer _marketDataHub.Unsubscribe(subscription)
+	println("Streaming market data to user ", userID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // stops nginx holding events back
+	w.WriteHeader(http.StatusOK)
+	for _, me := range engines {
+		writeStreamEvent(w, matchingEngineStructures.EventBookTop, me.GetBookTop())
+	}
+	flusher.Flush()
+
+	relayed := make(chan string)
+	ended := make(chan error, len(upstreams))
+	for _, upstream := range upstreams {
+		go relayEvents(r.Context(), upstream.Body, relayed, ended)
+	}
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			println("Market data stream closed for user ", userID)
+			return
+		case event := <-subscription.Events:
+			writeStreamEvent(w, event.Type, event.Data)
+		case event := <-relayed:
+			fmt.Fprint(w, event)
+		case err := <-ended:
+			println("Market data stream from another instance ended for user ", userID, ": ", err.Error())
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// Opens the stream on another instance for the stocks it has, and the user's orders there.
+// Every instance listens on the same PORT, with its instance name as its host name.
+func openUpstream(r *http.Request, instance string, stockIDs []string) (*http.Response, error) {
+	query := url.Values{"stock_id": stockIDs}
+	query.Set(forwardedParam, "true")
+	streamURL := url.URL{Scheme: "http", Host: instance + ":" + os.Getenv("PORT"), Path: r.URL.Path, RawQuery: query.Encode()}
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodGet, streamURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("token", r.Header.Get("token"))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, errStatus(response.StatusCode)
+	}
+	return response, nil
+}
+
+// Passes on each event another instance's stream sends, as it was written, until the stream ends.
+func relayEvents(ctx context.Context, stream io.Reader, relayed chan<- string, ended chan<- error) {
+	reader := bufio.NewReader(stream)
+	event := ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			ended <- err
+			return
+		}
+		// its keep-alives, this stream sends its own
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		event += line
+		if line != "\n" {
+			continue
+		}
+		if event != "\n" {
+			select {
+			case relayed <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		event = ""
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, eventType string, data any) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		println("Error: ", err.Error())
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, dataJSON)
+}
@@ -0,0 +1,22 @@
+package matchingEngine
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRelayEventsPassesEventsOnWithoutKeepAlives(t *testing.T) {
+	stream := "event: book_top\ndata: {\"stock_id\":\"a\"}\n\n: keep-alive\n\nevent: order_status\ndata: {}\n\n"
+	relayed := make(chan string)
+	ended := make(chan error, 1)
+	go relayEvents(context.Background(), strings.NewReader(stream), relayed, ended)
+	expectStrings(t, "events", []string{<-relayed, <-relayed},
+		"event: book_top\ndata: {\"stock_id\":\"a\"}\n\n",
+		"event: order_status\ndata: {}\n\n",
+	)
+	if err := <-ended; err != io.EOF {
+		t.Errorf("got %v once the stream ended, wanted EOF", err)
+	}
+}
@@ -1,16 +1,26 @@
 package matchingEngine
 
 import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
 	"Shared/entities/order"
+	"Shared/entities/stock"
+	userStock "Shared/entities/user-stock"
 	"Shared/network"
 	"databaseAccessStock"
 	"databaseAccessStockOrder"
+	"databaseAccessTransaction"
+	"databaseAccessUserManagement"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -20,26 +30,61 @@ var _databaseManager databaseAccessStockOrder.DatabaseAccessInterface
 var _networkHttpManager network.NetworkInterface
 var _networkQueueManager network.NetworkInterface
 var _stockDatabaseAccess databaseAccessStock.DatabaseAccessInterface
+var _userDatabaseAccess databaseAccessUserManagement.DatabaseAccessInterface
+var _transactionDatabaseAccess databaseAccessTransaction.DatabaseAccessInterface
+var _marketDataHub matchingEngineStructures.MarketDataHubInterface
 
 func InitalizeHandlers(stockIDs *[]string,
-	networkHttpManager network.NetworkInterface, networkQueueManager network.NetworkInterface, databaseManager databaseAccessStockOrder.DatabaseAccessInterface, stockDatabaseAccess databaseAccessStock.DatabaseAccessInterface) {
+	networkHttpManager network.NetworkInterface, networkQueueManager network.NetworkInterface, databaseManager databaseAccessStockOrder.DatabaseAccessInterface, stockDatabaseAccess databaseAccessStock.DatabaseAccessInterface, userDatabaseAccess databaseAccessUserManagement.DatabaseAccessInterface, transactionDatabaseAccess databaseAccessTransaction.DatabaseAccessInterface) {
 	_databaseManager = databaseManager
 	_networkHttpManager = networkHttpManager
 	_networkQueueManager = networkQueueManager
 	_stockDatabaseAccess = stockDatabaseAccess
+	_userDatabaseAccess = userDatabaseAccess
+	_transactionDatabaseAccess = transactionDatabaseAccess
 	_matchingEngineMap = make(map[string]MatchingEngineInterface)
-	//Create all matching engines for stocks.
-	for _, stockID := range *stockIDs {
-		AddNewStock(stockID)
-	}
+	_marketDataHub = matchingEngineStructures.NewMarketDataHub(&matchingEngineStructures.NewMarketDataHubParams{})
+	initSharding(networkQueueManager)
 
-	//Add handlers
-	_networkHttpManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "createStock", Handler: AddNewStockHandler})
-	_networkQueueManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "placeStockOrder", Handler: PlaceStockOrderHandler})
-	_networkQueueManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "deleteOrder/", Handler: DeleteStockOrderHandler})
-	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("transaction_route") + "/getStockPrices", Handler: GetStockPricesHandler})
+	//Add handlers. Ones about a single stock run on the instance that owns it
+	_networkHttpManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "createStock", Handler: forwardable("createStock", AddNewStockHandler)})
+	_networkHttpManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "delistStock", Handler: forwardable("delistStock", DelistStockHandler)})
+	_networkHttpManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "suspendStock", Handler: routedByStock("suspendStock", stockIDInBody, SuspendStockHandler)})
+	_networkHttpManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "reinstateStock", Handler: routedByStock("reinstateStock", stockIDInBody, ReinstateStockHandler)})
+	_networkQueueManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "placeStockOrder", Handler: forwardable("placeStockOrder", PlaceStockOrderHandler)})
+	_networkQueueManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "deleteOrder/", Handler: forwardable("deleteOrder/", DeleteStockOrderHandler)})
+	_networkQueueManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "amendOrder", Handler: forwardable("amendOrder", AmendStockOrderHandler)})
+	_networkQueueManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: forwardPattern + _instance, Handler: ForwardHandler})
+	forwardable(adoptStockPattern, AdoptStockHandler)
+	forwardable(handOffStocksPattern, HandOffStocksHandler)
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("transaction_route") + "/getStockPrices", Handler: forwardable("getStockPrices", GetStockPricesHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("transaction_route") + "/getStockOrderBook", Handler: routedByStock("getStockOrderBook", stockIDInQuery, GetStockOrderBookHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("transaction_route") + "/getStockTrades", Handler: routedByStock("getStockTrades", stockIDInQuery, GetStockTradesHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/haltStock", Handler: routedByStock("haltStock", stockIDInBody, HaltStockHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/resumeStock", Handler: routedByStock("resumeStock", stockIDInBody, ResumeStockHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/getStockHaltStatus", Handler: routedByStock("getStockHaltStatus", stockIDInQuery, GetStockHaltStatusHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/startAuction", Handler: routedByStock("startAuction", stockIDInBody, StartAuctionHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/uncrossAuction", Handler: routedByStock("uncrossAuction", stockIDInBody, UncrossAuctionHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/getQuarantinedMatches", Handler: routedByStock("getQuarantinedMatches", stockIDInQuery, GetQuarantinedMatchesHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/releaseQuarantinedMatch", Handler: routedByStock("releaseQuarantinedMatch", stockIDInBody, ReleaseQuarantinedMatchHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/getEngineIntake", Handler: routedByStock("getEngineIntake", stockIDInQuery, GetEngineIntakeHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/splitStock", Handler: routedByStock("splitStock", stockIDInBody, SplitStockHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/declareDividend", Handler: routedByStock("declareDividend", stockIDInBody, DeclareDividendHandler)})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/rebalanceMatchingEngines", Handler: RebalanceMatchingEnginesHandler})
+	http.Handle("/"+os.Getenv("transaction_route")+"/streamMarketData", StreamAuthMiddleware(http.HandlerFunc(StreamMarketDataHandler)))
 	http.HandleFunc("/health", healthHandler)
 	networkQueueManager.Listen()
+
+	//Create the matching engines for the stocks this instance owns. Listening first, so their routes can be bound
+	openingAuction := os.Getenv("OPENING_AUCTION") == "true"
+	for _, stockID := range *stockIDs {
+		if ownsStock(stockID) {
+			addStock(stockID, openingAuction)
+		}
+	}
+	if openingAuction {
+		scheduleOpeningUncross(envDuration("OPENING_AUCTION_DURATION"))
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -62,29 +107,137 @@ func AddNewStockHandler(responseWriter network.ResponseWriter, data []byte, quer
 		responseWriter.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if forwardToOwner(responseWriter, "createStock", stockID.StockID, data, queryParams, requestType) {
+		return
+	}
 	AddNewStock(stockID.StockID)
 	responseWriter.WriteHeader(http.StatusOK)
 }
 
 func AddNewStock(stockID string) {
-	_, ok := _matchingEngineMap[stockID]
+	addStock(stockID, false)
+}
+
+// An engine opening with an auction is in it before its matching loop starts, so nothing from before a restart matches early.
+// The engine is rebuilt from its snapshot and journal when it has them, otherwise from the database.
+func addStock(stockID string, openingAuction bool) {
+	_, ok := stockEngine(stockID)
 	//if we don't have a matching engine for this stock, create one
 	if !ok {
-		stockOrders := _databaseManager.GetInitialStockOrdersForStock(stockID)
-		ordersInterface := make([]order.StockOrderInterface, len(*stockOrders))
-		copy(ordersInterface, *stockOrders)
-		me := NewMatchingEngineForStock(&NewMatchingEngineParams{
-			StockID:                  stockID,
-			InitalOrders:             &ordersInterface,
-			SendToOrderExecutionFunc: SendToOrderExection,
-			DatabaseManager:          _databaseManager,
-		})
-		_matchingEngineMap[stockID] = me
-		go me.RunMatchingEngineOrders()
-		go me.RunMatchingEngineUpdates()
+		params := engineParams(stockID)
+		me, err := RecoverMatchingEngine(params)
+		if err != nil {
+			println("Error recovering stock: ", stockID, " from its snapshot: ", err.Error(), ". Loading it from the database")
+		}
+		if me == nil {
+			stockOrders := _databaseManager.GetInitialStockOrdersForStock(stockID)
+			ordersInterface := make([]order.StockOrderInterface, len(*stockOrders))
+			copy(ordersInterface, *stockOrders)
+			params.InitalOrders = &ordersInterface
+			me = NewMatchingEngineForStock(params)
+			if openingAuction {
+				me.StartAuction()
+			}
+			go me.RunMatchingEngineOrders()
+		} else if openingAuction {
+			// the recovered book has already been matched up to where it left off
+			me.StartAuction()
+		}
+		startStock(stockID, me)
+	}
+}
+
+// Everything a stock's engine is built with but its orders.
+func engineParams(stockID string) *NewMatchingEngineParams {
+	allocationPolicy := stock.AllocationFIFO
+	stockEntity, err := _stockDatabaseAccess.GetByID(stockID)
+	if err != nil {
+		println("Error: ", err.Error(), ". Using FIFO allocation for stock: ", stockID)
+	} else {
+		allocationPolicy = stockEntity.GetAllocationPolicy()
+	}
+	return &NewMatchingEngineParams{
+		StockID:                       stockID,
+		SendToOrderExecutionFunc:      SendToOrderExection,
+		SendBatchToOrderExecutionFunc: SendBatchToOrderExecution,
+		SettlementBatchSize:           envInt("SETTLEMENT_BATCH_SIZE"),
+		SettlementWindow:              envInt("SETTLEMENT_WINDOW"),
+		CancelUnfilledOrderFunc:       CancelUnfilledOrder,
+		AdjustEscrowFunc:              AdjustEscrowedShares,
+		ReduceUnfilledOrderFunc:       ReduceUnfilledOrder,
+		SelfTradePrevention:           os.Getenv("SELF_TRADE_PREVENTION"),
+		AllocationPolicy:              allocationPolicy,
+		PriceBandPercent:              envFloat("PRICE_BAND_PERCENT"),
+		PriceBandWindow:               envDuration("PRICE_BAND_WINDOW"),
+		BreachHaltDuration:            envDuration("PRICE_BAND_HALT_DURATION"),
+		RecordTradeFunc:               RecordTrade,
+		MarketDataHub:                 _marketDataHub,
+		Journal:                       openJournal(stockID),
+		SnapshotDirectory:             os.Getenv("SNAPSHOT_DIR"),
+		SnapshotInterval:              envDuration("SNAPSHOT_INTERVAL"),
+		IntakeQueueSize:               envInt("INTAKE_QUEUE_SIZE"),
+		AdjustHoldingsFunc:            SplitHoldings,
+		DatabaseManager:               _databaseManager,
+	}
+}
+
+// Puts an engine whose matching loop is running into service, and routes the stock's orders here.
+func startStock(stockID string, me MatchingEngineInterface) {
+	_stocksMutex.Lock()
+	_matchingEngineMap[stockID] = me
+	delete(_handoffs, stockID)
+	if _, ok := _stockLocks[stockID]; !ok {
+		_stockLocks[stockID] = &sync.RWMutex{}
+	}
+	_stocksMutex.Unlock()
+	go me.RunMatchingEngineUpdates()
+	go me.RunMatchingEngineTrades()
+	go me.RunMatchingEngineExpiry()
+	go me.RunMatchingEngineSnapshots()
+	go me.RunMatchingEngineQuarantine()
+	bindStock(stockID)
+	go resumeDividends(stockID)
+}
+
+// Variables that aren't set, or don't parse, give 0 so the engine uses its default.
+func envFloat(key string) float64 {
+	if os.Getenv(key) == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		println("Error: invalid ", key, ": ", err.Error())
+		return 0
+	}
+	return value
+}
+
+func envInt(key string) int {
+	if os.Getenv(key) == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		println("Error: invalid ", key, ": ", err.Error())
+		return 0
+	}
+	return value
+}
+
+func envDuration(key string) time.Duration {
+	if os.Getenv(key) == "" {
+		return 0
+	}
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		println("Error: invalid ", key, ": ", err.Error())
+		return 0
 	}
+	return value
 }
 
+// Answers with a network.ReturnJSON holding the stock's network.HaltStatus, so the caller knows if the order is queued behind a halt.
+// When the engine is too busy to take it the order is cancelled and the answer is unsuccessful, holding the reason.
 func PlaceStockOrderHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
 	println("Received stock order")
 	println("Data: ", string(data))
@@ -95,32 +248,386 @@ func PlaceStockOrderHandler(responseWriter network.ResponseWriter, data []byte,
 		responseWriter.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if PlaceStockOrder(stockOrder) {
-		responseWriter.WriteHeader(http.StatusOK)
-	} else {
+	found := withStock(responseWriter, "placeStockOrder", stockOrder.GetStockID(), data, queryParams, requestType, func() {
+		if !firstDelivery(stockOrder.GetStockID(), queryParams) {
+			println("Order already placed: ", stockOrder.GetId())
+			responseWriter.WriteHeader(http.StatusConflict)
+			return
+		}
+		haltStatus, err := PlaceStockOrder(stockOrder)
+		if errors.Is(err, ErrEngineBusy) {
+			writeRejection(responseWriter, err)
+			return
+		}
+		if err != nil {
+			println("Error: ", err.Error())
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeReturnJSON(responseWriter, haltStatus)
+	})
+	if !found {
+		println("Error: Matching engine not found for ID: ", stockOrder.GetStockID())
 		responseWriter.WriteHeader(http.StatusBadRequest)
 	}
 }
 
-func PlaceStockOrder(stockOrder order.StockOrderInterface) bool {
+// Orders for a halted stock are still accepted, they wait in the book until trading resumes.
+// Call holding the stock.
+// A busy engine turns the order away with ErrEngineBusy. Its transaction is cancelled and a sell order's escrow given back, as if it was never placed.
+func PlaceStockOrder(stockOrder order.StockOrderInterface) (network.HaltStatus, error) {
 	println("Placing stock order")
-	if me, ok := _matchingEngineMap[stockOrder.GetStockID()]; ok {
-		createdOrder, err := _databaseManager.Create(stockOrder)
-		if err != nil {
-			println("Error: ", err.Error())
-			return false
+	me, ok := stockEngine(stockOrder.GetStockID())
+	if !ok {
+		return network.HaltStatus{}, fmt.Errorf("matching engine not found for ID: %s", stockOrder.GetStockID())
+	}
+	createdOrder, err := _databaseManager.Create(stockOrder)
+	if err != nil {
+		println("Error: ", err.Error())
+		return network.HaltStatus{}, err
+	}
+	err = me.AddOrder(createdOrder)
+	if err != nil {
+		deleteErr := _databaseManager.Delete(createdOrder.GetId())
+		if deleteErr != nil {
+			println("Error: ", deleteErr.Error())
+		}
+		cancelErr := CancelUnfilledOrder(createdOrder)
+		if cancelErr != nil {
+			println("Error: ", cancelErr.Error())
+		}
+		return network.HaltStatus{}, err
+	}
+	return me.GetHaltStatus(), nil
+}
+
+// Expected input is a network.HaltStock: {"stock_id": <id>, "reason": <optional, defaults to ADMIN>}
+func HaltStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Halting stock")
+	var haltStock network.HaltStock
+	err := json.Unmarshal(data, &haltStock)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	me, ok := stockEngine(haltStock.StockID)
+	if !ok {
+		println("Error: Matching engine not found for ID: ", haltStock.StockID)
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if haltStock.Reason == "" {
+		haltStock.Reason = HaltReasonAdmin
+	}
+	me.Halt(haltStock.Reason)
+	writeReturnJSON(responseWriter, me.GetHaltStatus())
+}
+
+// Expected input is a network.HaltStock: {"stock_id": <id>}
+func ResumeStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Resuming stock")
+	var haltStock network.HaltStock
+	err := json.Unmarshal(data, &haltStock)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	me, ok := stockEngine(haltStock.StockID)
+	if !ok {
+		println("Error: Matching engine not found for ID: ", haltStock.StockID)
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if me.GetHaltStatus().Reason == HaltReasonSuspended {
+		println("Error: stock is suspended, reinstate it instead: ", haltStock.StockID)
+		responseWriter.WriteHeader(http.StatusConflict)
+		return
+	}
+	me.Resume()
+	writeReturnJSON(responseWriter, me.GetHaltStatus())
+}
+
+// Expected query param is stock_id. Leave it out to get every stock.
+func GetStockHaltStatusHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Getting stock halt status")
+	stockID := queryParams.Get("stock_id")
+	if stockID != "" {
+		me, ok := stockEngine(stockID)
+		if !ok {
+			println("Error: Matching engine not found for ID: ", stockID)
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeReturnJSON(responseWriter, me.GetHaltStatus())
+		return
+	}
+	statuses := []network.HaltStatus{}
+	readStocks(func() {
+		for _, me := range _matchingEngineMap {
+			statuses = append(statuses, me.GetHaltStatus())
+		}
+	})
+	statuses = append(statuses, gatherFromInstances[network.HaltStatus]("getStockHaltStatus", queryParams)...)
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StockID < statuses[j].StockID
+	})
+	writeReturnJSON(responseWriter, statuses)
+}
+
+// With OPENING_AUCTION every stock loaded at boot opens with a call auction, so orders left from before maintenance and orders placed since meet at one price.
+// With a duration each auction uncrosses by itself, otherwise they wait for setup/uncrossAuction.
+func scheduleOpeningUncross(duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	readStocks(func() {
+		for stockID := range _matchingEngineMap {
+			time.AfterFunc(duration, func() {
+				// it may have been handed off since
+				me, ok := acquireStock(stockID)
+				if !ok {
+					return
+				}
+				defer releaseStock(stockID)
+				_, err := me.Uncross()
+				if err != nil {
+					println("Error uncrossing opening auction for ", stockID, ": ", err.Error())
+				}
+			})
+		}
+	})
+}
+
+// Expected input is a network.HaltStock: {"stock_id": <id>}
+func StartAuctionHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Starting auction")
+	var haltStock network.HaltStock
+	err := json.Unmarshal(data, &haltStock)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	me, ok := stockEngine(haltStock.StockID)
+	if !ok {
+		println("Error: Matching engine not found for ID: ", haltStock.StockID)
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	me.StartAuction()
+	writeReturnJSON(responseWriter, me.GetHaltStatus())
+}
+
+// Expected input is a network.HaltStock: {"stock_id": <id>}. Answers with the network.AuctionResult.
+func UncrossAuctionHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Uncrossing auction")
+	var haltStock network.HaltStock
+	err := json.Unmarshal(data, &haltStock)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	me, ok := stockEngine(haltStock.StockID)
+	if !ok {
+		println("Error: Matching engine not found for ID: ", haltStock.StockID)
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	result, err := me.Uncross()
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusConflict)
+		return
+	}
+	writeReturnJSON(responseWriter, result)
+}
+
+// Expected query param is stock_id. Leave it out to get every stock's.
+func GetQuarantinedMatchesHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Getting quarantined matches")
+	stockID := queryParams.Get("stock_id")
+	matches := []network.QuarantinedMatch{}
+	if stockID != "" {
+		me, ok := stockEngine(stockID)
+		if !ok {
+			println("Error: Matching engine not found for ID: ", stockID)
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		matches = me.GetQuarantinedMatches()
+	} else {
+		readStocks(func() {
+			for _, me := range _matchingEngineMap {
+				matches = append(matches, me.GetQuarantinedMatches()...)
+			}
+		})
+		matches = append(matches, gatherFromInstances[network.QuarantinedMatch]("getQuarantinedMatches", queryParams)...)
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].QuarantinedAt.Before(matches[j].QuarantinedAt)
+		})
+	}
+	writeReturnJSON(responseWriter, matches)
+}
+
+// Expected query param is stock_id. Leave it out to get every stock's, busiest first.
+func GetEngineIntakeHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Getting engine intake")
+	stockID := queryParams.Get("stock_id")
+	if stockID != "" {
+		me, ok := stockEngine(stockID)
+		if !ok {
+			println("Error: Matching engine not found for ID: ", stockID)
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeReturnJSON(responseWriter, me.GetIntake())
+		return
+	}
+	intakes := []network.EngineIntake{}
+	readStocks(func() {
+		for _, me := range _matchingEngineMap {
+			intakes = append(intakes, me.GetIntake())
+		}
+	})
+	intakes = append(intakes, gatherFromInstances[network.EngineIntake]("getEngineIntake", queryParams)...)
+	sort.Slice(intakes, func(i, j int) bool {
+		if intakes[i].Queued != intakes[j].Queued {
+			return intakes[i].Queued > intakes[j].Queued
+		}
+		return intakes[i].StockID < intakes[j].StockID
+	})
+	writeReturnJSON(responseWriter, intakes)
+}
+
+// Expected input is a network.ReleaseQuarantinedMatch. Answers with the stock's quarantined matches that are left.
+func ReleaseQuarantinedMatchHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Releasing quarantined match")
+	var release network.ReleaseQuarantinedMatch
+	err := json.Unmarshal(data, &release)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	me, ok := stockEngine(release.StockID)
+	if !ok {
+		println("Error: Matching engine not found for ID: ", release.StockID)
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if release.Action == "" {
+		release.Action = QuarantineRetry
+	}
+	err = me.ReleaseQuarantinedMatch(release.MatchID, release.Action)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusConflict)
+		return
+	}
+	writeReturnJSON(responseWriter, me.GetQuarantinedMatches())
+}
+
+func writeReturnJSON(responseWriter network.ResponseWriter, data any) {
+	returnValJSON, err := json.Marshal(network.ReturnJSON{
+		Success: true,
+		Data:    data,
+	})
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+// Expected input is a network.AmendStockOrder. Always answers with a network.ReturnJSON, holding the
+// network.AmendStockOrderResult on success or the reason on failure, since an error status never reaches a queue caller.
+func AmendStockOrderHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Amending stock order")
+	var amend network.AmendStockOrder
+	err := json.Unmarshal(data, &amend)
+	var stockOrder order.StockOrderInterface
+	if err == nil {
+		stockOrder, err = _databaseManager.GetByID(amend.StockTxID)
+	}
+	if err != nil {
+		writeAmendResult(responseWriter, nil, err)
+		return
+	}
+	found := withStock(responseWriter, "amendOrder", stockOrder.GetStockID(), data, queryParams, requestType, func() {
+		if !firstDelivery(stockOrder.GetStockID(), queryParams) {
+			println("Order already amended: ", stockOrder.GetId())
+			responseWriter.WriteHeader(http.StatusConflict)
+			return
 		}
-		me.AddOrder(createdOrder)
-		return true
+		result, err := AmendStockOrder(stockOrder, amend)
+		writeAmendResult(responseWriter, result, err)
+	})
+	if !found {
+		writeAmendResult(responseWriter, nil, fmt.Errorf("matching engine not found for ID: %s", stockOrder.GetStockID()))
 	}
-	println("Error: Matching engine not found for ID: ", stockOrder.GetStockID())
-	return false
+}
+
+// An error status never reaches a queue caller, so a refusal is answered with the reason.
+func writeRejection(responseWriter network.ResponseWriter, err error) {
+	println("Error: ", err.Error())
+	returnValJSON, err := json.Marshal(network.ReturnJSON{
+		Success: false,
+		Data:    err.Error(),
+	})
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+func writeAmendResult(responseWriter network.ResponseWriter, result *network.AmendStockOrderResult, err error) {
+	returnVal := network.ReturnJSON{Success: true, Data: result}
+	if err != nil {
+		println("Error: ", err.Error())
+		returnVal = network.ReturnJSON{Success: false, Data: err.Error()}
+	}
+	returnValJSON, err := json.Marshal(returnVal)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+// Call holding the order's stock.
+func AmendStockOrder(stockOrder order.StockOrderInterface, amend network.AmendStockOrder) (*network.AmendStockOrderResult, error) {
+	me, ok := stockEngine(stockOrder.GetStockID())
+	if !ok {
+		return nil, fmt.Errorf("matching engine not found for ID: %s", stockOrder.GetStockID())
+	}
+	result := me.AmendOrder(&AmendParams{
+		OrderID:  stockOrder.GetId(),
+		PriceKey: stockOrder.GetPrice(),
+		IsBuy:    stockOrder.GetIsBuy(),
+		Quantity: amend.Quantity,
+		Price:    amend.Price,
+	})
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &network.AmendStockOrderResult{
+		StockTxID:     result.Order.GetId(),
+		Quantity:      result.Order.GetQuantity(),
+		Price:         result.Order.GetPrice(),
+		QuantityDelta: result.QuantityDelta,
+		Requeued:      result.Requeued,
+	}, nil
 }
 
 func DeleteStockOrderHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
 	println("Deleting stock order")
 	orderID := queryParams.Get("id")
-	err := DeleteStockOrder(orderID)
+	stockOrder, err := _databaseManager.GetByID(orderID)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -130,37 +637,48 @@ func DeleteStockOrderHandler(responseWriter network.ResponseWriter, data []byte,
 		responseWriter.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	responseWriter.WriteHeader(http.StatusOK)
+	deleteOrder := func() {
+		err := DeleteStockOrder(stockOrder)
+		if err != nil {
+			println("Error: ", err.Error())
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusOK)
+	}
+	// an order for a stock nobody has is in no book, it only needs deleting
+	if !withStock(responseWriter, "deleteOrder/", stockOrder.GetStockID(), data, queryParams, requestType, deleteOrder) {
+		deleteOrder()
+	}
 }
 
-func DeleteStockOrder(orderID string) error {
-	order, err := _databaseManager.GetByID(orderID)
+// Call holding the order's stock.
+func DeleteStockOrder(order order.StockOrderInterface) error {
+	orderID := order.GetId()
+	err := _databaseManager.Delete(orderID)
 	if err != nil {
 		println("Error: ", err.Error())
 		return err
 	}
-	err = _databaseManager.Delete(orderID)
-	if err != nil {
-		println("Error: ", err.Error())
-		return err
-	}
-	me, ok := _matchingEngineMap[order.GetStockID()]
+	me, ok := stockEngine(order.GetStockID())
 	if !ok {
 		println("Error: Matching engine not found for ID: ", order.GetStockID())
 		return nil
 	}
-	me.RemoveOrder(orderID, order.GetPrice())
+	me.RemoveOrder(orderID, order.GetPrice(), order.GetIsBuy())
 	return nil
 }
 
 func GetStockPricesHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
 	println("Getting stock prices")
-	prices, err := GetStockPrices()
+	prices, err := GetStockPrices(queryParams.Get("quote") == "true")
 	if err != nil {
 		println("Error: ", err.Error())
 		responseWriter.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	*prices = append(*prices, gatherFromInstances[network.StockPrice]("getStockPrices", queryParams)...)
+	sortStockPrices(*prices)
 	returnVal := network.ReturnJSON{
 		Success: true,
 		Data:    prices,
@@ -175,7 +693,8 @@ func GetStockPricesHandler(responseWriter network.ResponseWriter, data []byte, q
 	responseWriter.Write(pricesJSON)
 }
 
-func GetStockPrices() (*[]network.StockPrice, error) {
+// includeQuote adds the last trade price and the best bid and ask to each stock.
+func GetStockPrices(includeQuote bool) (*[]network.StockPrice, error) {
 	stocks, err := _stockDatabaseAccess.GetAll()
 	if err != nil {
 		println("Error: ", err.Error())
@@ -187,47 +706,192 @@ func GetStockPrices() (*[]network.StockPrice, error) {
 		stockIDToName[stock.GetId()] = stock.GetName()
 	}
 	//get the prices for each stock
-	prices := make(map[string]float64)
-	for stockID, me := range _matchingEngineMap {
-		prices[stockID] = me.GetPrice()
-	}
+	prices := make(map[string]money.Money)
+	haltStatuses := make(map[string]network.HaltStatus)
+	quotes := make(map[string][3]money.Money)
+	readStocks(func() {
+		for stockID, me := range _matchingEngineMap {
+			prices[stockID] = me.GetPrice()
+			haltStatuses[stockID] = me.GetHaltStatus()
+			quotes[stockID] = [3]money.Money{me.GetLastPrice(), me.GetBidPrice(), me.GetAskPrice()}
+		}
+	})
 	//create the stock prices
 	stockPrices := make([]network.StockPrice, len(prices))
 	i := 0
 	for stockID, price := range prices {
+		haltStatus := haltStatuses[stockID]
 		stockPrices[i] = network.StockPrice{
-			StockID:   stockID,
-			StockName: stockIDToName[stockID],
-			Price:     price,
+			StockID:    stockID,
+			StockName:  stockIDToName[stockID],
+			Price:      price,
+			Halted:     haltStatus.Halted,
+			HaltReason: haltStatus.Reason,
+		}
+		if includeQuote {
+			lastPrice, bidPrice, askPrice := quotes[stockID][0], quotes[stockID][1], quotes[stockID][2]
+			stockPrices[i].LastPrice = &lastPrice
+			stockPrices[i].BidPrice = &bidPrice
+			stockPrices[i].AskPrice = &askPrice
 		}
 		i++
 	}
-	//sort by stock name in lexicographically decreasing order
+	sortStockPrices(stockPrices)
+	return &stockPrices, nil
+}
+
+// sort by stock name in lexicographically decreasing order
+func sortStockPrices(stockPrices []network.StockPrice) {
 	sort.SliceStable(stockPrices, func(i, j int) bool {
 		return stockPrices[i].StockName > stockPrices[j].StockName
 	})
+}
 
-	return &stockPrices, nil
+const defaultOrderBookDepth = 10
+
+// Expected query params are stock_id, and optionally depth (the number of price levels per side, default 10).
+func GetStockOrderBookHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Getting stock order book")
+	depth := defaultOrderBookDepth
+	if queryParams.Get("depth") != "" {
+		parsedDepth, err := strconv.Atoi(queryParams.Get("depth"))
+		if err != nil || parsedDepth <= 0 {
+			println("Error: invalid depth ", queryParams.Get("depth"))
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		depth = parsedDepth
+	}
+	orderBook, err := GetStockOrderBook(queryParams.Get("stock_id"), depth)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	returnVal := network.ReturnJSON{
+		Success: true,
+		Data:    orderBook,
+	}
+	orderBookJSON, err := json.Marshal(returnVal)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(orderBookJSON)
 }
 
-func SendToOrderExection(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (network.ExecutorToMatchingEngineJSON, error) {
-	buyQty := buyOrder.GetQuantity()
-	sellQty := sellOrder.GetQuantity()
-	quantity := buyQty
-	if sellQty < buyQty {
-		quantity = sellQty
+func GetStockOrderBook(stockID string, depth int) (*network.StockOrderBook, error) {
+	me, ok := stockEngine(stockID)
+	if !ok {
+		return nil, fmt.Errorf("matching engine not found for ID: %s", stockID)
 	}
-	transferEntity := network.MatchingEngineToExecutionJSON{
+	bids, asks := me.GetDepth(depth)
+	return &network.StockOrderBook{
+		StockID: stockID,
+		Bids:    toPriceLevelJSON(bids),
+		Asks:    toPriceLevelJSON(asks),
+	}, nil
+}
+
+const defaultStockTradesLimit = 50
+
+func GetStockTrades(stockID string, limit int) (*network.StockTrades, error) {
+	me, ok := stockEngine(stockID)
+	if !ok {
+		return nil, fmt.Errorf("matching engine not found for ID: %s", stockID)
+	}
+	recentTrades := me.GetRecentTrades(limit)
+	trades := make([]network.Trade, len(recentTrades))
+	for i, trade := range recentTrades {
+		trades[i] = network.Trade{
+			Price:         trade.Price,
+			Quantity:      trade.Quantity,
+			Timestamp:     trade.Timestamp,
+			AggressorSide: trade.AggressorSide,
+		}
+	}
+	return &network.StockTrades{
+		StockID: stockID,
+		Trades:  trades,
+	}, nil
+}
+
+func GetStockTradesHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Getting stock trades")
+	limit := defaultStockTradesLimit
+	if queryParams.Get("limit") != "" {
+		parsedLimit, err := strconv.Atoi(queryParams.Get("limit"))
+		if err != nil || parsedLimit <= 0 {
+			println("Error: invalid limit ", queryParams.Get("limit"))
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = parsedLimit
+	}
+	trades, err := GetStockTrades(queryParams.Get("stock_id"), limit)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	returnVal := network.ReturnJSON{
+		Success: true,
+		Data:    trades,
+	}
+	tradesJSON, err := json.Marshal(returnVal)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(tradesJSON)
+}
+
+func toPriceLevelJSON(levels []matchingEngineStructures.PriceLevel) []network.PriceLevel {
+	converted := make([]network.PriceLevel, len(levels))
+	for i, level := range levels {
+		converted[i] = network.PriceLevel{
+			Price:      level.Price,
+			Quantity:   level.Quantity,
+			OrderCount: level.OrderCount,
+		}
+	}
+	return converted
+}
+
+// Sends a trade to the stock database, which keeps the candles for the price history.
+func RecordTrade(stockID string, trade matchingEngineStructures.Trade) error {
+	_, err := _networkHttpManager.Stocks().Post("recordTrade", network.StockTrade{
+		StockID: stockID,
+		Trade: network.Trade{
+			Price:         trade.Price,
+			Quantity:      trade.Quantity,
+			Timestamp:     trade.Timestamp,
+			AggressorSide: trade.AggressorSide,
+		},
+	})
+	return err
+}
+
+// A child order is only part of its order, so that side is partial. Both sides can be partial when a pro-rata fill splits them both.
+func executionJSON(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, stockPrice money.Money, matchID string) network.MatchingEngineToExecutionJSON {
+	return network.MatchingEngineToExecutionJSON{
 		BuyerID:       buyOrder.GetUserID(),
 		SellerID:      sellOrder.GetUserID(),
 		StockID:       buyOrder.GetStockID(),
 		BuyOrderID:    buyOrder.GetId(),
 		SellOrderID:   sellOrder.GetId(),
-		IsBuyPartial:  buyQty > sellQty,
-		IsSellPartial: buyQty < sellQty,
-		StockPrice:    sellOrder.GetPrice(),
-		Quantity:      quantity,
+		IsBuyPartial:  buyOrder.GetParentStockOrderID() != "",
+		IsSellPartial: sellOrder.GetParentStockOrderID() != "",
+		StockPrice:    stockPrice,
+		Quantity:      min(buyOrder.GetQuantity(), sellOrder.GetQuantity()),
+		MatchID:       matchID,
 	}
+}
+
+func SendToOrderExection(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, stockPrice money.Money, matchID string) (network.ExecutorToMatchingEngineJSON, error) {
+	transferEntity := executionJSON(buyOrder, sellOrder, stockPrice, matchID)
 
 	data, err := _networkHttpManager.OrderExecutor().Post("executor", transferEntity)
 
@@ -248,3 +912,91 @@ func SendToOrderExection(buyOrder order.StockOrderInterface, sellOrder order.Sto
 	}
 	return matchedData, nil
 }
+
+// Sends a batch of matches to the executor, which runs them in order and answers each. A match it didn't run has an error.
+func SendBatchToOrderExecution(matches []network.MatchingEngineToExecutionJSON) ([]network.MatchExecutionResult, error) {
+	data, err := _networkHttpManager.OrderExecutor().Post("executeBatch", matches)
+	if err != nil {
+		println("Error: ", err.Error())
+		return nil, err
+	}
+	var results []network.MatchExecutionResult
+	err = json.Unmarshal(data, &results)
+	if err != nil {
+		println("Error: ", err.Error())
+		return nil, err
+	}
+	return results, nil
+}
+
+// Marks the stock transaction behind an order cancelled, and hands a sell order's escrowed shares back to the seller.
+// The order initiator took the shares out of the seller's portfolio when the order was placed.
+func CancelUnfilledOrder(stockOrder order.StockOrderInterface) error {
+	_, err := _networkHttpManager.Transactions().Put("cancelStockTransaction/"+stockOrder.GetId(), nil)
+	if err != nil {
+		println("Error: ", err.Error())
+		return err
+	}
+	if stockOrder.GetIsBuy() {
+		return nil
+	}
+	return ReleaseEscrowedShares(stockOrder.GetUserID(), stockOrder.GetStockID(), stockOrder.GetQuantity())
+}
+
+// Takes quantity off the stock transaction behind an order that was cut back without trading, and hands a sell order's escrowed shares for it back.
+func ReduceUnfilledOrder(stockOrder order.StockOrderInterface, quantity int) error {
+	stockTransaction, err := _transactionDatabaseAccess.StockTransaction().GetByID(stockOrder.GetId())
+	if err != nil {
+		return err
+	}
+	stockTransaction.SetQuantity(stockTransaction.GetQuantity() - quantity)
+	err = _transactionDatabaseAccess.StockTransaction().Update(stockTransaction)
+	if err != nil {
+		return err
+	}
+	if stockOrder.GetIsBuy() {
+		return nil
+	}
+	return ReleaseEscrowedShares(stockOrder.GetUserID(), stockOrder.GetStockID(), quantity)
+}
+
+// Positive delta takes more of the seller's shares into escrow, failing if they don't hold enough. Negative delta gives them back.
+func AdjustEscrowedShares(stockOrder order.StockOrderInterface, delta int) error {
+	if delta <= 0 {
+		return ReleaseEscrowedShares(stockOrder.GetUserID(), stockOrder.GetStockID(), -delta)
+	}
+	portfolio, err := _userDatabaseAccess.UserStock().GetUserStocks(stockOrder.GetUserID())
+	if err != nil {
+		return fmt.Errorf("failed to get seller stocks: %v", err)
+	}
+	for _, holding := range *portfolio {
+		if holding.GetStockID() == stockOrder.GetStockID() {
+			if holding.GetQuantity() < delta {
+				return fmt.Errorf("insufficient stock quantity: has %d, needs %d more", holding.GetQuantity(), delta)
+			}
+			holding.SetQuantity(holding.GetQuantity() - delta)
+			return _userDatabaseAccess.UserStock().Update(holding)
+		}
+	}
+	return fmt.Errorf("seller does not own stock %s", stockOrder.GetStockID())
+}
+
+func ReleaseEscrowedShares(userID string, stockID string, quantity int) error {
+	portfolio, err := _userDatabaseAccess.UserStock().GetUserStocks(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get seller stocks: %v", err)
+	}
+	for _, holding := range *portfolio {
+		if holding.GetStockID() == stockID {
+			holding.SetQuantity(holding.GetQuantity() + quantity)
+			return _userDatabaseAccess.UserStock().Update(holding)
+		}
+	}
+	// the holding is normally kept at zero when everything is escrowed, but recreate it if it has gone.
+	_, err = _userDatabaseAccess.UserStock().Create(userStock.New(userStock.NewUserStockParams{
+		UserID:   userID,
+		StockID:  stockID,
+		Quantity: quantity,
+	}))
+	return err
+}
@@ -2,71 +2,285 @@ package matchingEngine
 
 import (
 	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
 	"Shared/entities/order"
+	"Shared/entities/stock"
 	"Shared/network"
 	"databaseAccessStockOrder"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
 // https://gobyexample.com/channels
 // https://chatgpt.com/share/67aa804e-4678-8006-970a-23d76d933f3c
 type MatchingEngineInterface interface {
-	AddOrder(stockOrder order.StockOrderInterface)
-	RemoveOrder(orderID string, priceKey float64)
+	AddOrder(stockOrder order.StockOrderInterface) error
+	RemoveOrder(orderID string, priceKey money.Money, isBuy bool)
+	AmendOrder(params *AmendParams) *AmendResult
 	RunMatchingEngineOrders()
 	RunMatchingEngineUpdates()
-	GetPrice() float64
+	RunMatchingEngineTrades()
+	RunMatchingEngineExpiry()
+	RunMatchingEngineSnapshots()
+	RunMatchingEngineQuarantine()
+	Stop() *matchingEngineStructures.BookState
+	GetQuarantinedMatches() []network.QuarantinedMatch
+	GetIntake() network.EngineIntake
+	Split(corporateActionID string, split stock.Split, cashPrice money.Money) *SplitResult
+	ReleaseQuarantinedMatch(matchID string, action string) error
+	Halt(reason string) bool
+	Resume() bool
+	IsHalted() bool
+	GetHaltStatus() network.HaltStatus
+	StartAuction() bool
+	InAuction() bool
+	Uncross() (network.AuctionResult, error)
+	GetPrice() money.Money
+	GetDepth(depth int) (bids []matchingEngineStructures.PriceLevel, asks []matchingEngineStructures.PriceLevel)
+	GetBidPrice() money.Money
+	GetAskPrice() money.Money
+	GetLastPrice() money.Money
+	GetRecentTrades(limit int) []matchingEngineStructures.Trade
+	GetBookTop() network.BookTop
 }
 
 type MatchingEngine struct {
-	StockId             string
-	BuyOrderBook        matchingEngineStructures.BuyOrderBookInterface
-	SellOrderBook       matchingEngineStructures.SellOrderBookInterface
-	orderChannel        chan order.StockOrderInterface
-	updateChannel       chan *UpdateParams
-	SendToOrderExection func(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (network.ExecutorToMatchingEngineJSON, error)
+	StockId                   string
+	BuyOrderBook              matchingEngineStructures.BuyOrderBookInterface
+	SellOrderBook             matchingEngineStructures.SellOrderBookInterface
+	TradeTape                 matchingEngineStructures.TradeTapeInterface
+	TriggerBook               matchingEngineStructures.TriggerBookInterface
+	PriceBand                 matchingEngineStructures.PriceBandInterface
+	BreachHaltDuration        time.Duration
+	orderChannel              chan order.StockOrderInterface // the intake queue, AddOrder turns orders away once it is full
+	intakeMutex               *sync.Mutex
+	rejected                  int // guarded by intakeMutex
+	updateChannel             chan *UpdateParams
+	tradeChannel              chan matchingEngineStructures.Trade
+	SendToOrderExection       func(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, stockPrice money.Money, matchID string) (network.ExecutorToMatchingEngineJSON, error)
+	SendBatchToOrderExecution func(matches []network.MatchingEngineToExecutionJSON) ([]network.MatchExecutionResult, error)
+	SettlementBatchSize       int
+	SettlementWindow          int
+	SynchronousSettlement     bool
+	pendingMatches            []*pendingMatch    // made but not sent to the executor yet
+	inFlight                  []*settlementBatch // sent, oldest first. They are answered and applied in this order
+	reserved                  *reservations
+	CancelUnfilledOrder       func(stockOrder order.StockOrderInterface) error
+	AdjustEscrow              func(stockOrder order.StockOrderInterface, delta int) error
+	ReduceUnfilledOrder       func(stockOrder order.StockOrderInterface, quantity int) error
+	AdjustHoldings            func(split *network.UserStockSplit) ([]network.SplitHolding, error)
+	SelfTradePrevention       string
+	RecordTrade               func(stockID string, trade matchingEngineStructures.Trade) error
+	MarketData                matchingEngineStructures.MarketDataHubInterface
+	lastBookTop               network.BookTop
+	pendingImmediate          []order.StockOrderInterface // IOC and FOK orders in the book, cancelled once the current matching pass ends
+	bookTopMutex              *sync.Mutex
+	haltState                 haltState
+	haltMutex                 *sync.Mutex
+	resumeChannel             chan struct{}
+	inAuction                 bool // guarded by haltMutex
+	uncrossChannel            chan *uncrossRequest
+	auctionPrice              money.Money // while auctionVolume is left, the loop is executing an uncross at this price
+	auctionVolume             int
+	Journal                   matchingEngineStructures.JournalInterface // nil when nothing is journaled
+	clock                     func() time.Time                          // nil for the wall clock. A replay runs on the journal's clock
+	settleChannel             chan chan struct{}                        // closes the channel it is sent once the matching loop has nothing left to match
+	inputMutex                *sync.Mutex                               // held while an input is journaled and applied to the book, so a snapshot always falls between two journal entries
+	snapshotChannel           chan chan *matchingEngineStructures.Snapshot
+	SnapshotDirectory         string
+	SnapshotInterval          time.Duration
+	Quarantine                matchingEngineStructures.QuarantineInterface
+	resolveChannel            chan *quarantineResolution
+	splitChannel              chan *splitRequest
+	startedAt                 time.Time // with matchCount, gives every match this engine sends a new ID
+	matchCount                int
+	stopChannel               chan struct{}      // closed by Stop, the Run goroutines other than the matching loop return
+	exitChannel               chan chan struct{} // the matching loop returns once it has nothing left to match, and closes the channel it is sent
+	stopOnce                  *sync.Once
+	workers                   *sync.WaitGroup // the Run goroutines other than the matching loop
 	//dirty fix
 	DatabaseManager databaseAccessStockOrder.DatabaseAccessInterface
 }
 
 type NewMatchingEngineParams struct {
-	StockID                  string
-	InitalOrders             *[]order.StockOrderInterface
-	SendToOrderExecutionFunc func(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (network.ExecutorToMatchingEngineJSON, error)
-	DatabaseManager          databaseAccessStockOrder.DatabaseAccessInterface
+	StockID                       string
+	InitalOrders                  *[]order.StockOrderInterface
+	SendToOrderExecutionFunc      func(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, stockPrice money.Money, matchID string) (network.ExecutorToMatchingEngineJSON, error)
+	SendBatchToOrderExecutionFunc func(matches []network.MatchingEngineToExecutionJSON) ([]network.MatchExecutionResult, error) // leave nil to send a batch's matches one at a time with SendToOrderExecutionFunc
+	SettlementBatchSize           int                                                                                           // most matches sent to the executor at once. leave 0 for the default
+	SettlementWindow              int                                                                                           // most batches out at once before matching waits. leave 0 for the default
+	SynchronousSettlement         bool                                                                                          // settle every match before making the next, like a replay needs
+	CancelUnfilledOrderFunc       func(stockOrder order.StockOrderInterface) error
+	AdjustEscrowFunc              func(stockOrder order.StockOrderInterface, delta int) error      // moves delta more shares of a sell order into escrow, or back out if negative
+	ReduceUnfilledOrderFunc       func(stockOrder order.StockOrderInterface, quantity int) error   // takes quantity off an order's transaction that will never trade, releasing escrow for a sell
+	SelfTradePrevention           string                                                           // one of the SelfTrade modes. leave empty for the default
+	AllocationPolicy              string                                                           // FIFO or one of the pro-rata policies from the stock. leave empty for FIFO
+	PriceBandPercent              float64                                                          // a trade further than this from the reference price halts the stock. leave 0 for no band
+	PriceBandWindow               time.Duration                                                    // how long a reference price lasts. leave 0 for the default
+	BreachHaltDuration            time.Duration                                                    // how long a band breach halts for. leave 0 to stay halted until resumed
+	RecordTradeFunc               func(stockID string, trade matchingEngineStructures.Trade) error // leave nil to not keep a price history
+	MarketDataHub                 matchingEngineStructures.MarketDataHubInterface                  // leave nil if nothing streams from this engine
+	Journal                       matchingEngineStructures.JournalInterface                        // leave nil to not journal
+	InQueueOrder                  bool                                                             // the initial orders are already in the order they match, like a snapshot's. leave false to sort them by time priority
+	SnapshotDirectory             string                                                           // where RunMatchingEngineSnapshots writes. leave empty, or Journal nil, for no snapshots
+	SnapshotInterval              time.Duration                                                    // leave 0 for the default
+	IntakeQueueSize               int                                                              // most orders waiting for the matching loop before AddOrder says the engine is busy. leave 0 for the default
+	DatabaseManager               databaseAccessStockOrder.DatabaseAccessInterface
+	// rescales the holdings of the stock in a split. leave nil to leave them alone
+	AdjustHoldingsFunc func(split *network.UserStockSplit) ([]network.SplitHolding, error)
 }
 
 func NewMatchingEngineForStock(params *NewMatchingEngineParams) MatchingEngineInterface {
-	var marketOrders []order.StockOrderInterface
-	var limitOrders []order.StockOrderInterface
+	me := newMatchingEngine(params)
+	me.journalStart()
+	return me
+}
+
+func newMatchingEngine(params *NewMatchingEngineParams) *MatchingEngine {
+	var buyOrders []order.StockOrderInterface
+	var sellOrders []order.StockOrderInterface
+	var stopOrders []order.StockOrderInterface
 	for _, order := range *params.InitalOrders {
-		if order.GetIsBuy() {
-			marketOrders = append(marketOrders, order)
+		if order.IsStop() {
+			stopOrders = append(stopOrders, order)
+		} else if order.GetIsBuy() {
+			buyOrders = append(buyOrders, order)
 		} else {
-			limitOrders = append(limitOrders, order)
+			sellOrders = append(sellOrders, order)
 		}
 	}
+	selfTradePrevention, err := ParseSelfTradePrevention(params.SelfTradePrevention)
+	if err != nil {
+		println("Error: ", err.Error(), ". Using ", DefaultSelfTradePrevention)
+		selfTradePrevention = DefaultSelfTradePrevention
+	}
+	if !params.InQueueOrder {
+		matchingEngineStructures.SortByTimePriority(&buyOrders)
+		matchingEngineStructures.SortByTimePriority(&sellOrders)
+	}
+	if params.SnapshotInterval <= 0 {
+		params.SnapshotInterval = defaultSnapshotInterval
+	}
+	if params.SettlementBatchSize <= 0 {
+		params.SettlementBatchSize = defaultSettlementBatchSize
+	}
+	if params.SettlementWindow <= 0 {
+		params.SettlementWindow = defaultSettlementWindow
+	}
+	if params.IntakeQueueSize <= 0 {
+		params.IntakeQueueSize = defaultIntakeQueueSize
+	}
+	if params.MarketDataHub == nil {
+		params.MarketDataHub = matchingEngineStructures.NewMarketDataHub(&matchingEngineStructures.NewMarketDataHubParams{})
+	}
 	me := &MatchingEngine{
-		StockId:             params.StockID,
-		BuyOrderBook:        matchingEngineStructures.DefaultBuyOrderBook(&marketOrders),
-		SellOrderBook:       matchingEngineStructures.DefaultSellOrderBook(&limitOrders),
-		orderChannel:        make(chan order.StockOrderInterface),
-		updateChannel:       make(chan *UpdateParams),
-		SendToOrderExection: params.SendToOrderExecutionFunc,
-		DatabaseManager:     params.DatabaseManager,
+		StockId:                   params.StockID,
+		BuyOrderBook:              matchingEngineStructures.DefaultBuyOrderBook(&buyOrders, params.AllocationPolicy),
+		SellOrderBook:             matchingEngineStructures.DefaultSellOrderBook(&sellOrders, params.AllocationPolicy),
+		TradeTape:                 matchingEngineStructures.NewTradeTape(&matchingEngineStructures.NewTradeTapeParams{}),
+		TriggerBook:               matchingEngineStructures.NewTriggerBook(&matchingEngineStructures.NewTriggerBookParams{InitalOrders: &stopOrders}),
+		PriceBand:                 matchingEngineStructures.NewPriceBand(&matchingEngineStructures.NewPriceBandParams{Percent: params.PriceBandPercent, Window: params.PriceBandWindow}),
+		BreachHaltDuration:        params.BreachHaltDuration,
+		orderChannel:              make(chan order.StockOrderInterface, params.IntakeQueueSize),
+		intakeMutex:               &sync.Mutex{},
+		updateChannel:             make(chan *UpdateParams),
+		tradeChannel:              make(chan matchingEngineStructures.Trade, tradeChannelSize),
+		SendToOrderExection:       params.SendToOrderExecutionFunc,
+		SendBatchToOrderExecution: params.SendBatchToOrderExecutionFunc,
+		SettlementBatchSize:       params.SettlementBatchSize,
+		SettlementWindow:          params.SettlementWindow,
+		SynchronousSettlement:     params.SynchronousSettlement,
+		reserved:                  newReservations(),
+		CancelUnfilledOrder:       params.CancelUnfilledOrderFunc,
+		AdjustEscrow:              params.AdjustEscrowFunc,
+		ReduceUnfilledOrder:       params.ReduceUnfilledOrderFunc,
+		SelfTradePrevention:       selfTradePrevention,
+		RecordTrade:               params.RecordTradeFunc,
+		MarketData:                params.MarketDataHub,
+		lastBookTop:               network.BookTop{StockID: params.StockID},
+		bookTopMutex:              &sync.Mutex{},
+		haltMutex:                 &sync.Mutex{},
+		resumeChannel:             make(chan struct{}, 1),
+		uncrossChannel:            make(chan *uncrossRequest),
+		Journal:                   params.Journal,
+		settleChannel:             make(chan chan struct{}),
+		inputMutex:                &sync.Mutex{},
+		snapshotChannel:           make(chan chan *matchingEngineStructures.Snapshot),
+		SnapshotDirectory:         params.SnapshotDirectory,
+		SnapshotInterval:          params.SnapshotInterval,
+		Quarantine:                matchingEngineStructures.NewQuarantine(&matchingEngineStructures.NewQuarantineParams{}),
+		resolveChannel:            make(chan *quarantineResolution),
+		splitChannel:              make(chan *splitRequest),
+		AdjustHoldings:            params.AdjustHoldingsFunc,
+		startedAt:                 time.Now(),
+		stopChannel:               make(chan struct{}),
+		exitChannel:               make(chan chan struct{}),
+		stopOnce:                  &sync.Once{},
+		workers:                   &sync.WaitGroup{},
+		DatabaseManager:           params.DatabaseManager,
+	}
+	// left over from before a restart, they get no more time than the first pass
+	for _, stockOrder := range *params.InitalOrders {
+		if IsImmediate(stockOrder) {
+			me.pendingImmediate = append(me.pendingImmediate, stockOrder)
+		}
 	}
 	return me
 }
 
+func (me *MatchingEngine) now() time.Time {
+	if me.clock == nil {
+		return time.Now()
+	}
+	return me.clock()
+}
+
+// Blocks until the matching loop has matched everything it can and is waiting for something new.
+func (me *MatchingEngine) settle() {
+	done := make(chan struct{})
+	me.settleChannel <- done
+	<-done
+}
+
 func (me *MatchingEngine) RunMatchingEngineOrders() {
 	println("Running Matching Engine Orders")
 	var buyOrder order.StockOrderInterface
 	var sellOrder order.StockOrderInterface
 	for {
+		if me.batchReady() && me.canSendMatches() {
+			me.sendMatches()
+		}
+		if me.mustAwaitSettlement() {
+			me.returnHeldOrders(buyOrder, sellOrder)
+			buyOrder = nil
+			sellOrder = nil
+			me.awaitSettlement()
+			continue
+		}
+		if buyOrder == nil && sellOrder == nil {
+			me.applySettledBatches()
+		}
+		if me.IsHalted() || me.InAuction() {
+			me.returnHeldOrders(buyOrder, sellOrder)
+			buyOrder = nil
+			sellOrder = nil
+			if me.waitForTrading() {
+				return
+			}
+			continue
+		}
 		//dequeue the top of the buy order book and sell order book
 		if buyOrder == nil {
 			println("Getting best buy order")
 			buyOrder = me.BuyOrderBook.GetBestOrder()
+			if buyOrder != nil && buyOrder.IsExpired(me.now()) {
+				println("Buy order has expired: ", buyOrder.GetId())
+				me.cancelRemainder(buyOrder)
+				buyOrder = nil
+				continue
+			}
 			if buyOrder != nil {
 				temp, err := buyOrder.ToJSON()
 				if err != nil {
@@ -78,6 +292,12 @@ func (me *MatchingEngine) RunMatchingEngineOrders() {
 		if sellOrder == nil {
 			println("Getting best sell order")
 			sellOrder = me.SellOrderBook.GetBestOrder()
+			if sellOrder != nil && sellOrder.IsExpired(me.now()) {
+				println("Sell order has expired: ", sellOrder.GetId())
+				me.cancelRemainder(sellOrder)
+				sellOrder = nil
+				continue
+			}
 			if sellOrder != nil {
 				temp, err := sellOrder.ToJSON()
 				if err != nil {
@@ -89,9 +309,13 @@ func (me *MatchingEngine) RunMatchingEngineOrders() {
 		if buyOrder == nil || sellOrder == nil {
 			if buyOrder == nil {
 				println("Buy Order is nil")
-				if sellOrder != nil {
+				if sellOrder != nil && sellOrder.GetOrderType() == order.OrderTypeMarket {
+					println("No bids left for market sell. Cancelling remainder")
+					me.cancelRemainder(sellOrder)
+					sellOrder = nil
+				} else if sellOrder != nil {
 					println("Returning sell order")
-					me.SellOrderBook.AddOrder(sellOrder)
+					me.SellOrderBook.ReturnOrder(sellOrder)
 					sellOrder = nil
 				}
 			} else if sellOrder == nil {
@@ -101,83 +325,119 @@ func (me *MatchingEngine) RunMatchingEngineOrders() {
 				buyOrder = nil
 			}
 		}
+		var stockPrice money.Money
+		if buyOrder != nil && sellOrder != nil {
+			var crosses bool
+			stockPrice, crosses = me.matchPrice(buyOrder, sellOrder)
+			if !crosses && sellOrder.GetOrderType() == order.OrderTypeMarket {
+				println("No priced bid for market sell. Cancelling remainder")
+				me.BuyOrderBook.ReturnOrder(buyOrder)
+				me.cancelRemainder(sellOrder)
+				buyOrder = nil
+				sellOrder = nil
+			} else if !crosses {
+				println("Best bid does not cross best ask. Returning orders")
+				me.BuyOrderBook.ReturnOrder(buyOrder)
+				me.SellOrderBook.ReturnOrder(sellOrder)
+				buyOrder = nil
+				sellOrder = nil
+			}
+		}
+		if buyOrder != nil && sellOrder != nil && IsSelfTrade(buyOrder, sellOrder) {
+			buyOrder, sellOrder = me.preventSelfTrade(buyOrder, sellOrder)
+			continue
+		}
+		// an uncross is allowed to move the price as far as it needs to
+		if buyOrder != nil && sellOrder != nil && me.auctionVolume == 0 && me.PriceBand.Breached(stockPrice, me.now()) {
+			println("Trade at ", stockPrice.String(), " breaks the price band around ", me.PriceBand.GetReferencePrice().String())
+			me.tripCircuitBreaker()
+			continue
+		}
 		println("Starting Match")
 		if buyOrder != nil && sellOrder != nil {
 			println("Matching Orders. Buy Order: ", buyOrder.GetId(), " Sell Order: ", sellOrder.GetId())
-			buyIsChild := false
-			sellIsChild := false
-			var parentOrder order.StockOrderInterface
-			if buyOrder.GetQuantity() < sellOrder.GetQuantity() {
-				println("Creating sell child order for: ", sellOrder.GetId())
-				parentOrder = sellOrder
-				sellIsChild = true
-				sellOrder = sellOrder.CreateChildOrder(sellOrder, buyOrder)
-				println("Parent Order Quantity: ", parentOrder.GetQuantity())
-				println("Child Order Quantity: ", sellOrder.GetQuantity())
-				if sellOrder.GetQuantity() == parentOrder.GetQuantity() {
-					close(me.orderChannel)
-					close(me.updateChannel)
-					panic("Child order quantity is equal to parent order quantity. This should not happen")
-				}
-			}
-			if buyOrder.GetQuantity() > sellOrder.GetQuantity() {
-				println("Creating buy child order for: ", buyOrder.GetId())
-				parentOrder = buyOrder
-				buyIsChild = true
-				buyOrder = buyOrder.CreateChildOrder(buyOrder, sellOrder)
-				println("Parent Order Quantity: ", parentOrder.GetQuantity())
-				println("Child Order Quantity: ", buyOrder.GetQuantity())
-				if sellOrder.GetQuantity() == parentOrder.GetQuantity() {
-					panic("Child order quantity is equal to parent order quantity. This should not happen")
-				}
-			}
-			result, err := me.SendToOrderExection(buyOrder, sellOrder)
-			println("Order Executed: ")
-			sellOrderQuantity := sellOrder.GetQuantity()
-			buyOrderQuantity := buyOrder.GetQuantity()
-			if sellIsChild {
-				sellOrder = parentOrder
-			} else if buyIsChild {
-				buyOrder = parentOrder
+			if level := me.proRataLevel(buyOrder, sellOrder); level != nil {
+				buyOrder, sellOrder = me.matchProRata(buyOrder, sellOrder, level, stockPrice)
+				continue
 			}
-			if err != nil {
-				//rollback
-				me.BuyOrderBook.ReturnOrder(buyOrder)
+			me.reserveTrade(buyOrder, sellOrder, min(buyOrder.GetVisibleQuantity(), sellOrder.GetVisibleQuantity()), stockPrice)
+			if sellOrder.GetQuantity() == 0 {
+				sellOrder = nil
+			} else if me.refreshIceberg(sellOrder) {
 				me.SellOrderBook.AddOrder(sellOrder)
-				close(me.orderChannel)
-				close(me.updateChannel)
-				panic("Error in order execution")
-			} else if result.IsBuyFailure {
-				println("Buy Order Failed: ", buyOrder.GetId())
-				buyOrder = nil
-			} else if result.IsSellFailure {
-				println("Sell Order Failed: ", sellOrder.GetId())
 				sellOrder = nil
-			} else {
-				println("Cleaning up orders")
-				sellOrder.SetQuantity(sellOrder.GetQuantity() - buyOrderQuantity)
-				buyOrder.SetQuantity(buyOrder.GetQuantity() - sellOrderQuantity)
-				if sellOrder.GetQuantity() == 0 {
-					println("finishing sell Order: ", buyOrder.GetId())
-					_databaseManager.Delete(sellOrder.GetId())
-					sellOrder = nil
-				} else {
-					_databaseManager.Update(sellOrder)
-				}
-
-				if buyOrder.GetQuantity() == 0 {
-					println("finishing buy Order: ", buyOrder.GetId())
-					_databaseManager.Delete(buyOrder.GetId())
-					buyOrder = nil
-				} else {
-					_databaseManager.Update(buyOrder)
-				}
+			}
+			if buyOrder.GetQuantity() == 0 {
+				buyOrder = nil
+			} else if me.refreshIceberg(buyOrder) {
+				me.BuyOrderBook.AddOrder(buyOrder)
+				buyOrder = nil
 			}
 		} else {
 			fmt.Println("No orders to match")
+			// an IOC or FOK remainder is only cancelled once what it traded has settled
+			if len(me.pendingImmediate) > 0 && me.settlementPending() {
+				me.drainSettlements()
+				continue
+			}
+			if len(me.pendingMatches) > 0 && me.canSendMatches() {
+				me.sendMatches()
+			}
+			me.cancelImmediateRemainders()
+			me.publishBookTop()
 			fmt.Println("Waiting for order")
-			stockOrder := <-me.orderChannel
+			var settled chan struct{} // nil blocks, when nothing is out
+			if len(me.inFlight) > 0 {
+				settled = me.inFlight[0].done
+			}
+			var stockOrder order.StockOrderInterface
+			select {
+			case stockOrder = <-me.orderChannel:
+			case <-settled:
+				me.applySettlement()
+				continue
+			case request := <-me.uncrossChannel:
+				me.uncross(request)
+				continue
+			case done := <-me.settleChannel:
+				if me.settlementPending() {
+					// what comes back may match again, so the book isn't settled until then
+					me.drainSettlements()
+					go func() { me.settleChannel <- done }()
+					continue
+				}
+				if me.queued() > 0 {
+					go func() { me.settleChannel <- done }()
+					continue
+				}
+				close(done)
+				continue
+			case request := <-me.snapshotChannel:
+				me.snapshot(request)
+				continue
+			case resolution := <-me.resolveChannel:
+				me.resolveQuarantined(resolution)
+				continue
+			case request := <-me.splitChannel:
+				me.splitBooks(request)
+				continue
+			case done := <-me.exitChannel:
+				if me.queued() > 0 {
+					// match what was queued before stopping
+					go func() { me.exitChannel <- done }()
+					continue
+				}
+				me.drainSettlements()
+				println("Matching loop stopped for stock: ", me.StockId)
+				close(done)
+				return
+			}
 			fmt.Println("Order received")
+			if stockOrder.IsStop() {
+				me.acceptStopOrder(stockOrder)
+			} else if IsImmediate(stockOrder) {
+				me.acceptImmediateOrder(stockOrder)
+			}
 			temp, err := stockOrder.ToJSON()
 			if err != nil {
 				fmt.Println("Error: ", err.Error())
@@ -188,45 +448,650 @@ func (me *MatchingEngine) RunMatchingEngineOrders() {
 	}
 }
 
+// What is sent to the executor for a trade of quantity shares. A side the trade doesn't finish is a child order for just those shares.
+// Either way it is a copy, so the order can change while the match is out.
+func fills(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, quantity int) (order.StockOrderInterface, order.StockOrderInterface) {
+	var buyFill order.StockOrderInterface = order.New(buyOrder.ToParams())
+	if quantity < buyOrder.GetQuantity() {
+		println("Creating buy child order for: ", buyOrder.GetId(), " Quantity: ", quantity, " of ", buyOrder.GetQuantity())
+		buyFill = buyOrder.CreateChildOrder(buyOrder, quantity)
+	}
+	var sellFill order.StockOrderInterface = order.New(sellOrder.ToParams())
+	if quantity < sellOrder.GetQuantity() {
+		println("Creating sell child order for: ", sellOrder.GetId(), " Quantity: ", quantity, " of ", sellOrder.GetQuantity())
+		sellFill = sellOrder.CreateChildOrder(sellOrder, quantity)
+	}
+	return buyFill, sellFill
+}
+
+// A market order crosses any order on the other side. Two limit orders only cross when the best ask is at or below the bid.
+func OrdersCross(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) bool {
+	if buyOrder.GetOrderType() == order.OrderTypeMarket || sellOrder.GetOrderType() == order.OrderTypeMarket {
+		return true
+	}
+	return buyOrder.GetPrice() >= sellOrder.GetPrice()
+}
+
+// Trades execute at the price of the resting order. A limit bid that was in the book before the ask trades at the bid price.
+// A market order always takes the price of the limit order it matched. Two market orders have no price, so this returns 0.
+func TradePrice(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) money.Money {
+	buyIsLimit := buyOrder.GetOrderType() == order.OrderTypeLimit
+	sellIsLimit := sellOrder.GetOrderType() == order.OrderTypeLimit
+	if buyIsLimit && (!sellIsLimit || buyOrder.GetTimePriority().Before(sellOrder.GetTimePriority())) {
+		return buyOrder.GetPrice()
+	}
+	if sellIsLimit {
+		return sellOrder.GetPrice()
+	}
+	return 0
+}
+
+// The aggressor is the order that took liquidity. A market order against a limit order is always the aggressor, otherwise it is whichever arrived last.
+func AggressorSide(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) string {
+	buyIsMarket := buyOrder.GetOrderType() == order.OrderTypeMarket
+	sellIsMarket := sellOrder.GetOrderType() == order.OrderTypeMarket
+	if buyIsMarket != sellIsMarket {
+		if buyIsMarket {
+			return matchingEngineStructures.AggressorBuy
+		}
+		return matchingEngineStructures.AggressorSell
+	}
+	if sellOrder.GetTimePriority().After(buyOrder.GetTimePriority()) {
+		return matchingEngineStructures.AggressorSell
+	}
+	return matchingEngineStructures.AggressorBuy
+}
+
+// Returns the price the pair would trade at, and whether they cross at all.
+// A market buy meeting a market sell borrows the best resting limit price, bid side first. If neither side has one, they don't cross.
+// While an auction is uncrossing, every pair that is eligible at the clearing price trades at it.
+func (me *MatchingEngine) matchPrice(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (money.Money, bool) {
+	if me.auctionVolume > 0 {
+		if matchingEngineStructures.BuyEligible(buyOrder, me.auctionPrice) && matchingEngineStructures.SellEligible(sellOrder, me.auctionPrice) {
+			return me.auctionPrice, true
+		}
+		println("Auction uncross finished with ", me.auctionVolume, " shares unfilled")
+		me.auctionVolume = 0
+	}
+	if !OrdersCross(buyOrder, sellOrder) {
+		return 0, false
+	}
+	if price := TradePrice(buyOrder, sellOrder); price != 0 {
+		return price, true
+	}
+	if price := me.BuyOrderBook.GetBestPrice(); price != 0 {
+		return price, true
+	}
+	if price := me.SellOrderBook.GetBestPrice(); price != 0 {
+		return price, true
+	}
+	return 0, false
+}
+
+// IOC and FOK orders never rest in the book past the matching pass they arrive in.
+func IsImmediate(stockOrder order.StockOrderInterface) bool {
+	return stockOrder.GetTimeInForce() == order.TimeInForceIOC || stockOrder.GetTimeInForce() == order.TimeInForceFOK
+}
+
+// Puts an IOC or FOK order in the book for the next pass. A FOK order the other side can't fill completely is cancelled without trading.
+func (me *MatchingEngine) acceptImmediateOrder(stockOrder order.StockOrderInterface) {
+	if stockOrder.GetTimeInForce() == order.TimeInForceFOK && me.crossingQuantity(stockOrder) < stockOrder.GetQuantity() {
+		println("Not enough quantity to fill FOK order: ", stockOrder.GetId())
+		me.cancelRemainder(stockOrder)
+		return
+	}
+	if stockOrder.GetIsBuy() {
+		me.BuyOrderBook.AddOrder(stockOrder)
+	} else {
+		me.SellOrderBook.AddOrder(stockOrder)
+	}
+	me.pendingImmediate = append(me.pendingImmediate, stockOrder)
+}
+
+// How much of the other side of the book the order could trade against right now.
+func (me *MatchingEngine) crossingQuantity(stockOrder order.StockOrderInterface) int {
+	var otherSide []order.StockOrderInterface
+	if stockOrder.GetIsBuy() {
+		otherSide = me.SellOrderBook.GetOrders()
+	} else {
+		otherSide = me.BuyOrderBook.GetOrders()
+	}
+	quantity := 0
+	for _, other := range otherSide {
+		// the user's own orders would be stopped by self trade prevention, not fill it
+		if other.GetUserID() == stockOrder.GetUserID() {
+			continue
+		}
+		if stockOrder.GetIsBuy() && OrdersCross(stockOrder, other) || !stockOrder.GetIsBuy() && OrdersCross(other, stockOrder) {
+			quantity += other.GetQuantity()
+		}
+	}
+	return quantity
+}
+
+// A stop order waits in the trigger book. If the last trade has already reached its stop it is released straight away.
+func (me *MatchingEngine) acceptStopOrder(stockOrder order.StockOrderInterface) {
+	me.TriggerBook.AddOrder(stockOrder)
+	if lastTrade, ok := me.TradeTape.GetLastTrade(); ok {
+		me.triggerStops(lastTrade.Price)
+	}
+}
+
+// Releases every stop order the last trade price has reached into the live book, in trigger book order.
+// The triggered order is saved before it joins the book, so after a restart it loads as the market or limit order it became.
+// Each order in a batch is triggered a microsecond after the last, the precision the database keeps, so they reload in the same time priority.
+func (me *MatchingEngine) triggerStops(lastPrice money.Money) {
+	triggeredAt := me.now().Truncate(time.Microsecond)
+	for _, stockOrder := range me.TriggerBook.PopTriggered(lastPrice) {
+		println("Stop triggered at ", lastPrice.String(), " for order: ", stockOrder.GetId())
+		stockOrder.Trigger(triggeredAt)
+		triggeredAt = triggeredAt.Add(time.Microsecond)
+		me.saveOrder(stockOrder)
+		if IsImmediate(stockOrder) {
+			me.acceptImmediateOrder(stockOrder)
+		} else if stockOrder.GetIsBuy() {
+			me.BuyOrderBook.AddOrder(stockOrder)
+		} else {
+			me.SellOrderBook.AddOrder(stockOrder)
+		}
+	}
+}
+
+// Called at the end of every matching pass. Whatever is left of an IOC or FOK order is cancelled.
+func (me *MatchingEngine) cancelImmediateRemainders() {
+	for _, stockOrder := range me.pendingImmediate {
+		removeParams := &matchingEngineStructures.RemoveParams{
+			OrderID:  stockOrder.GetId(),
+			PriceKey: stockOrder.GetPrice(),
+		}
+		var removed order.StockOrderInterface
+		if stockOrder.GetIsBuy() {
+			removed = me.BuyOrderBook.RemoveOrder(removeParams)
+		} else {
+			removed = me.SellOrderBook.RemoveOrder(removeParams)
+		}
+		if removed != nil {
+			me.cancelRemainder(removed)
+		}
+	}
+	me.pendingImmediate = nil
+}
+
+// How often the books are checked for GTD orders that have expired
+const expirySweepInterval = time.Second
+
+// Removes expired GTD orders from the book, cancels their transactions and releases escrowed shares.
+// Orders the matching loop is holding are left alone, it checks them itself before matching.
+func (me *MatchingEngine) RunMatchingEngineExpiry() {
+	if !me.startWorker() {
+		return
+	}
+	defer me.workers.Done()
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-me.stopChannel:
+			return
+		}
+		expired, expiredStops := me.takeExpired(now)
+		// the remainders are cancelled over the network, so it's done after letting go of the input mutex
+		for _, stockOrder := range expired {
+			println("Order has expired: ", stockOrder.GetId())
+			me.cancelRemainder(stockOrder)
+		}
+		for _, stockOrder := range expiredStops {
+			println("Stop order has expired: ", stockOrder.GetId())
+			me.cancelRemainder(stockOrder)
+		}
+		if len(expired) > 0 {
+			me.publishBookTop()
+		}
+	}
+}
+
+// Takes every order expired by now out of the books and the trigger book, journaling each, under one hold of the input mutex.
+func (me *MatchingEngine) takeExpired(now time.Time) (expired []order.StockOrderInterface, expiredStops []order.StockOrderInterface) {
+	me.inputMutex.Lock()
+	defer me.inputMutex.Unlock()
+	for _, book := range []matchingEngineStructures.OrderBookInterface{me.BuyOrderBook, me.SellOrderBook} {
+		for _, stockOrder := range book.GetOrders() {
+			if !stockOrder.IsExpired(now) {
+				continue
+			}
+			removed := book.RemoveOrder(&matchingEngineStructures.RemoveParams{
+				OrderID:  stockOrder.GetId(),
+				PriceKey: stockOrder.GetPrice(),
+			})
+			if removed != nil {
+				me.journalExpiry(removed)
+				expired = append(expired, removed)
+			}
+		}
+	}
+	for _, stockOrder := range me.TriggerBook.GetOrders() {
+		if !stockOrder.IsExpired(now) {
+			continue
+		}
+		removed := me.TriggerBook.RemoveOrder(stockOrder.GetId())
+		if removed != nil {
+			me.journalExpiry(removed)
+			expiredStops = append(expiredStops, removed)
+		}
+	}
+	return expired, expiredStops
+}
+
+// Cancels whatever is left of an order already out of the book, e.g. a market sell once the bids run out, an IOC or FOK remainder,
+// an order stopped from trading with itself or one that expired. A sell order's escrowed shares go back to the seller.
+func (me *MatchingEngine) cancelRemainder(stockOrder order.StockOrderInterface) {
+	println("Cancelling unfilled quantity ", stockOrder.GetQuantity(), " of order: ", stockOrder.GetId())
+	err := me.DatabaseManager.Delete(stockOrder.GetId())
+	if err != nil {
+		println("Error: ", err.Error())
+	}
+	err = me.CancelUnfilledOrder(stockOrder)
+	if err != nil {
+		println("Error: ", err.Error())
+	}
+	me.publishOrderStatus(stockOrder, "CANCELLED", 0)
+}
+
 type UpdateParams struct {
 	OrderID  string
-	PriceKey float64
+	PriceKey money.Money
+	IsBuy    bool
+	Amend    *AmendParams // nil removes the order
+}
+
+// Quantity is the new open quantity and Price the new limit price. 0 keeps the current one.
+type AmendParams struct {
+	OrderID  string
+	PriceKey money.Money // the price the order is resting at
+	IsBuy    bool
+	Quantity int
+	Price    money.Money
+	done     chan *AmendResult
+}
+
+type AmendResult struct {
+	Order         order.StockOrderInterface
+	QuantityDelta int
+	Requeued      bool
+	Err           error
+}
+
+var ErrOrderNotResting = errors.New("order is not resting in the book. It may be filled, being matched, or a stop that hasn't triggered")
+
+// Trades queued up for the price history before matching has to wait on it
+const tradeChannelSize = 1000
+
+// Sends trades on for the price history, in the order they happened, so the matching loop never waits on the network.
+func (me *MatchingEngine) RunMatchingEngineTrades() {
+	if !me.startWorker() {
+		return
+	}
+	defer me.workers.Done()
+	for {
+		select {
+		case trade := <-me.tradeChannel:
+			me.recordTrade(trade)
+		case <-me.stopChannel:
+			return
+		}
+	}
+}
+
+func (me *MatchingEngine) recordTrade(trade matchingEngineStructures.Trade) {
+	if me.RecordTrade == nil {
+		return
+	}
+	err := me.RecordTrade(me.StockId, trade)
+	if err != nil {
+		println("Error recording trade: ", err.Error())
+	}
 }
 
 func (me *MatchingEngine) RunMatchingEngineUpdates() {
+	if !me.startWorker() {
+		return
+	}
+	defer me.workers.Done()
 	for {
-		updateParams := <-me.updateChannel
+		var updateParams *UpdateParams
+		select {
+		case updateParams = <-me.updateChannel:
+		case <-me.stopChannel:
+			return
+		}
+		if updateParams.Amend != nil {
+			fmt.Println("Amending Order")
+			result := me.amendOrder(updateParams.Amend)
+			updateParams.Amend.done <- result
+			continue
+		}
 		fmt.Println("Removing Order")
-		me.SellOrderBook.RemoveOrder(&matchingEngineStructures.RemoveParams{
-			OrderID:  updateParams.OrderID,
-			PriceKey: updateParams.PriceKey,
-		})
+		me.removeOrder(updateParams.OrderID, updateParams.PriceKey, updateParams.IsBuy)
 	}
 }
 
-func (me *MatchingEngine) AddOrder(stockOrder order.StockOrderInterface) {
-	println("Adding Order")
-	if stockOrder.GetOrderType() == order.OrderTypeMarket {
-		me.BuyOrderBook.AddOrder(stockOrder)
+// Counts a Run goroutine in, unless the engine has already stopped.
+func (me *MatchingEngine) startWorker() bool {
+	select {
+	case <-me.stopChannel:
+		return false
+	default:
+	}
+	me.workers.Add(1)
+	return true
+}
+
+// Stops every goroutine the engine runs and closes its journal, then returns the book as it was left.
+// The matching loop stops last, once the others have finished and it has matched everything it was sent.
+// The caller has to make sure nothing adds, removes or amends an order once this is called, nothing would take it.
+func (me *MatchingEngine) Stop() *matchingEngineStructures.BookState {
+	me.stopOnce.Do(func() {
+		println("Stopping matching engine for stock: ", me.StockId)
+		close(me.stopChannel)
+		me.workers.Wait()
+		done := make(chan struct{})
+		me.exitChannel <- done
+		<-done
+		// what the trade goroutine hadn't got to yet
+		for len(me.tradeChannel) > 0 {
+			me.recordTrade(<-me.tradeChannel)
+		}
+		if me.Journal != nil {
+			err := me.Journal.Close()
+			if err != nil {
+				println("Error closing journal for stock: ", me.StockId, " ", err.Error())
+			}
+		}
+	})
+	return me.captureState()
+}
+
+// Takes the order out of whichever book it is in. Nothing happens if it has already gone.
+func (me *MatchingEngine) removeOrder(orderID string, priceKey money.Money, isBuy bool) {
+	me.inputMutex.Lock()
+	defer me.inputMutex.Unlock()
+	me.journal(matchingEngineStructures.JournalEntry{
+		Type:     matchingEngineStructures.JournalRemoveOrder,
+		OrderID:  orderID,
+		PriceKey: priceKey,
+		IsBuy:    isBuy,
+	})
+	removeParams := &matchingEngineStructures.RemoveParams{
+		OrderID:  orderID,
+		PriceKey: priceKey,
+	}
+	var removed order.StockOrderInterface
+	if isBuy {
+		removed = me.BuyOrderBook.RemoveOrder(removeParams)
 	} else {
-		me.SellOrderBook.AddOrder(stockOrder)
+		removed = me.SellOrderBook.RemoveOrder(removeParams)
+	}
+	if removed == nil {
+		removed = me.TriggerBook.RemoveOrder(orderID)
+	}
+	if removed != nil {
+		me.publishOrderStatus(removed, "CANCELLED", 0)
+	}
+	me.publishBookTop()
+}
+
+// Returns ErrEngineBusy, without journaling or booking the order, when the intake queue is full.
+func (me *MatchingEngine) AddOrder(stockOrder order.StockOrderInterface) error {
+	println("Adding Order")
+	// held until the order is queued, a snapshot waits for the queue to empty since stop and immediate orders aren't in any book before the loop has them
+	me.inputMutex.Lock()
+	defer me.inputMutex.Unlock()
+	if me.intakeFull() {
+		println("Intake queue full. Turning away order: ", stockOrder.GetId())
+		return ErrEngineBusy
+	}
+	orderParams := stockOrder.ToParams()
+	me.journal(matchingEngineStructures.JournalEntry{
+		Type:  matchingEngineStructures.JournalAddOrder,
+		Order: &orderParams,
+	})
+	// Stop, IOC and FOK orders are booked by the matching loop, so they are checked against a settled book
+	if !stockOrder.IsStop() && !IsImmediate(stockOrder) {
+		if stockOrder.GetIsBuy() {
+			me.BuyOrderBook.AddOrder(stockOrder)
+		} else {
+			me.SellOrderBook.AddOrder(stockOrder)
+		}
 	}
-	me.orderChannel <- stockOrder
+	me.publishOrderStatus(stockOrder, "IN_PROGRESS", 0)
+	if !me.queueOrder(stockOrder) {
+		// intakeFull has already made sure there is room, this only guards against the send ever waiting under the mutex
+		return ErrEngineBusy
+	}
+	return nil
 }
 
-func (me *MatchingEngine) RemoveOrder(orderID string, priceKey float64) {
+func (me *MatchingEngine) RemoveOrder(orderID string, priceKey money.Money, isBuy bool) {
 	me.updateChannel <- &UpdateParams{
 		OrderID:  orderID,
 		PriceKey: priceKey,
+		IsBuy:    isBuy,
+	}
+}
+
+// Waits for the update loop to apply the amend, so the caller knows how it went.
+func (me *MatchingEngine) AmendOrder(params *AmendParams) *AmendResult {
+	params.done = make(chan *AmendResult, 1)
+	me.updateChannel <- &UpdateParams{
+		OrderID:  params.OrderID,
+		PriceKey: params.PriceKey,
+		IsBuy:    params.IsBuy,
+		Amend:    params,
+	}
+	return <-params.done
+}
+
+// Only ever run from the update loop. A quantity reduction changes the order where it rests, so it keeps its time priority.
+// A price change or quantity increase takes it out of the book and puts it at the back of the queue for its new price.
+// Sell orders move the quantity delta in or out of escrow. An increase the seller can't cover is refused.
+func (me *MatchingEngine) amendOrder(params *AmendParams) *AmendResult {
+	me.inputMutex.Lock()
+	defer me.inputMutex.Unlock()
+	var book matchingEngineStructures.OrderBookInterface = me.SellOrderBook
+	if params.IsBuy {
+		book = me.BuyOrderBook
+	}
+	removeParams := &matchingEngineStructures.RemoveParams{
+		OrderID:  params.OrderID,
+		PriceKey: params.PriceKey,
+	}
+	current := book.FindOrder(removeParams)
+	if current == nil {
+		return &AmendResult{Err: ErrOrderNotResting}
+	}
+	if IsImmediate(current) {
+		return &AmendResult{Err: fmt.Errorf("%s orders can't be amended", current.GetTimeInForce())}
+	}
+	quantity := current.GetQuantity()
+	if params.Quantity != 0 {
+		quantity = params.Quantity
 	}
+	price := current.GetPrice()
+	if params.Price != 0 {
+		price = params.Price
+	}
+	if quantity < 0 || price < 0 {
+		return &AmendResult{Err: fmt.Errorf("quantity and price can't be negative")}
+	}
+	if price != current.GetPrice() && current.GetOrderType() == order.OrderTypeMarket {
+		return &AmendResult{Err: fmt.Errorf("market orders have no price to amend")}
+	}
+	delta := quantity - current.GetQuantity()
+	requeue := delta > 0 || price != current.GetPrice()
+	escrowChanges := !current.GetIsBuy() && delta != 0
+
+	if requeue {
+		if escrowChanges && delta > 0 {
+			err := me.AdjustEscrow(current, delta)
+			if err != nil {
+				return &AmendResult{Err: err}
+			}
+		}
+		if book.RemoveOrder(removeParams) == nil {
+			if escrowChanges && delta > 0 {
+				me.AdjustEscrow(current, -delta)
+			}
+			return &AmendResult{Err: ErrOrderNotResting}
+		}
+		current.SetQuantity(quantity)
+		current.SetPrice(price)
+		current.Requeue(me.now())
+		book.AddOrder(current)
+		if escrowChanges && delta < 0 {
+			me.releaseEscrow(current, delta)
+		}
+	} else {
+		if book.ReduceOrder(removeParams, quantity) == nil {
+			return &AmendResult{Err: ErrOrderNotResting}
+		}
+		if escrowChanges {
+			me.releaseEscrow(current, delta)
+		}
+	}
+
+	me.saveOrder(current)
+	// a refused amend changes nothing, so only the ones that happened are journaled
+	me.journal(matchingEngineStructures.JournalEntry{
+		Type:     matchingEngineStructures.JournalAmendOrder,
+		OrderID:  params.OrderID,
+		PriceKey: params.PriceKey,
+		IsBuy:    params.IsBuy,
+		Quantity: params.Quantity,
+		Price:    params.Price,
+	})
+	me.publishBookTop()
+	// wake the matching loop, the new price may cross. A full queue means it has orders to take, and it looks at the book again once it has them
+	if requeue && !me.queueOrder(current) {
+		println("Intake queue full. Leaving amended order for the matching loop's next pass: ", current.GetId())
+	}
+	return &AmendResult{Order: current, QuantityDelta: delta, Requeued: requeue}
 }
 
-func (me *MatchingEngine) GetPrice() float64 {
+// The amend has already happened, so a failed release is logged rather than undone.
+func (me *MatchingEngine) releaseEscrow(stockOrder order.StockOrderInterface, delta int) {
+	err := me.AdjustEscrow(stockOrder, delta)
+	if err != nil {
+		println("Error releasing escrowed shares: ", err.Error())
+	}
+}
+
+func (me *MatchingEngine) GetPrice() money.Money {
 	return me.SellOrderBook.GetBestPrice()
 }
 
+func (me *MatchingEngine) GetBidPrice() money.Money {
+	return me.BuyOrderBook.GetBestPrice()
+}
+
+func (me *MatchingEngine) GetAskPrice() money.Money {
+	return me.SellOrderBook.GetBestPrice()
+}
+
+// Price of the most recent trade, or 0 if the stock hasn't traded since the engine started.
+func (me *MatchingEngine) GetLastPrice() money.Money {
+	trade, ok := me.TradeTape.GetLastTrade()
+	if !ok {
+		return 0
+	}
+	return trade.Price
+}
+
+// Newest first.
+func (me *MatchingEngine) GetRecentTrades(limit int) []matchingEngineStructures.Trade {
+	return me.TradeTape.GetRecentTrades(limit)
+}
+
+// Top depth price levels on each side of the book. Market orders have no price, so they are not included.
+func (me *MatchingEngine) GetDepth(depth int) ([]matchingEngineStructures.PriceLevel, []matchingEngineStructures.PriceLevel) {
+	return me.BuyOrderBook.GetPriceLevels(depth), me.SellOrderBook.GetPriceLevels(depth)
+}
+
+func (me *MatchingEngine) GetBookTop() network.BookTop {
+	bookTop := network.BookTop{StockID: me.StockId}
+	bids, asks := me.GetDepth(1)
+	if len(bids) > 0 {
+		bookTop.BidPrice = bids[0].Price
+		bookTop.BidQuantity = bids[0].Quantity
+	}
+	if len(asks) > 0 {
+		bookTop.AskPrice = asks[0].Price
+		bookTop.AskQuantity = asks[0].Quantity
+	}
+	return bookTop
+}
+
+// Only publishes when the top of the book actually moved since the last time.
+func (me *MatchingEngine) publishBookTop() {
+	bookTop := me.GetBookTop()
+	me.bookTopMutex.Lock()
+	defer me.bookTopMutex.Unlock()
+	if bookTop == me.lastBookTop {
+		return
+	}
+	me.lastBookTop = bookTop
+	me.MarketData.Publish(matchingEngineStructures.MarketDataEvent{
+		Type:    matchingEngineStructures.EventBookTop,
+		StockID: me.StockId,
+		Data:    bookTop,
+	})
+}
+
+func (me *MatchingEngine) publishTrade(trade matchingEngineStructures.Trade) {
+	me.MarketData.Publish(matchingEngineStructures.MarketDataEvent{
+		Type:    matchingEngineStructures.EventTrade,
+		StockID: me.StockId,
+		Data: network.StockTrade{
+			StockID: me.StockId,
+			Trade: network.Trade{
+				Price:         trade.Price,
+				Quantity:      trade.Quantity,
+				Timestamp:     trade.Timestamp,
+				AggressorSide: trade.AggressorSide,
+			},
+		},
+	})
+}
+
+// Call once the order's quantity has been reduced by the fill.
+func (me *MatchingEngine) publishFill(stockOrder order.StockOrderInterface, filledQuantity int) {
+	if stockOrder.GetQuantity() == 0 {
+		me.publishOrderStatus(stockOrder, "COMPLETED", filledQuantity)
+	} else {
+		me.publishOrderStatus(stockOrder, "PARTIALLY_COMPLETE", filledQuantity)
+	}
+}
+
+func (me *MatchingEngine) publishOrderStatus(stockOrder order.StockOrderInterface, orderStatus string, filledQuantity int) {
+	me.MarketData.Publish(matchingEngineStructures.MarketDataEvent{
+		Type:    matchingEngineStructures.EventOrderStatus,
+		StockID: me.StockId,
+		UserID:  stockOrder.GetUserID(),
+		Data: network.OrderStatusUpdate{
+			StockTxID:         stockOrder.GetId(),
+			StockID:           me.StockId,
+			OrderStatus:       orderStatus,
+			FilledQuantity:    filledQuantity,
+			RemainingQuantity: stockOrder.GetQuantity(),
+		},
+	})
+}
+
 //fake matching engine mock for testing
 
+var _ MatchingEngineInterface = &FakeMatchingEngine{}
+
 type FakeMatchingEngine struct {
 	ordersCalled  bool
 	updatesCalled bool
@@ -234,7 +1099,13 @@ type FakeMatchingEngine struct {
 	updatesCh     chan struct{}
 }
 
-func (fme *FakeMatchingEngine) AddOrder(o order.StockOrderInterface) {}
+func (fme *FakeMatchingEngine) AddOrder(o order.StockOrderInterface) error { return nil }
+
+func (fme *FakeMatchingEngine) RemoveOrder(orderID string, priceKey money.Money, isBuy bool) {}
+
+func (fme *FakeMatchingEngine) AmendOrder(params *AmendParams) *AmendResult {
+	return &AmendResult{Err: ErrOrderNotResting}
+}
 
 func (fme *FakeMatchingEngine) RunMatchingEngineOrders() {
 	fme.ordersCalled = true
@@ -245,3 +1116,50 @@ func (fme *FakeMatchingEngine) RunMatchingEngineUpdates() {
 	fme.updatesCalled = true
 	close(fme.updatesCh)
 }
+
+func (fme *FakeMatchingEngine) RunMatchingEngineTrades()     {}
+func (fme *FakeMatchingEngine) RunMatchingEngineExpiry()     {}
+func (fme *FakeMatchingEngine) RunMatchingEngineSnapshots()  {}
+func (fme *FakeMatchingEngine) RunMatchingEngineQuarantine() {}
+
+func (fme *FakeMatchingEngine) Stop() *matchingEngineStructures.BookState { return nil }
+
+func (fme *FakeMatchingEngine) GetQuarantinedMatches() []network.QuarantinedMatch { return nil }
+
+func (fme *FakeMatchingEngine) GetIntake() network.EngineIntake { return network.EngineIntake{} }
+
+func (fme *FakeMatchingEngine) Split(corporateActionID string, split stock.Split, cashPrice money.Money) *SplitResult {
+	return &SplitResult{}
+}
+
+func (fme *FakeMatchingEngine) ReleaseQuarantinedMatch(matchID string, action string) error {
+	return nil
+}
+
+func (fme *FakeMatchingEngine) Halt(reason string) bool { return false }
+func (fme *FakeMatchingEngine) Resume() bool            { return false }
+func (fme *FakeMatchingEngine) IsHalted() bool          { return false }
+
+func (fme *FakeMatchingEngine) GetHaltStatus() network.HaltStatus { return network.HaltStatus{} }
+
+func (fme *FakeMatchingEngine) StartAuction() bool { return false }
+func (fme *FakeMatchingEngine) InAuction() bool    { return false }
+
+func (fme *FakeMatchingEngine) Uncross() (network.AuctionResult, error) {
+	return network.AuctionResult{}, nil
+}
+
+func (fme *FakeMatchingEngine) GetPrice() money.Money     { return 0 }
+func (fme *FakeMatchingEngine) GetBidPrice() money.Money  { return 0 }
+func (fme *FakeMatchingEngine) GetAskPrice() money.Money  { return 0 }
+func (fme *FakeMatchingEngine) GetLastPrice() money.Money { return 0 }
+
+func (fme *FakeMatchingEngine) GetDepth(depth int) ([]matchingEngineStructures.PriceLevel, []matchingEngineStructures.PriceLevel) {
+	return nil, nil
+}
+
+func (fme *FakeMatchingEngine) GetRecentTrades(limit int) []matchingEngineStructures.Trade {
+	return nil
+}
+
+func (fme *FakeMatchingEngine) GetBookTop() network.BookTop { return network.BookTop{} }
@@ -0,0 +1,378 @@
+package matchingEngine
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/network"
+	databaseAccessStockOrder "databaseAccessStockOrder"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Stands in for the stock order database. Only what the engine calls is implemented.
+type fakeDatabase struct {
+	databaseAccessStockOrder.DatabaseAccessInterface
+	mutex   sync.Mutex
+	deleted []string
+	updated []string
+}
+
+func (f *fakeDatabase) Delete(id string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeDatabase) Update(stockOrder order.StockOrderInterface) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.updated = append(f.updated, stockOrder.GetId())
+	return nil
+}
+
+type executedMatch struct {
+	buyOrderID  string
+	sellOrderID string
+	quantity    int
+	price       money.Money
+}
+
+// Stands in for the order executor, and the services the engine cancels and escrows through.
+// fail decides which side of a match fails, leave it nil for every match to go through.
+type fakeExecutor struct {
+	mutex     sync.Mutex
+	matches   []executedMatch
+	children  []string // the parent of each child order sent, for the partial fills
+	cancelled []string
+	batches   []int
+	fail      func(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (bool, bool)
+}
+
+func (f *fakeExecutor) execute(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, stockPrice money.Money, matchID string) (network.ExecutorToMatchingEngineJSON, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	result := network.ExecutorToMatchingEngineJSON{}
+	if f.fail != nil {
+		result.IsBuyFailure, result.IsSellFailure = f.fail(buyOrder, sellOrder)
+	}
+	for _, fill := range []order.StockOrderInterface{buyOrder, sellOrder} {
+		if fill.GetParentStockOrderID() != "" {
+			f.children = append(f.children, fill.GetParentStockOrderID())
+		}
+	}
+	if !result.IsBuyFailure && !result.IsSellFailure {
+		f.matches = append(f.matches, executedMatch{
+			buyOrderID:  parentID(buyOrder),
+			sellOrderID: parentID(sellOrder),
+			quantity:    buyOrder.GetQuantity(),
+			price:       stockPrice,
+		})
+	}
+	return result, nil
+}
+
+// A partial fill is sent as a child order of the order it fills.
+func parentID(stockOrder order.StockOrderInterface) string {
+	if stockOrder.GetParentStockOrderID() != "" {
+		return stockOrder.GetParentStockOrderID()
+	}
+	return stockOrder.GetId()
+}
+
+func (f *fakeExecutor) executeBatch(matches []network.MatchingEngineToExecutionJSON) ([]network.MatchExecutionResult, error) {
+	f.mutex.Lock()
+	f.batches = append(f.batches, len(matches))
+	f.mutex.Unlock()
+	results := make([]network.MatchExecutionResult, len(matches))
+	for i, match := range matches {
+		buyOrder := order.New(order.NewStockOrderParams{NewEntityParams: entity.NewEntityParams{ID: match.BuyOrderID}, IsBuy: true, Quantity: match.Quantity})
+		sellOrder := order.New(order.NewStockOrderParams{NewEntityParams: entity.NewEntityParams{ID: match.SellOrderID}, Quantity: match.Quantity})
+		results[i].MatchID = match.MatchID
+		results[i].ExecutorToMatchingEngineJSON, _ = f.execute(buyOrder, sellOrder, match.StockPrice, match.MatchID)
+	}
+	return results, nil
+}
+
+func (f *fakeExecutor) cancel(stockOrder order.StockOrderInterface) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.cancelled = append(f.cancelled, stockOrder.GetId())
+	return nil
+}
+
+func (f *fakeExecutor) escrow(stockOrder order.StockOrderInterface, delta int) error {
+	return nil
+}
+
+func (f *fakeExecutor) reduce(stockOrder order.StockOrderInterface, quantity int) error {
+	return nil
+}
+
+// What was matched, as "buy/sell quantity@price".
+func (f *fakeExecutor) matched() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	matched := []string{}
+	for _, match := range f.matches {
+		matched = append(matched, fmt.Sprintf("%s/%s %d@%s", match.buyOrderID, match.sellOrderID, match.quantity, match.price))
+	}
+	return matched
+}
+
+func (f *fakeExecutor) cancelledOrders() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.cancelled...)
+}
+
+func (f *fakeExecutor) childOrders() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.children...)
+}
+
+func (f *fakeExecutor) batchSizes() []int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]int{}, f.batches...)
+}
+
+// Starts an engine's matching loop on the fakes, stopped when the test ends. Leave fields of params empty for the defaults.
+func newTestEngine(t *testing.T, params *NewMatchingEngineParams) (*MatchingEngine, *fakeExecutor) {
+	me, executor := newIdleTestEngine(params)
+	startTestEngine(t, me)
+	return me, executor
+}
+
+// An engine on the fakes whose matching loop hasn't started, so nothing takes orders off its intake.
+func newIdleTestEngine(params *NewMatchingEngineParams) (*MatchingEngine, *fakeExecutor) {
+	executor := &fakeExecutor{}
+	if params.StockID == "" {
+		params.StockID = "stock"
+	}
+	if params.InitalOrders == nil {
+		params.InitalOrders = &[]order.StockOrderInterface{}
+	}
+	params.SendToOrderExecutionFunc = executor.execute
+	params.CancelUnfilledOrderFunc = executor.cancel
+	params.AdjustEscrowFunc = executor.escrow
+	params.ReduceUnfilledOrderFunc = executor.reduce
+	params.DatabaseManager = &fakeDatabase{}
+	return newMatchingEngine(params), executor
+}
+
+func startTestEngine(t *testing.T, me *MatchingEngine) {
+	go me.RunMatchingEngineOrders()
+	t.Cleanup(func() { me.Stop() })
+}
+
+var testStart = time.Date(2026, 1, 5, 9, 30, 0, 0, time.UTC)
+var testOrders = 0
+
+// A limit order queued after every order made before it. A price of 0 makes a market order.
+func testOrder(id string, userID string, isBuy bool, quantity int, price money.Money) *order.StockOrder {
+	testOrders++
+	orderType := order.OrderTypeLimit
+	if price == 0 {
+		orderType = order.OrderTypeMarket
+	}
+	return order.New(order.NewStockOrderParams{
+		NewEntityParams: entity.NewEntityParams{ID: id},
+		StockID:         "stock",
+		UserID:          userID,
+		IsBuy:           isBuy,
+		OrderType:       orderType,
+		Quantity:        quantity,
+		Price:           price,
+		QueuedAt:        testStart.Add(time.Duration(testOrders) * time.Millisecond),
+	})
+}
+
+// A stop order to become a market order once the last trade reaches stopPrice.
+func stopOrder(id string, userID string, isBuy bool, quantity int, stopPrice money.Money) *order.StockOrder {
+	stockOrder := testOrder(id, userID, isBuy, quantity, 0)
+	stockOrder.OrderType = order.OrderTypeStop
+	stockOrder.StopPrice = stopPrice
+	return stockOrder
+}
+
+// Adds the orders one at a time, each once the loop has matched everything before it.
+func addOrders(t *testing.T, me *MatchingEngine, stockOrders ...order.StockOrderInterface) {
+	t.Helper()
+	for _, stockOrder := range stockOrders {
+		err := me.AddOrder(stockOrder)
+		if err != nil {
+			t.Fatalf("adding %s: %v", stockOrder.GetId(), err)
+		}
+		me.settle()
+	}
+}
+
+func bookIDs(orders []order.StockOrderInterface) []string {
+	ids := []string{}
+	for _, stockOrder := range orders {
+		ids = append(ids, fmt.Sprintf("%s:%d", stockOrder.GetId(), stockOrder.GetQuantity()))
+	}
+	return ids
+}
+
+// For what the loop does without being asked, like matching an amended order. Fails the test if it doesn't happen within a second.
+func eventually(t *testing.T, what string, happened func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !happened() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s didn't happen", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func expectStrings(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: got %v, wanted %v", what, got, want)
+	}
+}
+
+func TestLimitOrdersMatchAtTheRestingPrice(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	addOrders(t, me,
+		testOrder("sell-1", "alice", false, 5, 1000),
+		testOrder("sell-2", "bob", false, 5, 1000),
+		testOrder("buy-1", "carol", true, 7, 1100),
+	)
+	// the buy crosses at the price already resting, oldest sell first
+	expectStrings(t, "matches", executor.matched(), "buy-1/sell-1 5@10.00", "buy-1/sell-2 2@10.00")
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-2:3")
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()))
+}
+
+func TestImmediateOrCancelCancelsWhatDoesntFill(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	ioc := testOrder("buy-ioc", "bob", true, 5, 1000)
+	ioc.TimeInForce = order.TimeInForceIOC
+	addOrders(t, me, testOrder("sell-1", "alice", false, 3, 1000), ioc)
+	expectStrings(t, "matches", executor.matched(), "buy-ioc/sell-1 3@10.00")
+	expectStrings(t, "cancelled", executor.cancelledOrders(), "buy-ioc")
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()))
+}
+
+func TestFillOrKill(t *testing.T) {
+	tests := []struct {
+		name    string
+		matched []string
+	}{
+		{name: "fills", matched: []string{"buy-fok/sell-1 3@10.00", "buy-fok/sell-2 1@10.00"}},
+		// sell-2 is priced above it, so there is too little
+		{name: "killed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+			var secondPrice money.Money = 1000
+			if test.matched == nil {
+				secondPrice = 1010
+			}
+			fok := testOrder("buy-fok", "bob", true, 4, 1000)
+			fok.TimeInForce = order.TimeInForceFOK
+			addOrders(t, me,
+				testOrder("sell-1", "alice", false, 3, 1000),
+				testOrder("sell-2", "carol", false, 2, secondPrice),
+				fok,
+			)
+			expectStrings(t, "matches", executor.matched(), test.matched...)
+			if test.matched == nil {
+				expectStrings(t, "cancelled", executor.cancelledOrders(), "buy-fok")
+				expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:3", "sell-2:2")
+			}
+			expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()))
+		})
+	}
+}
+
+func TestStopsTriggerInACascade(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	addOrders(t, me,
+		testOrder("buy-1", "bob", true, 2, 990),
+		testOrder("buy-2", "bob", true, 5, 950),
+		stopOrder("stop-1", "dave", false, 2, 995),
+		stopOrder("stop-2", "erin", false, 3, 990),
+		testOrder("sell-a", "alice", false, 1, 995),
+	)
+	expectStrings(t, "stops", bookIDs(me.TriggerBook.GetOrders()), "stop-1:2", "stop-2:3")
+	// trading at 9.95 sets off stop-1, whose trade at 9.90 sets off stop-2
+	addOrders(t, me, testOrder("buy-t", "carol", true, 1, 995))
+	expectStrings(t, "matches", executor.matched(), "buy-t/sell-a 1@9.95", "buy-1/stop-1 2@9.90", "buy-2/stop-2 3@9.50")
+	expectStrings(t, "stops", bookIDs(me.TriggerBook.GetOrders()))
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()), "buy-2:2")
+}
+
+func TestExpiredOrdersAreTakenOutTogether(t *testing.T) {
+	goodTill := func(stockOrder *order.StockOrder) *order.StockOrder {
+		stockOrder.TimeInForce = order.TimeInForceGTD
+		stockOrder.ExpiresAt = testStart.Add(time.Hour)
+		return stockOrder
+	}
+	me, executor := newIdleTestEngine(&NewMatchingEngineParams{InitalOrders: &[]order.StockOrderInterface{
+		goodTill(testOrder("buy-1", "carol", true, 2, 950)),
+		testOrder("buy-2", "dave", true, 2, 940),
+		goodTill(testOrder("sell-1", "alice", false, 5, 1000)),
+		goodTill(stopOrder("stop-1", "bob", false, 1, 900)),
+	}})
+	expired, expiredStops := me.takeExpired(testStart.Add(time.Hour))
+	expectStrings(t, "expired", bookIDs(expired), "buy-1:2", "sell-1:5")
+	expectStrings(t, "expired stops", bookIDs(expiredStops), "stop-1:1")
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()), "buy-2:2")
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()))
+	// nothing is cancelled while the input mutex is held, the sweep does that once it has let go
+	expectStrings(t, "cancelled", executor.cancelledOrders())
+	if !me.inputMutex.TryLock() {
+		t.Fatal("takeExpired kept hold of the input mutex")
+	}
+	me.inputMutex.Unlock()
+}
+
+func TestAmendKeepsPriorityOnlyWhenQuantityGoesDown(t *testing.T) {
+	me, _ := newTestEngine(t, &NewMatchingEngineParams{})
+	go me.RunMatchingEngineUpdates()
+	addOrders(t, me,
+		testOrder("sell-1", "alice", false, 5, 1000),
+		testOrder("sell-2", "bob", false, 5, 1000),
+	)
+	result := me.AmendOrder(&AmendParams{OrderID: "sell-1", PriceKey: 1000, Quantity: 3})
+	if result.Err != nil || result.Requeued || result.QuantityDelta != -2 {
+		t.Fatalf("got %+v, wanted 2 taken off in place", result)
+	}
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:3", "sell-2:5")
+
+	result = me.AmendOrder(&AmendParams{OrderID: "sell-1", PriceKey: 1000, Quantity: 4})
+	if result.Err != nil || !result.Requeued {
+		t.Fatalf("got %+v, wanted it requeued", result)
+	}
+	// the requeue wakes the loop, which takes the best orders out of the book to look at them
+	eventually(t, "sell-1 going behind sell-2", func() bool {
+		return fmt.Sprint(bookIDs(me.SellOrderBook.GetOrders())) == "[sell-2:5 sell-1:4]"
+	})
+}
+
+func TestAmendingToACrossingPriceTrades(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	go me.RunMatchingEngineUpdates()
+	addOrders(t, me,
+		testOrder("buy-1", "carol", true, 2, 950),
+		testOrder("sell-1", "alice", false, 5, 1000),
+	)
+	result := me.AmendOrder(&AmendParams{OrderID: "sell-1", PriceKey: 1000, Price: 950})
+	if result.Err != nil || !result.Requeued {
+		t.Fatalf("got %+v, wanted it requeued", result)
+	}
+	eventually(t, "matching the amended order", func() bool { return len(executor.matched()) > 0 })
+	me.settle()
+	expectStrings(t, "matches", executor.matched(), "buy-1/sell-1 2@9.50")
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:3")
+}
@@ -0,0 +1,90 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/entities/stock"
+)
+
+// The resting side's book, and which of the pair is the aggressor.
+func (me *MatchingEngine) restingSide(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (matchingEngineStructures.OrderBookInterface, order.StockOrderInterface, order.StockOrderInterface) {
+	if AggressorSide(buyOrder, sellOrder) == matchingEngineStructures.AggressorSell {
+		return me.BuyOrderBook, sellOrder, buyOrder
+	}
+	return me.SellOrderBook, buyOrder, sellOrder
+}
+
+// The resting orders a pro-rata stock shares the aggressor out over, oldest first.
+// Returns nil when the pair should just trade FIFO: the stock is FIFO, an auction is uncrossing, the resting order is a market order,
+// or nobody else at its price could share the fill. The aggressor's own orders are left out, self trade prevention deals with them one at a time.
+func (me *MatchingEngine) proRataLevel(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) []order.StockOrderInterface {
+	restingBook, aggressor, resting := me.restingSide(buyOrder, sellOrder)
+	if restingBook.GetAllocationPolicy() == stock.AllocationFIFO || me.auctionVolume > 0 || resting.GetOrderType() != order.OrderTypeLimit {
+		return nil
+	}
+	// the held resting order came off the front of its level, so it is the oldest
+	level := []order.StockOrderInterface{resting}
+	if restingBook.GetBestPrice() == resting.GetPrice() {
+		level = append(level, restingBook.GetBestLevel()...)
+	}
+	shared := make([]order.StockOrderInterface, 0, len(level))
+	for _, stockOrder := range level {
+		if stockOrder.GetUserID() != aggressor.GetUserID() {
+			shared = append(shared, stockOrder)
+		}
+	}
+	if len(shared) < 2 {
+		return nil
+	}
+	return shared
+}
+
+// Shares the aggressor out over the level, one trade per resting order. The resting orders stay in their book while they trade,
+// so a partly filled one keeps its place in the queue. Returns what the matching loop holds afterwards, which is
+// whatever is left of the aggressor and never a resting order.
+func (me *MatchingEngine) matchProRata(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, level []order.StockOrderInterface, stockPrice money.Money) (order.StockOrderInterface, order.StockOrderInterface) {
+	restingBook, aggressor, held := me.restingSide(buyOrder, sellOrder)
+	aggressorIsBuy := aggressor == buyOrder
+	restingBook.ReturnOrder(held)
+	allocations := matchingEngineStructures.AllocateProRata(level, aggressor.GetVisibleQuantity(), restingBook.GetAllocationPolicy() == stock.AllocationProRataTopOrder)
+	println("Sharing order: ", aggressor.GetId(), " between ", len(allocations), " resting orders")
+	for _, allocation := range allocations {
+		resting := allocation.Order
+		removeParams := &matchingEngineStructures.RemoveParams{
+			OrderID:  resting.GetId(),
+			PriceKey: resting.GetPrice(),
+		}
+		// the book may have changed since the level was read, e.g. a cancel
+		if restingBook.FindOrder(removeParams) == nil {
+			continue
+		}
+		buy, sell := aggressor, resting
+		if !aggressorIsBuy {
+			buy, sell = resting, aggressor
+		}
+		me.reserveTrade(buy, sell, allocation.Quantity, stockPrice)
+		if resting.GetQuantity() == 0 {
+			restingBook.RemoveOrder(removeParams)
+		} else if me.refreshIceberg(resting) {
+			// the new slice goes to the back of the level
+			restingBook.RemoveOrder(removeParams)
+			restingBook.AddOrder(resting)
+		}
+	}
+	if aggressor.GetQuantity() == 0 {
+		return nil, nil
+	}
+	if me.refreshIceberg(aggressor) {
+		if aggressorIsBuy {
+			me.BuyOrderBook.AddOrder(aggressor)
+		} else {
+			me.SellOrderBook.AddOrder(aggressor)
+		}
+		return nil, nil
+	}
+	if aggressorIsBuy {
+		return aggressor, nil
+	}
+	return nil, aggressor
+}
@@ -0,0 +1,47 @@
+package matchingEngine
+
+import (
+	"Shared/entities/stock"
+	"testing"
+)
+
+func TestProRataSharesTheAggressorOverTheLevel(t *testing.T) {
+	tests := []struct {
+		policy   string
+		matched  []string
+		children []string
+		asks     []string
+	}{
+		{
+			// 20 over 10, 30 and 20 is 3.33, 10 and 6.67. The share lost rounding down goes to the oldest
+			policy:   stock.AllocationProRata,
+			matched:  []string{"buy-erin/sell-bob 4@10.00", "buy-erin/sell-carol 10@10.00", "buy-erin/sell-dave 6@10.00"},
+			children: []string{"buy-erin", "sell-bob", "buy-erin", "sell-carol", "sell-dave"},
+			asks:     []string{"sell-bob:6", "sell-carol:20", "sell-dave:14"},
+		},
+		{
+			// bob's order fills first, the 10 left over carol's 30 and dave's 20
+			policy:   stock.AllocationProRataTopOrder,
+			matched:  []string{"buy-erin/sell-bob 10@10.00", "buy-erin/sell-carol 6@10.00", "buy-erin/sell-dave 4@10.00"},
+			children: []string{"buy-erin", "buy-erin", "sell-carol", "sell-dave"},
+			asks:     []string{"sell-carol:24", "sell-dave:16"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			me, executor := newTestEngine(t, &NewMatchingEngineParams{AllocationPolicy: test.policy})
+			addOrders(t, me,
+				testOrder("sell-bob", "bob", false, 10, 1000),
+				testOrder("sell-carol", "carol", false, 30, 1000),
+				testOrder("sell-dave", "dave", false, 20, 1000),
+				testOrder("buy-erin", "erin", true, 20, 1000),
+			)
+			expectStrings(t, "matches", executor.matched(), test.matched...)
+			// a side the trade doesn't finish is sent as a child order
+			expectStrings(t, "child orders", executor.childOrders(), test.children...)
+			// partly filled orders keep their place in the queue
+			expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), test.asks...)
+			expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/network"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// How a quarantined match can be released. EXECUTED is the executor answering a retry.
+const (
+	QuarantineRetry    = "RETRY"   // try the executor again now, however many attempts it has had
+	QuarantineRequeue  = "REQUEUE" // the operator has checked the trade never happened. Both orders go back in the book
+	QuarantineCancel   = "CANCEL"  // both orders are cancelled
+	QuarantineExecuted = "EXECUTED"
+)
+
+var ErrMatchNotQuarantined = errors.New("match is not in quarantine")
+
+type quarantineResolution struct {
+	matchID string
+	action  string
+	result  network.ExecutorToMatchingEngineJSON // EXECUTED only
+	done    chan error
+}
+
+// Holds the match's quantity out of trading, when the executor didn't answer. Nobody knows yet if the trade happened,
+// so the quantity stays reserved from both orders until the executor says, or an operator does. The rest of the orders trade on.
+func (me *MatchingEngine) quarantineMatch(matchID string, buyFill order.StockOrderInterface, sellFill order.StockOrderInterface, quantity int, stockPrice money.Money, err error) {
+	println("Quarantining match: ", matchID, " of ", buyFill.GetId(), " and ", sellFill.GetId(), ": ", err.Error())
+	match := matchingEngineStructures.QuarantinedMatch{
+		ID:            matchID,
+		BuyOrder:      buyFill.ToParams(),
+		SellOrder:     sellFill.ToParams(),
+		Quantity:      quantity,
+		Price:         stockPrice,
+		QuarantinedAt: me.now(),
+	}
+	match.Failed(err.Error(), me.now())
+	me.Quarantine.Add(match)
+}
+
+// Sends quarantined matches to the executor again as their retries come due. The executor answers a match it already ran
+// with the answer it gave, so whether the first attempt traded or not, the retry's answer is the truth.
+func (me *MatchingEngine) RunMatchingEngineQuarantine() {
+	if !me.startWorker() {
+		return
+	}
+	defer me.workers.Done()
+	ticker := time.NewTicker(matchingEngineStructures.QuarantineRetryDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, match := range me.Quarantine.Due(now) {
+				me.retryQuarantined(match.ID)
+			}
+		case <-me.stopChannel:
+			return
+		}
+	}
+}
+
+func (me *MatchingEngine) retryQuarantined(matchID string) {
+	match, ok := me.Quarantine.Begin(matchID)
+	if !ok {
+		return
+	}
+	println("Retrying quarantined match: ", matchID, " attempt ", match.Attempts+1)
+	buyFill, sellFill := fills(order.New(match.BuyOrder), order.New(match.SellOrder), match.Quantity)
+	result, err := me.SendToOrderExection(buyFill, sellFill, match.Price, matchID)
+	if err != nil {
+		me.Quarantine.Fail(matchID, err.Error(), me.now())
+		if failed, ok := me.Quarantine.Get(matchID); ok && failed.NextAttemptAt.IsZero() {
+			println("Quarantined match: ", matchID, " is out of retries. Waiting for an operator")
+		}
+		return
+	}
+	me.resolve(&quarantineResolution{matchID: matchID, action: QuarantineExecuted, result: result})
+}
+
+// RETRY happens on the next retry sweep. REQUEUE and CANCEL are done by the matching loop before this returns.
+func (me *MatchingEngine) ReleaseQuarantinedMatch(matchID string, action string) error {
+	switch action {
+	case QuarantineRetry:
+		if !me.Quarantine.Retry(matchID, me.now()) {
+			return ErrMatchNotQuarantined
+		}
+		return nil
+	case QuarantineRequeue, QuarantineCancel:
+		if _, ok := me.Quarantine.Begin(matchID); !ok {
+			if _, ok := me.Quarantine.Get(matchID); ok {
+				return fmt.Errorf("match %s is being retried, try again once the attempt is over", matchID)
+			}
+			return ErrMatchNotQuarantined
+		}
+		return me.resolve(&quarantineResolution{matchID: matchID, action: action})
+	default:
+		return fmt.Errorf("unknown action %s, expected %s, %s or %s", action, QuarantineRetry, QuarantineRequeue, QuarantineCancel)
+	}
+}
+
+// Hands the resolution to the matching loop and waits for it to be applied.
+func (me *MatchingEngine) resolve(resolution *quarantineResolution) error {
+	resolution.done = make(chan error, 1)
+	me.resolveChannel <- resolution
+	return <-resolution.done
+}
+
+// Only ever run from the matching loop. A side the executor failed is cancelled, and a side that didn't trade gets the quantity back,
+// in the book where it rests or at the front of its price level.
+func (me *MatchingEngine) resolveQuarantined(resolution *quarantineResolution) {
+	match, ok := me.Quarantine.Remove(resolution.matchID)
+	if !ok {
+		println("Quarantined match: ", resolution.matchID, " was already resolved")
+		resolution.done <- ErrMatchNotQuarantined
+		return
+	}
+	println("Resolving quarantined match: ", match.ID, " ", resolution.action)
+	buyOrder := me.reservedOrder(match.BuyOrder)
+	sellOrder := me.reservedOrder(match.SellOrder)
+	entry := matchingEngineStructures.JournalEntry{
+		Type:        matchingEngineStructures.JournalResolveMatch,
+		MatchID:     match.ID,
+		BuyOrderID:  match.BuyOrder.ID,
+		SellOrderID: match.SellOrder.ID,
+		Reason:      resolution.action,
+	}
+	if resolution.action == QuarantineExecuted {
+		entry.Result = &resolution.result
+	}
+	me.journal(entry)
+	switch {
+	case resolution.action == QuarantineCancel:
+		me.cancelOrder(buyOrder, match.Quantity)
+		me.cancelOrder(sellOrder, match.Quantity)
+	case resolution.action == QuarantineExecuted && resolution.result.IsBuyFailure:
+		println("Buy Order Failed: ", buyOrder.GetId())
+		me.cancelOrder(buyOrder, match.Quantity)
+		me.giveBack(sellOrder, match.Quantity)
+	case resolution.action == QuarantineExecuted && resolution.result.IsSellFailure:
+		println("Sell Order Failed: ", sellOrder.GetId())
+		me.cancelOrder(sellOrder, match.Quantity)
+		me.giveBack(buyOrder, match.Quantity)
+	case resolution.action == QuarantineExecuted:
+		me.confirmTrade(buyOrder, sellOrder, matchingEngineStructures.Trade{
+			Price:         match.Price,
+			Quantity:      match.Quantity,
+			Timestamp:     match.QuarantinedAt,
+			AggressorSide: AggressorSide(order.New(match.BuyOrder), order.New(match.SellOrder)),
+		})
+	default:
+		me.giveBack(buyOrder, match.Quantity)
+		me.giveBack(sellOrder, match.Quantity)
+	}
+	me.publishBookTop()
+	resolution.done <- nil
+}
+
+// Takes the order out of trading and cancels all of it, with the quantity the match reserved.
+func (me *MatchingEngine) cancelOrder(stockOrder order.StockOrderInterface, quantity int) {
+	me.reserved.drop(stockOrder)
+	me.reserved.release(stockOrder, quantity)
+	me.takeOutOfBook(stockOrder)
+	me.addQuantity(stockOrder, quantity)
+	me.cancelRemainder(stockOrder)
+}
+
+// An IOC or FOK order's pass is long over, so what is left of it is cancelled rather than booked.
+func (me *MatchingEngine) requeue(stockOrder order.StockOrderInterface) {
+	if stockOrder.GetQuantity() == 0 {
+		return
+	}
+	if IsImmediate(stockOrder) {
+		me.cancelRemainder(stockOrder)
+		return
+	}
+	var book matchingEngineStructures.OrderBookInterface = me.SellOrderBook
+	if stockOrder.GetIsBuy() {
+		book = me.BuyOrderBook
+	}
+	if me.refreshIceberg(stockOrder) {
+		book.AddOrder(stockOrder)
+	} else {
+		book.ReturnOrder(stockOrder)
+	}
+}
+
+// Oldest first.
+func (me *MatchingEngine) GetQuarantinedMatches() []network.QuarantinedMatch {
+	quarantined := me.Quarantine.GetMatches()
+	matches := make([]network.QuarantinedMatch, len(quarantined))
+	for i, match := range quarantined {
+		matches[i] = network.QuarantinedMatch{
+			MatchID:       match.ID,
+			StockID:       me.StockId,
+			BuyOrderID:    match.BuyOrder.ID,
+			SellOrderID:   match.SellOrder.ID,
+			BuyerID:       match.BuyOrder.UserID,
+			SellerID:      match.SellOrder.UserID,
+			Quantity:      match.Quantity,
+			StockPrice:    match.Price,
+			Attempts:      match.Attempts,
+			LastError:     match.LastError,
+			QuarantinedAt: match.QuarantinedAt,
+		}
+		if !match.NextAttemptAt.IsZero() {
+			nextAttemptAt := match.NextAttemptAt
+			matches[i].NextAttemptAt = &nextAttemptAt
+		}
+	}
+	return matches
+}
@@ -0,0 +1,76 @@
+package matchingEngine
+
+import (
+	"Shared/entities/order"
+	"fmt"
+)
+
+// Self trade prevention modes. What happens when a user's buy meets their own sell.
+const (
+	SelfTradeCancelNewest = "CANCEL_NEWEST" // the order that arrived last is cancelled, the resting one stays
+	SelfTradeCancelOldest = "CANCEL_OLDEST" // the resting order is cancelled, the new one carries on matching
+	SelfTradeCancelBoth   = "CANCEL_BOTH"
+	SelfTradeDecrement    = "DECREMENT" // both are cut by the smaller quantity without trading. Whichever reaches zero is cancelled
+)
+
+const DefaultSelfTradePrevention = SelfTradeCancelNewest
+
+// An empty mode gives the default.
+func ParseSelfTradePrevention(mode string) (string, error) {
+	switch mode {
+	case "":
+		return DefaultSelfTradePrevention, nil
+	case SelfTradeCancelNewest, SelfTradeCancelOldest, SelfTradeCancelBoth, SelfTradeDecrement:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown self trade prevention mode: %s", mode)
+}
+
+func IsSelfTrade(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) bool {
+	return buyOrder.GetUserID() != "" && buyOrder.GetUserID() == sellOrder.GetUserID()
+}
+
+// Called by the matching loop instead of executing a self trade. Returns the orders the loop should keep holding, nil for any that are gone.
+// Cancelled orders have their transactions cancelled and escrow returned like any other unfilled remainder.
+func (me *MatchingEngine) preventSelfTrade(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (order.StockOrderInterface, order.StockOrderInterface) {
+	println("Self trade prevented (", me.SelfTradePrevention, ") between buy order: ", buyOrder.GetId(), " and sell order: ", sellOrder.GetId())
+	buyIsNewer := buyOrder.GetTimePriority().After(sellOrder.GetTimePriority())
+	switch me.SelfTradePrevention {
+	case SelfTradeCancelOldest:
+		if buyIsNewer {
+			me.cancelRemainder(sellOrder)
+			return buyOrder, nil
+		}
+		me.cancelRemainder(buyOrder)
+		return nil, sellOrder
+	case SelfTradeCancelBoth:
+		me.cancelRemainder(buyOrder)
+		me.cancelRemainder(sellOrder)
+		return nil, nil
+	case SelfTradeDecrement:
+		quantity := min(buyOrder.GetQuantity(), sellOrder.GetQuantity())
+		return me.decrementOrder(buyOrder, quantity), me.decrementOrder(sellOrder, quantity)
+	default:
+		if buyIsNewer {
+			me.cancelRemainder(buyOrder)
+			return nil, sellOrder
+		}
+		me.cancelRemainder(sellOrder)
+		return buyOrder, nil
+	}
+}
+
+// Takes quantity off an order without it trading. An order with nothing left is cancelled.
+func (me *MatchingEngine) decrementOrder(stockOrder order.StockOrderInterface, quantity int) order.StockOrderInterface {
+	if stockOrder.GetQuantity() <= quantity {
+		me.cancelRemainder(stockOrder)
+		return nil
+	}
+	stockOrder.SetQuantity(stockOrder.GetQuantity() - quantity)
+	me.saveOrder(stockOrder)
+	err := me.ReduceUnfilledOrder(stockOrder, quantity)
+	if err != nil {
+		println("Error: ", err.Error())
+	}
+	return stockOrder
+}
@@ -0,0 +1,35 @@
+package matchingEngine
+
+import (
+	"testing"
+)
+
+func TestSelfTradePrevention(t *testing.T) {
+	// alice's buy arrives after her own sell, with bob's sell behind it at the same price
+	tests := []struct {
+		mode      string
+		matched   []string
+		cancelled []string
+		asks      []string
+		bids      []string
+	}{
+		{mode: SelfTradeCancelNewest, cancelled: []string{"buy-alice"}, asks: []string{"sell-alice:5", "sell-bob:5"}},
+		{mode: SelfTradeCancelOldest, matched: []string{"buy-alice/sell-bob 3@10.00"}, cancelled: []string{"sell-alice"}, asks: []string{"sell-bob:2"}},
+		{mode: SelfTradeCancelBoth, cancelled: []string{"buy-alice", "sell-alice"}, asks: []string{"sell-bob:5"}},
+		{mode: SelfTradeDecrement, cancelled: []string{"buy-alice"}, asks: []string{"sell-alice:2", "sell-bob:5"}},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			me, executor := newTestEngine(t, &NewMatchingEngineParams{SelfTradePrevention: test.mode})
+			addOrders(t, me,
+				testOrder("sell-alice", "alice", false, 5, 1000),
+				testOrder("sell-bob", "bob", false, 5, 1000),
+				testOrder("buy-alice", "alice", true, 3, 1000),
+			)
+			expectStrings(t, "matches", executor.matched(), test.matched...)
+			expectStrings(t, "cancelled", executor.cancelledOrders(), test.cancelled...)
+			expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), test.asks...)
+			expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()), test.bids...)
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/network"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Matches are settled with the executor in batches, so the matching loop doesn't wait a round trip for every match.
+// A match takes its quantity off both orders straight away, reserving it, and the loop carries on matching what is left.
+// Batches go to the executor one after another, in the order they were matched, and up to SettlementWindow of them can be
+// out at once before the loop has to stop and wait for the oldest. The loop applies the answers oldest first: a trade is
+// confirmed, a failed side is dropped and the other side gets its quantity back, and a match the executor didn't answer
+// goes into quarantine with its quantity still reserved.
+const (
+	defaultSettlementBatchSize = 50
+	defaultSettlementWindow    = 4
+)
+
+// A match that has been made but not settled. The orders are the ones in the book, already reduced by it.
+type pendingMatch struct {
+	id        string
+	buyOrder  order.StockOrderInterface
+	sellOrder order.StockOrderInterface
+	buyFill   order.StockOrderInterface // what the executor is sent
+	sellFill  order.StockOrderInterface
+	trade     matchingEngineStructures.Trade
+}
+
+type settlementBatch struct {
+	matches []*pendingMatch
+	results []network.MatchExecutionResult // one for each match, in the same order
+	err     error                          // the executor didn't answer at all
+	done    chan struct{}                  // closed once the executor has answered
+}
+
+// How much of an order is in matches that haven't settled, quarantined ones included.
+type reservation struct {
+	order    order.StockOrderInterface
+	quantity int
+	dropped  bool // the order has been taken out of trading, so nothing given back to it trades again
+}
+
+type reservations struct {
+	orders map[string]*reservation
+	mutex  *sync.Mutex
+}
+
+func newReservations() *reservations {
+	return &reservations{
+		orders: make(map[string]*reservation),
+		mutex:  &sync.Mutex{},
+	}
+}
+
+func (r *reservations) add(stockOrder order.StockOrderInterface, quantity int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	held, ok := r.orders[stockOrder.GetId()]
+	if !ok {
+		held = &reservation{order: stockOrder}
+		r.orders[stockOrder.GetId()] = held
+	}
+	held.quantity += quantity
+}
+
+// Returns whether the order had been dropped.
+func (r *reservations) release(stockOrder order.StockOrderInterface, quantity int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	held, ok := r.orders[stockOrder.GetId()]
+	if !ok {
+		return false
+	}
+	held.quantity -= quantity
+	if held.quantity <= 0 {
+		delete(r.orders, stockOrder.GetId())
+	}
+	return held.dropped
+}
+
+func (r *reservations) drop(stockOrder order.StockOrderInterface) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if held, ok := r.orders[stockOrder.GetId()]; ok {
+		held.dropped = true
+	}
+}
+
+func (r *reservations) get(orderID string) (order.StockOrderInterface, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	held, ok := r.orders[orderID]
+	if !ok {
+		return nil, 0
+	}
+	return held.order, held.quantity
+}
+
+// Makes the trade as far as the book is concerned and queues it for the executor. Both orders are reduced by quantity now,
+// and the last price, price band and stop orders move with it, so the loop matches on as if it had traded.
+func (me *MatchingEngine) reserveTrade(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, quantity int, stockPrice money.Money) {
+	me.matchCount++
+	match := &pendingMatch{
+		id:        fmt.Sprintf("%s-%d-%d", me.StockId, me.startedAt.UnixNano(), me.matchCount),
+		buyOrder:  buyOrder,
+		sellOrder: sellOrder,
+		trade: matchingEngineStructures.Trade{
+			Price:         stockPrice,
+			Quantity:      quantity,
+			Timestamp:     me.now(),
+			AggressorSide: AggressorSide(buyOrder, sellOrder),
+		},
+	}
+	match.buyFill, match.sellFill = fills(buyOrder, sellOrder, quantity)
+	println("Reserving match: ", match.id, " of ", quantity, " between ", buyOrder.GetId(), " and ", sellOrder.GetId())
+	me.TradeTape.Record(match.trade)
+	me.PriceBand.Record(match.trade.Price, match.trade.Timestamp)
+	if me.auctionVolume > 0 {
+		me.auctionVolume -= quantity
+	}
+	me.triggerStops(stockPrice)
+	for _, stockOrder := range []order.StockOrderInterface{buyOrder, sellOrder} {
+		if stockOrder.IsIceberg() {
+			stockOrder.SetVisibleQuantity(max(stockOrder.GetVisibleQuantity()-quantity, 0))
+		}
+		stockOrder.SetQuantity(stockOrder.GetQuantity() - quantity)
+		me.reserved.add(stockOrder, quantity)
+	}
+	me.pendingMatches = append(me.pendingMatches, match)
+}
+
+// One batch out at a time when settling synchronously, so every match settles before the next is made.
+func (me *MatchingEngine) settlementWindow() int {
+	if me.SynchronousSettlement {
+		return 1
+	}
+	return me.SettlementWindow
+}
+
+func (me *MatchingEngine) canSendMatches() bool {
+	return len(me.inFlight) < me.settlementWindow()
+}
+
+func (me *MatchingEngine) settlementPending() bool {
+	return len(me.pendingMatches) > 0 || len(me.inFlight) > 0
+}
+
+// Whether the matches made so far should go to the executor before the loop matches again.
+func (me *MatchingEngine) batchReady() bool {
+	return len(me.pendingMatches) >= me.SettlementBatchSize || me.SynchronousSettlement && len(me.pendingMatches) > 0
+}
+
+// Whether the loop has to wait for the oldest batch before it matches again. It must let go of the orders it holds first.
+func (me *MatchingEngine) mustAwaitSettlement() bool {
+	return me.batchReady() && !me.canSendMatches() || me.SynchronousSettlement && len(me.inFlight) > 0
+}
+
+// Sends the pending matches as one batch, once the batch before it has been answered.
+func (me *MatchingEngine) sendMatches() {
+	batch := &settlementBatch{
+		matches: me.pendingMatches,
+		done:    make(chan struct{}),
+	}
+	me.pendingMatches = nil
+	var previous *settlementBatch
+	if len(me.inFlight) > 0 {
+		previous = me.inFlight[len(me.inFlight)-1]
+	}
+	me.inFlight = append(me.inFlight, batch)
+	println("Sending ", len(batch.matches), " matches of stock: ", me.StockId, " to the executor. Batches out: ", len(me.inFlight))
+	go func() {
+		if previous != nil {
+			<-previous.done
+		}
+		batch.results, batch.err = me.executeBatch(batch.matches)
+		close(batch.done)
+	}()
+}
+
+// Without a batch function, the matches are sent one at a time. A match that errors is answered with the error.
+func (me *MatchingEngine) executeBatch(matches []*pendingMatch) ([]network.MatchExecutionResult, error) {
+	if me.SendBatchToOrderExecution == nil {
+		results := make([]network.MatchExecutionResult, len(matches))
+		for i, match := range matches {
+			results[i].MatchID = match.id
+			result, err := me.SendToOrderExection(match.buyFill, match.sellFill, match.trade.Price, match.id)
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].ExecutorToMatchingEngineJSON = result
+		}
+		return results, nil
+	}
+	requests := make([]network.MatchingEngineToExecutionJSON, len(matches))
+	for i, match := range matches {
+		requests[i] = executionJSON(match.buyFill, match.sellFill, match.trade.Price, match.id)
+	}
+	return me.SendBatchToOrderExecution(requests)
+}
+
+// Only ever run from the matching loop, holding no orders.
+func (me *MatchingEngine) awaitSettlement() {
+	<-me.inFlight[0].done
+	me.applySettlement()
+}
+
+// Applies the batches the executor has already answered, without waiting. Only ever run from the matching loop, holding no orders.
+func (me *MatchingEngine) applySettledBatches() {
+	for len(me.inFlight) > 0 {
+		select {
+		case <-me.inFlight[0].done:
+			me.applySettlement()
+		default:
+			return
+		}
+	}
+}
+
+// Sends every pending match and waits for every answer. Only ever run from the matching loop, holding no orders.
+// Matches a failed side gave back quantity to are only made on the loop's next pass.
+func (me *MatchingEngine) drainSettlements() {
+	for me.settlementPending() {
+		if len(me.pendingMatches) > 0 && me.canSendMatches() {
+			me.sendMatches()
+			continue
+		}
+		me.awaitSettlement()
+	}
+}
+
+// Applies the oldest batch's answers, in the order the matches were made.
+func (me *MatchingEngine) applySettlement() {
+	batch := me.inFlight[0]
+	me.inFlight = me.inFlight[1:]
+	for i, match := range batch.matches {
+		var result network.MatchExecutionResult
+		err := batch.err
+		if err == nil && i < len(batch.results) && batch.results[i].MatchID == match.id {
+			result = batch.results[i]
+			if result.Error != "" {
+				err = errors.New(result.Error)
+			}
+		} else if err == nil {
+			err = fmt.Errorf("the executor didn't answer match %s", match.id)
+		}
+		me.settleMatch(match, result.ExecutorToMatchingEngineJSON, err)
+	}
+	me.publishBookTop()
+}
+
+func (me *MatchingEngine) settleMatch(match *pendingMatch, result network.ExecutorToMatchingEngineJSON, err error) {
+	quantity := match.trade.Quantity
+	entry := matchingEngineStructures.JournalEntry{
+		Type:        matchingEngineStructures.JournalMatchResult,
+		MatchID:     match.id,
+		BuyOrderID:  match.buyOrder.GetId(),
+		SellOrderID: match.sellOrder.GetId(),
+		Quantity:    quantity,
+		Price:       match.trade.Price,
+		Result:      &result,
+	}
+	if err != nil {
+		entry.Result = nil
+		entry.Reason = err.Error()
+	}
+	me.journal(entry)
+	switch {
+	case err != nil:
+		// the quantity stays reserved until the quarantine is resolved
+		me.quarantineMatch(match.id, match.buyFill, match.sellFill, quantity, match.trade.Price, err)
+	case result.IsBuyFailure:
+		println("Buy Order Failed: ", match.buyOrder.GetId())
+		me.dropOrder(match.buyOrder, quantity)
+		me.giveBack(match.sellOrder, quantity)
+	case result.IsSellFailure:
+		println("Sell Order Failed: ", match.sellOrder.GetId())
+		me.dropOrder(match.sellOrder, quantity)
+		me.giveBack(match.buyOrder, quantity)
+	default:
+		me.confirmTrade(match.buyOrder, match.sellOrder, match.trade)
+	}
+}
+
+// The executor has done the trade. It goes out to the price history and market data, and both orders are saved as they now stand.
+func (me *MatchingEngine) confirmTrade(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface, trade matchingEngineStructures.Trade) {
+	println("Trade settled: ", buyOrder.GetId(), " and ", sellOrder.GetId(), " for ", trade.Quantity)
+	if me.RecordTrade != nil {
+		me.tradeChannel <- trade
+	}
+	me.publishTrade(trade)
+	for _, stockOrder := range []order.StockOrderInterface{buyOrder, sellOrder} {
+		me.reserved.release(stockOrder, trade.Quantity)
+		settled := me.settled(stockOrder)
+		me.publishFill(settled, trade.Quantity)
+		if settled.GetQuantity() == 0 {
+			println("finishing Order: ", stockOrder.GetId())
+			me.DatabaseManager.Delete(stockOrder.GetId())
+		} else {
+			me.DatabaseManager.Update(settled)
+		}
+	}
+}
+
+// The executor failed this side, so the order trades no more. Like a failed order before batching, it is left for the
+// executor to deal with rather than cancelled here.
+func (me *MatchingEngine) dropOrder(stockOrder order.StockOrderInterface, quantity int) {
+	me.reserved.drop(stockOrder)
+	me.reserved.release(stockOrder, quantity)
+	me.takeOutOfBook(stockOrder)
+}
+
+// Quantity a match reserved goes back to an order the match didn't trade. An order still in its book just grows again
+// where it rests, and one the match used up goes back to the front of its price level. An order that has left the book
+// since, cancelled, expired or dropped, can't take it, so it is cancelled like any unfilled remainder.
+func (me *MatchingEngine) giveBack(stockOrder order.StockOrderInterface, quantity int) {
+	dropped := me.reserved.release(stockOrder, quantity)
+	inBook := me.bookOf(stockOrder).FindOrder(&matchingEngineStructures.RemoveParams{
+		OrderID:  stockOrder.GetId(),
+		PriceKey: stockOrder.GetPrice(),
+	}) != nil
+	switch {
+	case inBook:
+		me.addQuantity(stockOrder, quantity)
+	case stockOrder.GetQuantity() == 0 && !dropped:
+		me.addQuantity(stockOrder, quantity)
+		me.requeue(stockOrder)
+	default:
+		println("Order: ", stockOrder.GetId(), " left the book while its match was out. Cancelling ", quantity)
+		params := stockOrder.ToParams()
+		params.Quantity = quantity
+		me.cancelRemainder(order.New(params))
+	}
+}
+
+func (me *MatchingEngine) addQuantity(stockOrder order.StockOrderInterface, quantity int) {
+	stockOrder.SetQuantity(stockOrder.GetQuantity() + quantity)
+	if stockOrder.IsIceberg() {
+		stockOrder.SetVisibleQuantity(min(stockOrder.GetVisibleQuantity()+quantity, stockOrder.GetQuantity()))
+	}
+}
+
+func (me *MatchingEngine) bookOf(stockOrder order.StockOrderInterface) matchingEngineStructures.OrderBookInterface {
+	if stockOrder.GetIsBuy() {
+		return me.BuyOrderBook
+	}
+	return me.SellOrderBook
+}
+
+func (me *MatchingEngine) takeOutOfBook(stockOrder order.StockOrderInterface) {
+	me.bookOf(stockOrder).RemoveOrder(&matchingEngineStructures.RemoveParams{
+		OrderID:  stockOrder.GetId(),
+		PriceKey: stockOrder.GetPrice(),
+	})
+}
+
+// The order as it will be once everything reserved from it has traded, which is what the database keeps.
+func (me *MatchingEngine) settled(stockOrder order.StockOrderInterface) order.StockOrderInterface {
+	_, quantity := me.reserved.get(stockOrder.GetId())
+	if quantity == 0 {
+		return stockOrder
+	}
+	params := stockOrder.ToParams()
+	params.Quantity += quantity
+	return order.New(params)
+}
+
+func (me *MatchingEngine) saveOrder(stockOrder order.StockOrderInterface) {
+	err := me.DatabaseManager.Update(me.settled(stockOrder))
+	if err != nil {
+		println("Error: ", err.Error())
+	}
+}
+
+// The live order a quarantined match reserved from. After a restart it is found in the book, or, if the match used all of it,
+// made again from the match with nothing left open.
+func (me *MatchingEngine) reservedOrder(fill order.NewStockOrderParams) order.StockOrderInterface {
+	if stockOrder, _ := me.reserved.get(fill.ID); stockOrder != nil {
+		return stockOrder
+	}
+	var book matchingEngineStructures.OrderBookInterface = me.SellOrderBook
+	if fill.IsBuy {
+		book = me.BuyOrderBook
+	}
+	if stockOrder := book.FindOrder(&matchingEngineStructures.RemoveParams{OrderID: fill.ID, PriceKey: fill.Price}); stockOrder != nil {
+		return stockOrder
+	}
+	fill.Quantity = 0
+	fill.ParentStockOrderID = ""
+	return order.New(fill)
+}
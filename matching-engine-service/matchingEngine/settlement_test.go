@@ -0,0 +1,60 @@
+package matchingEngine
+
+import (
+	"Shared/entities/order"
+	"Shared/network"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMatchesSettleInBatches(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{SettlementBatchSize: 2})
+	me.SendBatchToOrderExecution = executor.executeBatch
+	for i := 1; i <= 5; i++ {
+		addOrders(t, me, testOrder(fmt.Sprintf("sell-%d", i), fmt.Sprintf("seller-%d", i), false, 1, 1000))
+	}
+	addOrders(t, me, testOrder("buy-1", "carol", true, 5, 1000))
+	if fmt.Sprint(executor.batchSizes()) != "[2 2 1]" {
+		t.Errorf("got batches of %v, wanted [2 2 1]", executor.batchSizes())
+	}
+	if len(executor.matched()) != 5 {
+		t.Errorf("got %d matches settled, wanted 5", len(executor.matched()))
+	}
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()))
+}
+
+func TestFailedSideIsDroppedAndTheOtherTradesOn(t *testing.T) {
+	me, executor := newTestEngine(t, &NewMatchingEngineParams{})
+	executor.fail = func(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) (bool, bool) {
+		return false, parentID(sellOrder) == "sell-1"
+	}
+	addOrders(t, me,
+		testOrder("sell-1", "alice", false, 3, 1000),
+		testOrder("sell-2", "bob", false, 3, 1000),
+		testOrder("buy-1", "carol", true, 3, 1000),
+	)
+	// the buy gets its shares back and matches the next sell
+	expectStrings(t, "matches", executor.matched(), "buy-1/sell-2 3@10.00")
+	// the failed order is left to the executor, not cancelled here
+	expectStrings(t, "cancelled", executor.cancelledOrders())
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()))
+	expectStrings(t, "bids", bookIDs(me.BuyOrderBook.GetOrders()))
+}
+
+func TestUnansweredMatchIsQuarantined(t *testing.T) {
+	me, _ := newTestEngine(t, &NewMatchingEngineParams{})
+	me.SendBatchToOrderExecution = func(matches []network.MatchingEngineToExecutionJSON) ([]network.MatchExecutionResult, error) {
+		return nil, errors.New("executor unreachable")
+	}
+	addOrders(t, me,
+		testOrder("sell-1", "alice", false, 3, 1000),
+		testOrder("buy-1", "bob", true, 2, 1000),
+	)
+	quarantined := me.GetQuarantinedMatches()
+	if len(quarantined) != 1 || quarantined[0].Quantity != 2 {
+		t.Fatalf("got %+v, wanted the match of 2 quarantined", quarantined)
+	}
+	// its shares stay reserved until it is resolved
+	expectStrings(t, "asks", bookIDs(me.SellOrderBook.GetOrders()), "sell-1:1")
+}
@@ -0,0 +1,602 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/network"
+	networkQueue "Shared/network/queue"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stocks are spread over the matching engine instances by a consistent hash of their IDs, so an instance joining or leaving
+// only moves the stocks it gains or gives up. An instance binds the routing keys of the stocks it has, network.StockRoute of
+// these patterns, so the order initiator's messages go straight to it. Anything else that reaches an instance without the
+// stock, like an HTTP request through nginx, is sent on to the owner over its forward queue.
+var stockRoutedPatterns = []string{"placeStockOrder", "deleteOrder/", "amendOrder"}
+
+const (
+	forwardPattern       = "forward." // + the instance name
+	forwardedParam       = "forwarded"
+	adoptStockPattern    = "adoptStock"
+	handOffStocksPattern = "handOffStocks"
+)
+
+// How long after taking a stock over a request for it can arrive twice, from the queue and from the old owner
+const handoffOverlap = time.Minute
+
+type handlerFunc = func(network.ResponseWriter, []byte, url.Values, string)
+
+var _instance string
+var _ring matchingEngineStructures.HashRingInterface
+var _handoffs map[string]*handoff
+var _stockLocks map[string]*sync.RWMutex // held for reading by whatever is using the stock
+var _stocksMutex sync.RWMutex            // guards _matchingEngineMap, _handoffs, _stockLocks and _ring
+var _queueRouter networkQueue.QueueRouterInterface
+var _forwardHandlers = map[string]handlerFunc{}
+var _adoptedAt = map[string]time.Time{}
+var _deliveries = map[string]time.Time{} // correlation IDs of requests for stocks taken over recently
+var _deliveriesMutex sync.Mutex
+
+// A stock on its way to another instance. Requests for it wait for done, then go to owner.
+type handoff struct {
+	owner string
+	done  chan struct{}
+}
+
+// A request sent on from the instance it reached
+type forwardedRequest struct {
+	Pattern     string     `json:"pattern"`
+	Payload     []byte     `json:"payload"`
+	QueryParams url.Values `json:"query_params"`
+	RequestType string     `json:"request_type"`
+}
+
+// What a handler wrote, to be written again by the instance the request first reached
+type capturedResponse struct {
+	Status int    `json:"status"`
+	Body   []byte `json:"body"`
+	header http.Header
+}
+
+func (c *capturedResponse) WriteHeader(statusCode int) {
+	c.Status = statusCode
+}
+
+func (c *capturedResponse) Write(body []byte) (int, error) {
+	c.Body = append(c.Body, body...)
+	return len(body), nil
+}
+
+func (c *capturedResponse) Header() http.Header {
+	if c.header == nil {
+		c.header = http.Header{}
+	}
+	return c.header
+}
+
+func (c *capturedResponse) writeTo(responseWriter network.ResponseWriter) {
+	if c.Status != http.StatusOK || len(c.Body) == 0 {
+		responseWriter.WriteHeader(c.Status)
+		return
+	}
+	responseWriter.Write(c.Body)
+}
+
+type handedOffStock struct {
+	StockID string                             `json:"stock_id"`
+	State   matchingEngineStructures.BookState `json:"state"`
+}
+
+// MATCHING_ENGINE_INSTANCE names this instance, the host name if it isn't set. The instances are the ones the last
+// rebalance left, kept next to the snapshots, or MATCHING_ENGINE_INSTANCES (comma separated) before there has been one.
+// With neither, this instance owns every stock.
+func initSharding(networkQueueManager network.NetworkInterface) {
+	_instance = os.Getenv("MATCHING_ENGINE_INSTANCE")
+	if _instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			panic(err)
+		}
+		_instance = hostname
+	}
+	_ring = matchingEngineStructures.NewHashRing(&matchingEngineStructures.NewHashRingParams{Instances: loadInstances()})
+	_handoffs = make(map[string]*handoff)
+	_stockLocks = make(map[string]*sync.RWMutex)
+	_queueRouter, _ = networkQueueManager.(networkQueue.QueueRouterInterface)
+	// made now, so handlers don't race to make it
+	networkQueueManager.MatchingEngine()
+	println("Matching engine instance: ", _instance, " of ", strings.Join(_ring.GetInstances(), ","))
+}
+
+func instancesPath() string {
+	if os.Getenv("SNAPSHOT_DIR") == "" {
+		return ""
+	}
+	return filepath.Join(os.Getenv("SNAPSHOT_DIR"), "matching-engine-instances.json")
+}
+
+func loadInstances() []string {
+	if path := instancesPath(); path != "" {
+		data, err := os.ReadFile(path)
+		var instances []string
+		if err == nil && json.Unmarshal(data, &instances) == nil && len(instances) > 0 {
+			return instances
+		}
+	}
+	instances := []string{}
+	for _, instance := range strings.Split(os.Getenv("MATCHING_ENGINE_INSTANCES"), ",") {
+		if strings.TrimSpace(instance) != "" {
+			instances = append(instances, strings.TrimSpace(instance))
+		}
+	}
+	if len(instances) == 0 {
+		instances = append(instances, _instance)
+	}
+	return instances
+}
+
+func saveInstances(instances []string) {
+	path := instancesPath()
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(instances)
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		println("Error saving matching engine instances: ", err.Error())
+	}
+}
+
+func ownsStock(stockID string) bool {
+	_stocksMutex.RLock()
+	defer _stocksMutex.RUnlock()
+	return _ring.Owner(stockID) == _instance
+}
+
+// Where a stock this instance doesn't have is: where it was just handed to, otherwise wherever the ring puts it.
+func ownerOf(stockID string) string {
+	_stocksMutex.RLock()
+	defer _stocksMutex.RUnlock()
+	if h, ok := _handoffs[stockID]; ok {
+		return h.owner
+	}
+	return _ring.Owner(stockID)
+}
+
+// The stock's engine if this instance has it. Only looks, call acquireStock to keep it here while it is used.
+func stockEngine(stockID string) (MatchingEngineInterface, bool) {
+	_stocksMutex.RLock()
+	defer _stocksMutex.RUnlock()
+	me, ok := _matchingEngineMap[stockID]
+	return me, ok
+}
+
+// Returns the stock's engine if this instance has it, holding the stock until releaseStock so it can't be handed off
+// or delisted while it is used. A stock part way through a hand off is waited for.
+// Only that stock is held, so a slow request for one stock never holds up a rebalance of the others.
+func acquireStock(stockID string) (MatchingEngineInterface, bool) {
+	for {
+		_stocksMutex.RLock()
+		me, ok := _matchingEngineMap[stockID]
+		lock := _stockLocks[stockID]
+		h, moving := _handoffs[stockID]
+		_stocksMutex.RUnlock()
+		if !ok && !moving {
+			return nil, false
+		}
+		if !ok {
+			<-h.done
+			// a hand off that failed leaves the stock here
+			_stocksMutex.RLock()
+			me, ok = _matchingEngineMap[stockID]
+			lock = _stockLocks[stockID]
+			_stocksMutex.RUnlock()
+			if !ok {
+				return nil, false
+			}
+		}
+		lock.RLock()
+		// it may have gone between looking and holding it
+		if current, ok := stockEngine(stockID); ok && current == me {
+			return me, true
+		}
+		lock.RUnlock()
+	}
+}
+
+func releaseStock(stockID string) {
+	_stocksMutex.RLock()
+	lock := _stockLocks[stockID]
+	_stocksMutex.RUnlock()
+	lock.RUnlock()
+}
+
+// Waits for whatever is using a stock taken out of _matchingEngineMap to finish with it.
+func waitForStockUsers(stockID string) {
+	_stocksMutex.RLock()
+	lock := _stockLocks[stockID]
+	_stocksMutex.RUnlock()
+	lock.Lock()
+	lock.Unlock()
+}
+
+// Runs local while holding the stock if this instance has it, otherwise sends the request on to the stock's owner.
+// Returns false if it did neither, leaving the response to the caller.
+func withStock(responseWriter network.ResponseWriter, pattern string, stockID string, data []byte, queryParams url.Values, requestType string, local func()) bool {
+	if _, ok := acquireStock(stockID); ok {
+		defer releaseStock(stockID)
+		local()
+		return true
+	}
+	return forwardToOwner(responseWriter, pattern, stockID, data, queryParams, requestType)
+}
+
+// Only sends a request on once, so two instances that disagree about the owner don't pass it back and forth.
+func forwardToOwner(responseWriter network.ResponseWriter, pattern string, stockID string, data []byte, queryParams url.Values, requestType string) bool {
+	if queryParams.Get(forwardedParam) != "" {
+		return false
+	}
+	owner := ownerOf(stockID)
+	if owner == "" || owner == _instance {
+		return false
+	}
+	println("Sending ", pattern, " for stock: ", stockID, " on to instance: ", owner)
+	response, err := forward(owner, &forwardedRequest{
+		Pattern:     pattern,
+		Payload:     data,
+		QueryParams: queryParams,
+		RequestType: requestType,
+	})
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadGateway)
+		return true
+	}
+	response.writeTo(responseWriter)
+	return true
+}
+
+func forward(instance string, request *forwardedRequest) (*capturedResponse, error) {
+	response, err := _networkQueueManager.MatchingEngine().Post(forwardPattern+instance, request)
+	if err != nil {
+		return nil, err
+	}
+	var captured capturedResponse
+	err = json.Unmarshal(response, &captured)
+	if err != nil {
+		return nil, err
+	}
+	return &captured, nil
+}
+
+// Makes the handler reachable from other instances under pattern.
+func forwardable(pattern string, handler handlerFunc) handlerFunc {
+	_forwardHandlers[pattern] = handler
+	return handler
+}
+
+// For a handler that finds its stock with stockIDOf. It runs holding the stock, or on the stock's owner.
+// Otherwise it runs here as it is: without a stock ID to gather every instance's stocks itself, or to answer that there is no such stock.
+func routedByStock(pattern string, stockIDOf func(data []byte, queryParams url.Values) string, handler handlerFunc) handlerFunc {
+	return forwardable(pattern, func(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+		stockID := stockIDOf(data, queryParams)
+		if stockID != "" && withStock(responseWriter, pattern, stockID, data, queryParams, requestType, func() {
+			handler(responseWriter, data, queryParams, requestType)
+		}) {
+			return
+		}
+		handler(responseWriter, data, queryParams, requestType)
+	})
+}
+
+func stockIDInQuery(data []byte, queryParams url.Values) string {
+	return queryParams.Get("stock_id")
+}
+
+func stockIDInBody(data []byte, queryParams url.Values) string {
+	var stockID network.StockID
+	json.Unmarshal(data, &stockID)
+	return stockID.StockID
+}
+
+// Runs read over the stocks this instance has, with none coming or going until it is done. Keep it short, it holds up every hand off.
+func readStocks(read func()) {
+	_stocksMutex.RLock()
+	defer _stocksMutex.RUnlock()
+	read()
+}
+
+// Asks every other instance for its part of a list, with the same query, and joins the network.ReturnJSON data they answer with.
+// An instance that doesn't answer is left out. Nothing is gathered for a request that was itself sent on.
+func gatherFromInstances[T any](pattern string, queryParams url.Values) []T {
+	gathered := []T{}
+	if queryParams.Get(forwardedParam) != "" {
+		return gathered
+	}
+	for _, instance := range otherInstances() {
+		response, err := forward(instance, &forwardedRequest{Pattern: pattern, QueryParams: queryParams, RequestType: http.MethodGet})
+		if err == nil && response.Status != http.StatusOK {
+			err = errStatus(response.Status)
+		}
+		var returnVal struct {
+			Data []T `json:"data"`
+		}
+		if err == nil {
+			err = json.Unmarshal(response.Body, &returnVal)
+		}
+		if err != nil {
+			println("Error gathering ", pattern, " from instance: ", instance, " ", err.Error())
+			continue
+		}
+		gathered = append(gathered, returnVal.Data...)
+	}
+	return gathered
+}
+
+func otherInstances() []string {
+	_stocksMutex.RLock()
+	defer _stocksMutex.RUnlock()
+	instances := []string{}
+	for _, instance := range _ring.GetInstances() {
+		if instance != _instance {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+type errStatus int
+
+func (e errStatus) Error() string {
+	return http.StatusText(int(e))
+}
+
+// Requests for a stock taken over in the last handoffOverlap can arrive twice: straight from the queue once this instance
+// bound the stock, and sent on by the old owner that still had it bound. Only the first is handled.
+func firstDelivery(stockID string, queryParams url.Values) bool {
+	requestID := queryParams.Get(networkQueue.CorrelationIDParam)
+	_deliveriesMutex.Lock()
+	defer _deliveriesMutex.Unlock()
+	now := time.Now()
+	adoptedAt, ok := _adoptedAt[stockID]
+	if requestID == "" || !ok || now.Sub(adoptedAt) > handoffOverlap {
+		return true
+	}
+	for id, at := range _deliveries {
+		if now.Sub(at) > handoffOverlap {
+			delete(_deliveries, id)
+		}
+	}
+	if _, seen := _deliveries[requestID]; seen {
+		return false
+	}
+	_deliveries[requestID] = now
+	return true
+}
+
+func bindStock(stockID string) {
+	if _queueRouter == nil {
+		return
+	}
+	for _, pattern := range stockRoutedPatterns {
+		err := _queueRouter.Bind(pattern, network.StockRoute(pattern, stockID))
+		if err != nil {
+			println("Error routing ", pattern, " for stock: ", stockID, " here: ", err.Error())
+		}
+	}
+}
+
+func unbindStock(stockID string) {
+	if _queueRouter == nil {
+		return
+	}
+	for _, pattern := range stockRoutedPatterns {
+		err := _queueRouter.Unbind(pattern, network.StockRoute(pattern, stockID))
+		if err != nil {
+			println("Error unrouting ", pattern, " for stock: ", stockID, " from here: ", err.Error())
+		}
+	}
+}
+
+// Runs a request another instance sent on as if it had come here. Always answers, with what the handler wrote,
+// since the instance waiting on it would never hear an error status.
+func ForwardHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	captured := &capturedResponse{Status: http.StatusOK}
+	var request forwardedRequest
+	err := json.Unmarshal(data, &request)
+	if err != nil {
+		println("Error: ", err.Error())
+		captured.Status = http.StatusBadRequest
+	} else if handler, ok := _forwardHandlers[request.Pattern]; !ok {
+		println("Error: nothing handles forwarded ", request.Pattern)
+		captured.Status = http.StatusNotFound
+	} else {
+		if request.QueryParams == nil {
+			request.QueryParams = url.Values{}
+		}
+		request.QueryParams.Set(forwardedParam, "true")
+		handler(captured, request.Payload, request.QueryParams, request.RequestType)
+	}
+	capturedJSON, err := json.Marshal(captured)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(capturedJSON)
+}
+
+// Expected input is a network.MatchingEngineInstances. Every instance in it, and every instance there was before, takes
+// on the new instances and hands the stocks it loses to their new owners. Answers with the network.StockHandoff of every stock that moved.
+// To add an instance, start it first, then rebalance with it in. To take one out, rebalance without it, then stop it.
+func RebalanceMatchingEnginesHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Rebalancing matching engines")
+	var instances network.MatchingEngineInstances
+	err := json.Unmarshal(data, &instances)
+	if err != nil || len(instances.Instances) == 0 {
+		println("Error: expected the instances")
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writeReturnJSON(responseWriter, rebalance(instances.Instances))
+}
+
+// One instance at a time, so no two instances are ever handing stocks to each other at once.
+func rebalance(instances []string) []network.StockHandoff {
+	_stocksMutex.RLock()
+	everyInstance := append(_ring.GetInstances(), instances...)
+	_stocksMutex.RUnlock()
+	sort.Strings(everyInstance)
+	moved := []network.StockHandoff{}
+	for i, instance := range everyInstance {
+		if i > 0 && everyInstance[i-1] == instance {
+			continue
+		}
+		if instance == _instance {
+			moved = append(moved, handOffStocks(instances)...)
+			continue
+		}
+		payload, err := json.Marshal(network.MatchingEngineInstances{Instances: instances})
+		if err != nil {
+			println("Error: ", err.Error())
+			continue
+		}
+		response, err := forward(instance, &forwardedRequest{Pattern: handOffStocksPattern, Payload: payload, RequestType: http.MethodPost})
+		if err == nil && response.Status != http.StatusOK {
+			err = errStatus(response.Status)
+		}
+		var returnVal struct {
+			Data []network.StockHandoff `json:"data"`
+		}
+		if err == nil {
+			err = json.Unmarshal(response.Body, &returnVal)
+		}
+		if err != nil {
+			println("Error rebalancing instance: ", instance, " ", err.Error())
+			continue
+		}
+		moved = append(moved, returnVal.Data...)
+	}
+	return moved
+}
+
+func HandOffStocksHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	var instances network.MatchingEngineInstances
+	err := json.Unmarshal(data, &instances)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writeReturnJSON(responseWriter, handOffStocks(instances.Instances))
+}
+
+// Takes on the new instances, then hands each stock this instance has that they give to someone else to its new owner.
+func handOffStocks(instances []string) []network.StockHandoff {
+	ring := matchingEngineStructures.NewHashRing(&matchingEngineStructures.NewHashRingParams{Instances: instances})
+	_stocksMutex.Lock()
+	_ring = ring
+	// stocks handed off before are wherever the new ring says now
+	for stockID, h := range _handoffs {
+		select {
+		case <-h.done:
+			delete(_handoffs, stockID)
+		default:
+		}
+	}
+	stockIDs := make([]string, 0, len(_matchingEngineMap))
+	for stockID := range _matchingEngineMap {
+		stockIDs = append(stockIDs, stockID)
+	}
+	_stocksMutex.Unlock()
+	saveInstances(ring.GetInstances())
+	sort.Strings(stockIDs)
+	moved := []network.StockHandoff{}
+	for _, stockID := range stockIDs {
+		owner := ring.Owner(stockID)
+		if owner == _instance {
+			continue
+		}
+		moving := network.StockHandoff{StockID: stockID, From: _instance, To: owner}
+		err := handOffStock(stockID, owner)
+		if err != nil {
+			moving.Error = err.Error()
+		}
+		moved = append(moved, moving)
+	}
+	return moved
+}
+
+// Stops the stock's engine and sends its book to owner. Requests for the stock wait until it is over, then go to owner.
+// If owner doesn't take it the engine is built again here from the same book.
+func handOffStock(stockID string, owner string) error {
+	_stocksMutex.Lock()
+	me, ok := _matchingEngineMap[stockID]
+	if !ok {
+		_stocksMutex.Unlock()
+		return nil
+	}
+	h := &handoff{owner: owner, done: make(chan struct{})}
+	delete(_matchingEngineMap, stockID)
+	_handoffs[stockID] = h
+	_stocksMutex.Unlock()
+	defer close(h.done)
+	waitForStockUsers(stockID)
+
+	println("Handing stock: ", stockID, " off to instance: ", owner)
+	state := me.Stop()
+	payload, err := json.Marshal(handedOffStock{StockID: stockID, State: *state})
+	var response *capturedResponse
+	if err == nil {
+		response, err = forward(owner, &forwardedRequest{Pattern: adoptStockPattern, Payload: payload, RequestType: http.MethodPost})
+	}
+	if err == nil && response.Status != http.StatusOK {
+		err = errStatus(response.Status)
+	}
+	if err != nil {
+		println("Error handing stock: ", stockID, " off to instance: ", owner, " ", err.Error(), ". Keeping it")
+		adoptStock(stockID, state)
+		return err
+	}
+	// the owner has bound the stock, from here anything still coming here is sent on
+	unbindStock(stockID)
+	// a snapshot left here would be older than the book, if the stock ever came back after a restart
+	if os.Getenv("SNAPSHOT_DIR") != "" {
+		err = os.Remove(matchingEngineStructures.SnapshotPath(os.Getenv("SNAPSHOT_DIR"), stockID))
+		if err != nil && !os.IsNotExist(err) {
+			println("Error removing snapshot of stock: ", stockID, " ", err.Error())
+		}
+	}
+	println("Handed stock: ", stockID, " off to instance: ", owner)
+	return nil
+}
+
+func AdoptStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	var stock handedOffStock
+	err := json.Unmarshal(data, &stock)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	println("Taking over stock: ", stock.StockID)
+	adoptStock(stock.StockID, &stock.State)
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+// Builds the stock's engine from a book another instance stopped, and starts taking its orders.
+func adoptStock(stockID string, state *matchingEngineStructures.BookState) {
+	me := NewMatchingEngineFromState(engineParams(stockID), state)
+	_deliveriesMutex.Lock()
+	_adoptedAt[stockID] = time.Now()
+	_deliveriesMutex.Unlock()
+	startStock(stockID, me)
+}
@@ -0,0 +1,215 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/order"
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultSnapshotInterval = time.Minute
+
+// Writes the book to SnapshotDirectory every SnapshotInterval, so a restart only replays the journal after the last one.
+// A snapshot is only a starting point for the journal, so nothing is written for an engine without one.
+// Once it is written the journal is compacted down to it, so the journal doesn't keep growing.
+func (me *MatchingEngine) RunMatchingEngineSnapshots() {
+	if me.Journal == nil || me.SnapshotDirectory == "" {
+		return
+	}
+	if !me.startWorker() {
+		return
+	}
+	defer me.workers.Done()
+	ticker := time.NewTicker(me.SnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-me.stopChannel:
+			return
+		}
+		snapshot := me.TakeSnapshot()
+		err := matchingEngineStructures.WriteSnapshot(me.SnapshotDirectory, snapshot)
+		if err != nil {
+			println("Error writing snapshot for stock: ", me.StockId, " ", err.Error())
+			continue
+		}
+		println("Snapshot of stock: ", me.StockId, " at journal sequence ", snapshot.Sequence)
+		if snapshot.Sequence == 0 {
+			continue
+		}
+		// the snapshot is on disk, so the entries it holds are no longer needed to recover
+		err = me.Journal.Compact(matchingEngineStructures.JournalEntry{
+			Sequence:  snapshot.Sequence,
+			Type:      matchingEngineStructures.JournalStart,
+			Timestamp: snapshot.TakenAt,
+			Start:     &snapshot.BookState,
+		})
+		if err != nil {
+			println("Error compacting journal for stock: ", me.StockId, " ", err.Error())
+		}
+	}
+}
+
+// Taken by the matching loop while it waits for work, so no match is half done.
+func (me *MatchingEngine) TakeSnapshot() *matchingEngineStructures.Snapshot {
+	request := make(chan *matchingEngineStructures.Snapshot, 1)
+	me.snapshotChannel <- request
+	return <-request
+}
+
+// Only ever run from the matching loop, holding no orders.
+func (me *MatchingEngine) snapshot(request chan *matchingEngineStructures.Snapshot) {
+	if me.settlementPending() {
+		// a reservation isn't in the book or the quarantine, so it would be lost. Ask again once what came back has matched
+		me.drainSettlements()
+		go func() { me.snapshotChannel <- request }()
+		return
+	}
+	if !me.inputMutex.TryLock() {
+		// an input is part way in, ask again once it has landed
+		go func() { me.snapshotChannel <- request }()
+		return
+	}
+	defer me.inputMutex.Unlock()
+	if me.queued() > 0 {
+		// a queued stop or immediate order isn't in any book yet
+		go func() { me.snapshotChannel <- request }()
+		return
+	}
+	snapshot := &matchingEngineStructures.Snapshot{
+		StockID: me.StockId,
+		TakenAt: me.now(),
+	}
+	// read first, a halt or resume journaled after it is applied again on recovery and does nothing
+	if me.Journal != nil {
+		snapshot.Sequence = me.Journal.GetSequence()
+	}
+	snapshot.BookState = *me.captureState()
+	request <- snapshot
+}
+
+// Call only while the book can't change: from the matching loop holding the input mutex, or before the loop starts.
+func (me *MatchingEngine) captureState() *matchingEngineStructures.BookState {
+	haltStatus := me.GetHaltStatus()
+	return &matchingEngineStructures.BookState{
+		BuyOrders:           orderParams(me.BuyOrderBook.GetOrders()),
+		SellOrders:          orderParams(me.SellOrderBook.GetOrders()),
+		StopOrders:          orderParams(me.TriggerBook.GetOrders()),
+		Quarantined:         me.Quarantine.GetMatches(),
+		Halted:              haltStatus.Halted,
+		HaltReason:          haltStatus.Reason,
+		InAuction:           haltStatus.InAuction,
+		AllocationPolicy:    me.BuyOrderBook.GetAllocationPolicy(),
+		SelfTradePrevention: me.SelfTradePrevention,
+		PriceBandPercent:    me.PriceBand.GetPercent(),
+		PriceBandWindow:     me.PriceBand.GetWindow(),
+	}
+}
+
+// Keeps the order they are in.
+func orderParams(orders []order.StockOrderInterface) []order.NewStockOrderParams {
+	params := make([]order.NewStockOrderParams, len(orders))
+	for i, stockOrder := range orders {
+		params[i] = stockOrder.ToParams()
+	}
+	return params
+}
+
+// Puts back the halt, auction and quarantine a snapshot was taken in. Call before the matching loop starts.
+func (me *MatchingEngine) restoreState(state *matchingEngineStructures.BookState) {
+	for _, match := range state.Quarantined {
+		me.Quarantine.Add(match)
+		me.reserved.add(me.reservedOrder(match.BuyOrder), match.Quantity)
+		me.reserved.add(me.reservedOrder(match.SellOrder), match.Quantity)
+	}
+	if state.Halted && state.HaltReason == HaltReasonPriceBand {
+		me.tripCircuitBreaker()
+	} else if state.Halted {
+		me.halt(state.HaltReason)
+	}
+	me.haltMutex.Lock()
+	me.inAuction = state.InAuction
+	me.haltMutex.Unlock()
+}
+
+// Builds the engine for a book another instance handed over, as it was when that instance stopped it.
+// The book's own allocation, self trade prevention and price band are kept. The matching loop is already running on the engine it returns.
+func NewMatchingEngineFromState(params *NewMatchingEngineParams, state *matchingEngineStructures.BookState) MatchingEngineInterface {
+	orders := state.Orders()
+	params.InitalOrders = &orders
+	params.InQueueOrder = true
+	params.AllocationPolicy = state.AllocationPolicy
+	params.SelfTradePrevention = state.SelfTradePrevention
+	params.PriceBandPercent = state.PriceBandPercent
+	params.PriceBandWindow = state.PriceBandWindow
+	me := newMatchingEngine(params)
+	me.restoreState(state)
+	me.journalStart()
+	go me.RunMatchingEngineOrders()
+	return me
+}
+
+// Builds the engine from the stock's latest snapshot, then replays the journal written since, so a cold start doesn't
+// load every resting order from the database. A START entry after the snapshot is newer, and is used instead.
+// The replay runs like ReplayJournal, on the journal's clock and match results, until the journaled matches run out.
+// From there the engine is live: any match left to make goes to the executor.
+// Returns nil with no error if there is no snapshot. The matching loop is already running on the engine it returns.
+func RecoverMatchingEngine(params *NewMatchingEngineParams) (MatchingEngineInterface, error) {
+	if params.Journal == nil || params.SnapshotDirectory == "" {
+		return nil, nil
+	}
+	snapshot, err := matchingEngineStructures.ReadSnapshot(params.SnapshotDirectory, params.StockID)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if params.Journal.GetSequence() < snapshot.Sequence {
+		return nil, fmt.Errorf("journal ends at sequence %d, before the snapshot at %d", params.Journal.GetSequence(), snapshot.Sequence)
+	}
+	entries, err := matchingEngineStructures.ReadJournal(params.Journal.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	state := &snapshot.BookState
+	startedAt := snapshot.TakenAt
+	newer := []matchingEngineStructures.JournalEntry{}
+	for _, entry := range entries {
+		if entry.Sequence <= snapshot.Sequence {
+			continue
+		}
+		if entry.Type == matchingEngineStructures.JournalStart {
+			state = entry.Start
+			startedAt = entry.Timestamp
+			newer = newer[:0]
+			continue
+		}
+		newer = append(newer, entry)
+	}
+	println("Recovering stock: ", params.StockID, " from snapshot at sequence ", snapshot.Sequence, " and ", len(newer), " newer journal entries")
+
+	live := *params
+	replayParams := *params
+	replayParams.Journal = nil
+	r := newReplay(params.StockID, newer)
+	r.live = &live
+	r.setClock(startedAt)
+	r.me = r.build(state, &replayParams)
+	go r.me.RunMatchingEngineOrders()
+	r.me.settle()
+	for _, entry := range newer {
+		r.apply(entry)
+	}
+	r.me.settle()
+	result := r.result()
+	if len(result.Divergences) > 0 {
+		println("Recovery of stock: ", params.StockID, " diverged from the journal ", len(result.Divergences), " times")
+	}
+	r.goLive()
+	r.me.Journal = live.Journal
+	r.me.journalStart()
+	return r.me, nil
+}
@@ -0,0 +1,173 @@
+package matchingEngine
+
+import (
+	"MatchingEngineService/matchingEngineStructures"
+	"Shared/entities/order"
+	"Shared/network"
+	"time"
+)
+
+// Why a stock was halted
+const (
+	HaltReasonAdmin     = "ADMIN"
+	HaltReasonPriceBand = "PRICE_BAND"
+	HaltReasonSuspended = "SUSPENDED" // the stock is suspended. Only reinstating it resumes trading
+)
+
+type haltState struct {
+	halted     bool
+	reason     string
+	haltedAt   time.Time
+	generation int // bumped on every halt, so a timed resume can't end a later halt
+}
+
+// Stops matching. Orders keep being accepted and queue in the book until Resume is called.
+// Returns false if the stock was already halted. A suspension takes over from any other halt.
+func (me *MatchingEngine) Halt(reason string) bool {
+	_, ok := me.halt(reason)
+	if ok {
+		me.journal(matchingEngineStructures.JournalEntry{Type: matchingEngineStructures.JournalHalt, Reason: reason})
+	}
+	return ok
+}
+
+func (me *MatchingEngine) halt(reason string) (int, bool) {
+	me.haltMutex.Lock()
+	defer me.haltMutex.Unlock()
+	// a suspension halts again over any other halt, in a new generation so a timed resume from before can't end it
+	if me.haltState.halted && (reason != HaltReasonSuspended || me.haltState.reason == HaltReasonSuspended) {
+		return me.haltState.generation, false
+	}
+	println("Halting trading in stock: ", me.StockId, " Reason: ", reason)
+	me.haltState = haltState{
+		halted:     true,
+		reason:     reason,
+		haltedAt:   me.now(),
+		generation: me.haltState.generation + 1,
+	}
+	return me.haltState.generation, true
+}
+
+// Returns false if the stock wasn't halted. The price band starts over from the first trade after the resume.
+func (me *MatchingEngine) Resume() bool {
+	me.haltMutex.Lock()
+	defer me.haltMutex.Unlock()
+	if !me.haltState.halted {
+		return false
+	}
+	println("Resuming trading in stock: ", me.StockId)
+	// a timed resume after a band breach is journaled too, a replay has no timers
+	me.journal(matchingEngineStructures.JournalEntry{Type: matchingEngineStructures.JournalResume})
+	me.haltState.halted = false
+	me.PriceBand.Reset()
+	select {
+	case me.resumeChannel <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (me *MatchingEngine) IsHalted() bool {
+	me.haltMutex.Lock()
+	defer me.haltMutex.Unlock()
+	return me.haltState.halted
+}
+
+func (me *MatchingEngine) GetHaltStatus() network.HaltStatus {
+	me.haltMutex.Lock()
+	defer me.haltMutex.Unlock()
+	status := network.HaltStatus{
+		StockID:        me.StockId,
+		Halted:         me.haltState.halted,
+		InAuction:      me.inAuction,
+		ReferencePrice: me.PriceBand.GetReferencePrice(),
+		BandPercent:    me.PriceBand.GetPercent(),
+	}
+	if me.haltState.halted {
+		haltedAt := me.haltState.haltedAt
+		status.Reason = me.haltState.reason
+		status.HaltedAt = &haltedAt
+	}
+	return status
+}
+
+// Called by the matching loop when a trade would break the price band. The trade doesn't happen.
+// With a BreachHaltDuration the stock resumes by itself, otherwise it waits for an admin.
+func (me *MatchingEngine) tripCircuitBreaker() {
+	generation, ok := me.halt(HaltReasonPriceBand)
+	if !ok || me.BreachHaltDuration <= 0 {
+		return
+	}
+	time.AfterFunc(me.BreachHaltDuration, func() {
+		me.haltMutex.Lock()
+		sameHalt := me.haltState.halted && me.haltState.generation == generation
+		me.haltMutex.Unlock()
+		if sameHalt {
+			me.Resume()
+		}
+	})
+}
+
+// Blocks the matching loop while the stock is halted or in an auction. New orders are already in the book, so they just wait there.
+// IOC and FOK orders can't wait, so they are cancelled. Stop orders go to the trigger book and trigger on the first trades after.
+// Returns true if the engine was stopped while it waited.
+func (me *MatchingEngine) waitForTrading() bool {
+	println("Continuous trading stopped for stock: ", me.StockId)
+	// nothing is left out with the executor while the stock waits
+	me.drainSettlements()
+	me.cancelImmediateRemainders()
+	me.publishBookTop()
+	for me.IsHalted() || me.InAuction() {
+		select {
+		case <-me.resumeChannel:
+		case request := <-me.uncrossChannel:
+			me.uncross(request)
+		case done := <-me.settleChannel:
+			if me.queued() > 0 {
+				go func() { me.settleChannel <- done }()
+			} else if me.IsHalted() || me.InAuction() {
+				close(done)
+			} else {
+				// trading is about to carry on, so the book isn't settled until it has matched
+				go func() { me.settleChannel <- done }()
+			}
+		case request := <-me.snapshotChannel:
+			me.snapshot(request)
+		case resolution := <-me.resolveChannel:
+			me.resolveQuarantined(resolution)
+		case request := <-me.splitChannel:
+			me.splitBooks(request)
+		case done := <-me.exitChannel:
+			if me.queued() > 0 {
+				go func() { me.exitChannel <- done }()
+				continue
+			}
+			println("Matching loop stopped for stock: ", me.StockId)
+			close(done)
+			return true
+		case stockOrder := <-me.orderChannel:
+			me.holdOrder(stockOrder)
+		}
+	}
+	return false
+}
+
+// An order taken off the intake while the stock isn't trading. Resting orders are already in their book.
+func (me *MatchingEngine) holdOrder(stockOrder order.StockOrderInterface) {
+	if stockOrder.IsStop() {
+		me.TriggerBook.AddOrder(stockOrder)
+	} else if IsImmediate(stockOrder) {
+		println("Not trading. Cancelling ", stockOrder.GetTimeInForce(), " order: ", stockOrder.GetId())
+		me.cancelRemainder(stockOrder)
+	}
+}
+
+// Puts the orders the matching loop is holding back in their books.
+func (me *MatchingEngine) returnHeldOrders(buyOrder order.StockOrderInterface, sellOrder order.StockOrderInterface) {
+	if buyOrder != nil {
+		me.BuyOrderBook.ReturnOrder(buyOrder)
+	}
+	if sellOrder != nil {
+		me.SellOrderBook.ReturnOrder(sellOrder)
+	}
+}
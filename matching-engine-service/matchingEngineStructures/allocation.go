@@ -0,0 +1,53 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/order"
+)
+
+// Part of an incoming order given to one resting order.
+type Allocation struct {
+	Order    order.StockOrderInterface
+	Quantity int
+}
+
+// Splits quantity between the orders resting at one price level, which must be given oldest first.
+// Each order gets its share of quantity in proportion to its size (just the visible slice for an iceberg), rounded down. The shares lost to rounding go one each to the oldest orders.
+// With topOrderPriority the oldest order is filled as far as it can be first, and only what is left is shared.
+// Orders that get nothing are left out.
+func AllocateProRata(level []order.StockOrderInterface, quantity int, topOrderPriority bool) []Allocation {
+	shares := make([]int, len(level))
+	remaining := quantity
+	start := 0
+	if topOrderPriority && len(level) > 0 {
+		shares[0] = min(level[0].GetVisibleQuantity(), remaining)
+		remaining -= shares[0]
+		start = 1
+	}
+	total := 0
+	for _, stockOrder := range level[start:] {
+		total += stockOrder.GetVisibleQuantity()
+	}
+	if remaining >= total {
+		for i := start; i < len(level); i++ {
+			shares[i] = level[i].GetVisibleQuantity()
+		}
+	} else if remaining > 0 {
+		allocated := 0
+		for i := start; i < len(level); i++ {
+			shares[i] = int(int64(remaining) * int64(level[i].GetVisibleQuantity()) / int64(total))
+			allocated += shares[i]
+		}
+		// every order still has room, since none can be given its whole size when remaining < total
+		for i := start; allocated < remaining; i++ {
+			shares[i]++
+			allocated++
+		}
+	}
+	allocations := make([]Allocation, 0, len(level))
+	for i, stockOrder := range level {
+		if shares[i] > 0 {
+			allocations = append(allocations, Allocation{Order: stockOrder, Quantity: shares[i]})
+		}
+	}
+	return allocations
+}
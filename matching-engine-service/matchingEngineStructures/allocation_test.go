@@ -0,0 +1,43 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/order"
+	"testing"
+)
+
+func allocatedQuantities(allocations []Allocation) []int {
+	quantities := make([]int, len(allocations))
+	for i, allocation := range allocations {
+		quantities[i] = allocation.Quantity
+	}
+	return quantities
+}
+
+func TestAllocateProRata(t *testing.T) {
+	level := []order.StockOrderInterface{newAuctionOrder(10, 1000), newAuctionOrder(30, 1000), newAuctionOrder(60, 1000)}
+	cases := []struct {
+		name     string
+		quantity int
+		top      bool
+		want     []int
+	}{
+		{"proportional", 50, false, []int{5, 15, 30}},
+		{"rounding goes to the oldest", 11, false, []int{2, 3, 6}},
+		{"more than the level", 500, false, []int{10, 30, 60}},
+		{"top order first", 40, true, []int{10, 10, 20}},
+		{"top order takes it all", 4, true, []int{4}},
+	}
+	for _, c := range cases {
+		got := allocatedQuantities(AllocateProRata(level, c.quantity, c.top))
+		if len(got) != len(c.want) {
+			t.Errorf("%s: allocated %v, wanted %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: allocated %v, wanted %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"sort"
+)
+
+// Whether an order would trade in an auction clearing at this price. Market orders trade at any price.
+func BuyEligible(buyOrder order.StockOrderInterface, price money.Money) bool {
+	return buyOrder.GetOrderType() == order.OrderTypeMarket || buyOrder.GetPrice() >= price
+}
+
+func SellEligible(sellOrder order.StockOrderInterface, price money.Money) bool {
+	return sellOrder.GetOrderType() == order.OrderTypeMarket || sellOrder.GetPrice() <= price
+}
+
+// The single price a call auction uncrosses at, and how many shares trade there.
+// Every limit price in the book is a candidate. The winner executes the most shares, then leaves the smallest imbalance,
+// then is closest to the reference price (the last trade, 0 if there isn't one), then is the lowest.
+// When only market orders are on one side or both, the reference price is used. Returns 0, 0 if nothing crosses.
+func ClearingPrice(buyOrders []order.StockOrderInterface, sellOrders []order.StockOrderInterface, referencePrice money.Money) (money.Money, int) {
+	candidates := make(map[money.Money]bool)
+	for _, stockOrder := range append(append([]order.StockOrderInterface{}, buyOrders...), sellOrders...) {
+		if stockOrder.GetOrderType() == order.OrderTypeLimit {
+			candidates[stockOrder.GetPrice()] = true
+		}
+	}
+	if len(candidates) == 0 && referencePrice != 0 {
+		candidates[referencePrice] = true
+	}
+	prices := make([]money.Money, 0, len(candidates))
+	for price := range candidates {
+		prices = append(prices, price)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+
+	var bestPrice money.Money
+	bestVolume, bestImbalance := 0, 0
+	for _, price := range prices {
+		demand, supply := 0, 0
+		for _, buyOrder := range buyOrders {
+			if BuyEligible(buyOrder, price) {
+				demand += buyOrder.GetQuantity()
+			}
+		}
+		for _, sellOrder := range sellOrders {
+			if SellEligible(sellOrder, price) {
+				supply += sellOrder.GetQuantity()
+			}
+		}
+		volume := min(demand, supply)
+		imbalance := max(demand, supply) - volume
+		if volume == 0 || volume < bestVolume {
+			continue
+		}
+		// prices are ascending, so on a full tie the lower one already won
+		if volume > bestVolume || imbalance < bestImbalance ||
+			imbalance == bestImbalance && referencePrice != 0 && distance(price, referencePrice) < distance(bestPrice, referencePrice) {
+			bestPrice, bestVolume, bestImbalance = price, volume, imbalance
+		}
+	}
+	return bestPrice, bestVolume
+}
+
+func distance(a money.Money, b money.Money) money.Money {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,46 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"testing"
+)
+
+func newAuctionOrder(quantity int, price money.Money) order.StockOrderInterface {
+	orderType := order.OrderTypeLimit
+	if price == 0 {
+		orderType = order.OrderTypeMarket
+	}
+	return order.New(order.NewStockOrderParams{
+		OrderType: orderType,
+		Quantity:  quantity,
+		Price:     price,
+	})
+}
+
+func TestClearingPriceMaximizesVolume(t *testing.T) {
+	buys := []order.StockOrderInterface{newAuctionOrder(100, 1010), newAuctionOrder(50, 1000), newAuctionOrder(30, 0)}
+	sells := []order.StockOrderInterface{newAuctionOrder(80, 990), newAuctionOrder(60, 1000), newAuctionOrder(40, 1020)}
+	if price, volume := ClearingPrice(buys, sells, 0); price != 1000 || volume != 140 {
+		t.Errorf("cleared %d shares at %d, wanted 140 at 1000", volume, price)
+	}
+}
+
+func TestClearingPriceTieBreaks(t *testing.T) {
+	buys := []order.StockOrderInterface{newAuctionOrder(10, 1010)}
+	sells := []order.StockOrderInterface{newAuctionOrder(10, 1000)}
+	if price, _ := ClearingPrice(buys, sells, 1008); price != 1010 {
+		t.Errorf("expected the price nearest the reference, got %d", price)
+	}
+	if price, _ := ClearingPrice(buys, sells, 0); price != 1000 {
+		t.Errorf("expected the lowest price without a reference, got %d", price)
+	}
+	marketBuys := []order.StockOrderInterface{newAuctionOrder(5, 0)}
+	marketSells := []order.StockOrderInterface{newAuctionOrder(5, 0)}
+	if price, volume := ClearingPrice(marketBuys, marketSells, 1234); price != 1234 || volume != 5 {
+		t.Errorf("expected market orders to clear at the reference, got %d shares at %d", volume, price)
+	}
+	if _, volume := ClearingPrice(marketBuys, marketSells, 0); volume != 0 {
+		t.Errorf("market orders with no reference price shouldn't clear")
+	}
+}
@@ -0,0 +1,93 @@
+package matchingEngineStructures
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Points each instance gets on the ring. More points spread the stocks more evenly.
+const DefaultVirtualNodes = 100
+
+type HashRingInterface interface {
+	Owner(key string) string
+	GetInstances() []string
+}
+
+type ringPoint struct {
+	hash     uint64
+	instance string
+}
+
+// HashRing Structure. A key belongs to the first instance point at or after its hash, going round.
+// Adding an instance only takes keys from the others, and removing one only gives its keys away, so few stocks move.
+type HashRing struct {
+	instances []string
+	points    []ringPoint
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// Empty if the ring has no instances.
+func (r *HashRing) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].instance
+}
+
+// Sorted by name.
+func (r *HashRing) GetInstances() []string {
+	instances := make([]string, len(r.instances))
+	copy(instances, r.instances)
+	return instances
+}
+
+type NewHashRingParams struct {
+	Instances    []string
+	VirtualNodes int // leave 0 for the default
+}
+
+// Duplicate and empty instance names are dropped.
+func NewHashRing(params *NewHashRingParams) HashRingInterface {
+	if params.VirtualNodes <= 0 {
+		params.VirtualNodes = DefaultVirtualNodes
+	}
+	seen := map[string]bool{}
+	instances := []string{}
+	for _, instance := range params.Instances {
+		if instance == "" || seen[instance] {
+			continue
+		}
+		seen[instance] = true
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+	points := make([]ringPoint, 0, len(instances)*params.VirtualNodes)
+	for _, instance := range instances {
+		for i := 0; i < params.VirtualNodes; i++ {
+			points = append(points, ringPoint{hash: hashKey(instance + "#" + strconv.Itoa(i)), instance: instance})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].instance < points[j].instance
+		}
+		return points[i].hash < points[j].hash
+	})
+	return &HashRing{
+		instances: instances,
+		points:    points,
+	}
+}
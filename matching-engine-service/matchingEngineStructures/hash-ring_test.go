@@ -0,0 +1,26 @@
+package matchingEngineStructures
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHashRingOnlyMovesStocksToANewInstance(t *testing.T) {
+	before := NewHashRing(&NewHashRingParams{Instances: []string{"engine-a", "engine-b", "engine-c"}})
+	after := NewHashRing(&NewHashRingParams{Instances: []string{"engine-a", "engine-b", "engine-c", "engine-d"}})
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		stockID := "stock-" + strconv.Itoa(i)
+		if before.Owner(stockID) == after.Owner(stockID) {
+			continue
+		}
+		if after.Owner(stockID) != "engine-d" {
+			t.Fatalf("stock %s moved from %s to %s, not to the new instance", stockID, before.Owner(stockID), after.Owner(stockID))
+		}
+		moved++
+	}
+	// a quarter should move, give or take
+	if moved < 100 || moved > 400 {
+		t.Errorf("expected about 250 of 1000 stocks to move to the new instance, got %d", moved)
+	}
+}
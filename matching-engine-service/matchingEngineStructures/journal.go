@@ -0,0 +1,253 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"Shared/entities/stock"
+	"Shared/network"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// What a journal entry records. Everything the engine is told from outside, and what the executor said about each match.
+const (
+	JournalStart        = "START"
+	JournalAddOrder     = "ADD_ORDER"
+	JournalRemoveOrder  = "REMOVE_ORDER"
+	JournalAmendOrder   = "AMEND_ORDER"
+	JournalMatchResult  = "MATCH_RESULT"
+	JournalHalt         = "HALT"
+	JournalResume       = "RESUME"
+	JournalStartAuction = "START_AUCTION"
+	JournalUncross      = "UNCROSS"
+	JournalResolveMatch = "RESOLVE_MATCH"
+	JournalSplit        = "SPLIT"
+)
+
+// One line of the journal. Only the fields for its Type are set.
+type JournalEntry struct {
+	Sequence  uint64                     `json:"sequence"`
+	Type      string                     `json:"type"`
+	Timestamp time.Time                  `json:"timestamp"`
+	Start     *BookState                 `json:"start,omitempty"`     // START, the book the engine started with
+	Order     *order.NewStockOrderParams `json:"order,omitempty"`     // ADD_ORDER
+	OrderID   string                     `json:"order_id,omitempty"`  // REMOVE_ORDER and AMEND_ORDER
+	PriceKey  money.Money                `json:"price_key,omitempty"` // the price the order was resting at
+	IsBuy     bool                       `json:"is_buy,omitempty"`
+	Quantity  int                        `json:"quantity,omitempty"` // AMEND_ORDER's new quantity, or the shares a MATCH_RESULT traded
+	Price     money.Money                `json:"price,omitempty"`    // AMEND_ORDER's new price, or the price a MATCH_RESULT traded at
+	Reason    string                     `json:"reason,omitempty"`   // why a HALT or REMOVE_ORDER happened, the error a MATCH_RESULT got, or how a RESOLVE_MATCH was resolved
+	// MATCH_RESULT and RESOLVE_MATCH only
+	MatchID     string                                `json:"match_id,omitempty"`
+	BuyOrderID  string                                `json:"buy_order_id,omitempty"`
+	SellOrderID string                                `json:"sell_order_id,omitempty"`
+	Result      *network.ExecutorToMatchingEngineJSON `json:"result,omitempty"`
+	Split       *stock.Split                          `json:"split,omitempty"` // SPLIT
+}
+
+type JournalInterface interface {
+	Append(entry JournalEntry) (JournalEntry, error)
+	GetSequence() uint64
+	GetPath() string
+	Compact(start JournalEntry) error
+	Close() error
+}
+
+// Journal Structure, an append only file of JSON lines, one per entry, numbered from 1.
+// Every entry is synced to disk before Append returns, so nothing the engine acted on is lost in a crash.
+// Compact cuts off what a snapshot already holds, so the file only grows between snapshots.
+type Journal struct {
+	path     string
+	file     *os.File
+	sequence uint64
+	mutex    *sync.Mutex
+}
+
+// Gives the entry the next sequence number, and the current time if it has none.
+func (j *Journal) Append(entry JournalEntry) (JournalEntry, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	entry.Sequence = j.sequence + 1
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+	_, err = j.file.Write(append(line, '\n'))
+	if err != nil {
+		return entry, err
+	}
+	err = j.file.Sync()
+	if err != nil {
+		return entry, err
+	}
+	j.sequence = entry.Sequence
+	return entry, nil
+}
+
+// The sequence number of the last entry written, 0 for an empty journal.
+func (j *Journal) GetSequence() uint64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.sequence
+}
+
+func (j *Journal) GetPath() string {
+	return j.path
+}
+
+// Rewrites the journal as start, a START entry holding the book as of its sequence number, followed by the entries after it.
+// The numbering carries on as before, and a replay of the journal starts from that book rather than from the first entry.
+// The new file is synced and renamed over the old one, so a crash leaves one or the other.
+func (j *Journal) Compact(start JournalEntry) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if start.Type != JournalStart || start.Start == nil {
+		return fmt.Errorf("journal %s can only be compacted down to a START entry", j.path)
+	}
+	if start.Sequence > j.sequence {
+		return fmt.Errorf("journal %s ends at sequence %d, before %d", j.path, j.sequence, start.Sequence)
+	}
+	entries, _, err := readJournal(j.path)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	err = encoder.Encode(start)
+	for _, entry := range entries {
+		if err != nil {
+			break
+		}
+		if entry.Sequence > start.Sequence {
+			err = encoder.Encode(entry)
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	err = os.Rename(file.Name(), j.path)
+	if err != nil {
+		return err
+	}
+	// appends carry on in the new file
+	compacted, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	j.file.Close()
+	j.file = compacted
+	return nil
+}
+
+func (j *Journal) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.file.Close()
+}
+
+type NewJournalParams struct {
+	Directory string // created if it doesn't exist
+	StockID   string
+}
+
+// Opens the stock's journal for appending, carrying on from the last sequence number already in it.
+// A torn last line is cut off first, so new entries follow straight on from the last good one.
+func NewJournal(params *NewJournalParams) (JournalInterface, error) {
+	err := os.MkdirAll(params.Directory, 0755)
+	if err != nil {
+		return nil, err
+	}
+	path := JournalPath(params.Directory, params.StockID)
+	entries, validLength, err := readJournal(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > validLength {
+		println("Cutting torn last line off ", path)
+		err = file.Truncate(validLength)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	journal := &Journal{
+		path:  path,
+		file:  file,
+		mutex: &sync.Mutex{},
+	}
+	if len(entries) > 0 {
+		journal.sequence = entries[len(entries)-1].Sequence
+	}
+	return journal, nil
+}
+
+func JournalPath(directory string, stockID string) string {
+	return filepath.Join(directory, stockID+".journal")
+}
+
+// Reads every entry in a journal file, oldest first. A torn last line, from a crash part way through a write, is left out.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	entries, _, err := readJournal(path)
+	return entries, err
+}
+
+// Also returns how many bytes of the file hold whole entries.
+func readJournal(path string) ([]JournalEntry, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	entries := []JournalEntry{}
+	var validLength int64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	var badLine error
+	for scanner.Scan() {
+		lineNumber++
+		if badLine != nil {
+			// only the very last line can be torn, anything after a bad line means the file is damaged
+			return nil, 0, badLine
+		}
+		var entry JournalEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			badLine = fmt.Errorf("journal %s line %d: %v", path, lineNumber, err)
+			continue
+		}
+		if len(entries) > 0 && entry.Sequence != entries[len(entries)-1].Sequence+1 {
+			return nil, 0, fmt.Errorf("journal %s line %d: sequence %d follows %d", path, lineNumber, entry.Sequence, entries[len(entries)-1].Sequence)
+		}
+		entries = append(entries, entry)
+		validLength += int64(len(scanner.Bytes())) + 1
+	}
+	return entries, validLength, scanner.Err()
+}
@@ -0,0 +1,77 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/order"
+	"os"
+	"testing"
+)
+
+func TestJournalCarriesOnAfterATornLine(t *testing.T) {
+	directory := t.TempDir()
+	journal, err := NewJournal(&NewJournalParams{Directory: directory, StockID: "stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(JournalEntry{Type: JournalAddOrder, Order: &order.NewStockOrderParams{Quantity: 5}})
+	journal.Append(JournalEntry{Type: JournalRemoveOrder, OrderID: "a"})
+	journal.Close()
+	// a crash part way through the third write
+	file, _ := os.OpenFile(JournalPath(directory, "stock"), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"sequence":3,"ty`)
+	file.Close()
+
+	journal, err = NewJournal(&NewJournalParams{Directory: directory, StockID: "stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if journal.GetSequence() != 2 {
+		t.Errorf("expected to carry on from sequence 2, got %d", journal.GetSequence())
+	}
+	journal.Append(JournalEntry{Type: JournalHalt, Reason: "ADMIN"})
+	journal.Close()
+	entries, err := ReadJournal(JournalPath(directory, "stock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[2].Sequence != 3 || entries[2].Type != JournalHalt || entries[0].Order.Quantity != 5 {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func TestJournalCompactsDownToAStart(t *testing.T) {
+	directory := t.TempDir()
+	journal, err := NewJournal(&NewJournalParams{Directory: directory, StockID: "stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(JournalEntry{Type: JournalAddOrder, Order: &order.NewStockOrderParams{Quantity: 5}})
+	journal.Append(JournalEntry{Type: JournalRemoveOrder, OrderID: "a"})
+	journal.Append(JournalEntry{Type: JournalHalt, Reason: "ADMIN"})
+	// a snapshot taken after the second entry
+	err = journal.Compact(JournalEntry{Sequence: 2, Type: JournalStart, Start: &BookState{Halted: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(JournalEntry{Type: JournalResume})
+	journal.Close()
+
+	journal, err = NewJournal(&NewJournalParams{Directory: directory, StockID: "stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	if journal.GetSequence() != 4 {
+		t.Errorf("expected to carry on from sequence 4, got %d", journal.GetSequence())
+	}
+	entries, err := ReadJournal(JournalPath(directory, "stock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Sequence != 2 || entries[0].Type != JournalStart || !entries[0].Start.Halted ||
+		entries[1].Type != JournalHalt || entries[2].Sequence != 4 || entries[2].Type != JournalResume {
+		t.Errorf("unexpected entries %v", entries)
+	}
+	if journal.Compact(JournalEntry{Sequence: 5, Type: JournalStart, Start: &BookState{}}) == nil {
+		t.Error("expected compacting past the end of the journal to be refused")
+	}
+}
@@ -0,0 +1,97 @@
+package matchingEngineStructures
+
+import (
+	"sync"
+)
+
+// Market data event types
+const (
+	EventBookTop     = "book_top"
+	EventTrade       = "trade"
+	EventOrderStatus = "order_status"
+)
+
+type MarketDataEvent struct {
+	Type    string
+	StockID string
+	UserID  string // only set for order status events, which only go to that user
+	Data    any
+}
+
+// One streaming client. Events for its stocks, and its own order status updates, arrive on Events.
+type MarketDataSubscription struct {
+	Events   chan MarketDataEvent
+	stockIDs map[string]bool
+	userID   string
+}
+
+type MarketDataHubInterface interface {
+	Subscribe(stockIDs []string, userID string) *MarketDataSubscription
+	Unsubscribe(subscription *MarketDataSubscription)
+	Publish(event MarketDataEvent)
+}
+
+// MarketDataHub Structure, fans events out from the matching engines to every streaming client.
+// Publishing never blocks matching. A client that falls a full buffer behind misses events until it catches up.
+type MarketDataHub struct {
+	subscriptions map[*MarketDataSubscription]bool
+	bufferSize    int
+	mutex         *sync.RWMutex
+}
+
+func (h *MarketDataHub) Subscribe(stockIDs []string, userID string) *MarketDataSubscription {
+	subscription := &MarketDataSubscription{
+		Events:   make(chan MarketDataEvent, h.bufferSize),
+		stockIDs: make(map[string]bool),
+		userID:   userID,
+	}
+	for _, stockID := range stockIDs {
+		subscription.stockIDs[stockID] = true
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subscriptions[subscription] = true
+	return subscription
+}
+
+func (h *MarketDataHub) Unsubscribe(subscription *MarketDataSubscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.subscriptions, subscription)
+}
+
+func (h *MarketDataHub) Publish(event MarketDataEvent) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for subscription := range h.subscriptions {
+		if event.UserID != "" {
+			if event.UserID != subscription.userID {
+				continue
+			}
+		} else if !subscription.stockIDs[event.StockID] {
+			continue
+		}
+		select {
+		case subscription.Events <- event:
+		default:
+			println("Dropping ", event.Type, " event for slow subscriber")
+		}
+	}
+}
+
+type NewMarketDataHubParams struct {
+	BufferSize int // events held per client. leave 0 for the default
+}
+
+const DefaultMarketDataBufferSize = 256
+
+func NewMarketDataHub(params *NewMarketDataHubParams) MarketDataHubInterface {
+	if params.BufferSize <= 0 {
+		params.BufferSize = DefaultMarketDataBufferSize
+	}
+	return &MarketDataHub{
+		subscriptions: make(map[*MarketDataSubscription]bool),
+		bufferSize:    params.BufferSize,
+		mutex:         &sync.RWMutex{},
+	}
+}
@@ -0,0 +1,24 @@
+package matchingEngineStructures
+
+import "testing"
+
+func TestMarketDataHubRoutesEvents(t *testing.T) {
+	hub := NewMarketDataHub(&NewMarketDataHubParams{BufferSize: 1})
+	subscription := hub.Subscribe([]string{"stockA"}, "user1")
+	hub.Publish(MarketDataEvent{Type: EventTrade, StockID: "stockB"})
+	hub.Publish(MarketDataEvent{Type: EventOrderStatus, StockID: "stockA", UserID: "user2"})
+	hub.Publish(MarketDataEvent{Type: EventOrderStatus, StockID: "stockB", UserID: "user1"})
+	// buffer is full, so this one is dropped rather than blocking
+	hub.Publish(MarketDataEvent{Type: EventTrade, StockID: "stockA"})
+	if event := <-subscription.Events; event.Type != EventOrderStatus || event.UserID != "user1" {
+		t.Errorf("unexpected event %v", event)
+	}
+	if len(subscription.Events) != 0 {
+		t.Errorf("expected other events to be filtered or dropped")
+	}
+	hub.Unsubscribe(subscription)
+	hub.Publish(MarketDataEvent{Type: EventTrade, StockID: "stockA"})
+	if len(subscription.Events) != 0 {
+		t.Errorf("got an event after unsubscribing")
+	}
+}
@@ -1,8 +1,12 @@
 package matchingEngineStructures
 
 import (
+	"Shared/entities/money"
 	"Shared/entities/order"
+	"container/heap"
 	"container/list"
+	"os"
+	"sort"
 )
 
 // Base Structure
@@ -23,13 +27,34 @@ type OrderBookDataStructureInterface interface {
 	PushFront(stockOrder order.StockOrderInterface)
 	PopNext() order.StockOrderInterface
 	Remove(params *RemoveParams) order.StockOrderInterface
+	Find(params *RemoveParams) order.StockOrderInterface // like Remove, but the order stays where it is
 	Length() int
-	GetPrice() float64
+	GetPrice() money.Money
+	Orders() []order.StockOrderInterface    // every order held, in the order they would be matched
+	BestLevel() []order.StockOrderInterface // the orders at the best price, oldest first
+}
+
+// Implemented by structures that can be filled with a whole book at once, faster than pushing one order at a time.
+// The structure must be empty, and the orders in the order they would be matched, like Orders gives them.
+type BulkLoadInterface interface {
+	Load(orders []order.StockOrderInterface)
+}
+
+// Implemented by the price sorted structures, so depth queries can walk the book best price first.
+type PriceLevelsInterface interface {
+	GetPriceLevels(depth int) []PriceLevel // depth <= 0 returns every level
+}
+
+// Aggregated view of a single price in the book.
+type PriceLevel struct {
+	Price      money.Money
+	Quantity   int
+	OrderCount int
 }
 
 type RemoveParams struct {
 	OrderID  string
-	PriceKey float64
+	PriceKey money.Money
 }
 
 // Queue Structure, where adding is immediatly added to the back. Removing is done from the front.
@@ -56,6 +81,15 @@ func (q *Queue) PopNext() order.StockOrderInterface {
 	return nil
 }
 
+func (q *Queue) Find(params *RemoveParams) order.StockOrderInterface {
+	for e := q.data.Front(); e != nil; e = e.Next() {
+		if e.Value.(order.StockOrderInterface).GetId() == params.OrderID {
+			return e.Value.(order.StockOrderInterface)
+		}
+	}
+	return nil
+}
+
 func (q *Queue) Remove(params *RemoveParams) order.StockOrderInterface {
 	for e := q.data.Front(); e != nil; e = e.Next() {
 		if e.Value.(order.StockOrderInterface).GetId() == params.OrderID {
@@ -70,6 +104,19 @@ func (q *Queue) Length() int {
 	return q.data.Len()
 }
 
+func (q *Queue) Orders() []order.StockOrderInterface {
+	orders := make([]order.StockOrderInterface, 0, q.data.Len())
+	for e := q.data.Front(); e != nil; e = e.Next() {
+		orders = append(orders, e.Value.(order.StockOrderInterface))
+	}
+	return orders
+}
+
+// A queue is a single level.
+func (q *Queue) BestLevel() []order.StockOrderInterface {
+	return q.Orders()
+}
+
 type NewQueueParams struct {
 	*NewOrderBookDataStructureParams
 }
@@ -81,7 +128,7 @@ func NewQueue(params *NewQueueParams) OrderBookDataStructureInterface {
 	}
 }
 
-func (q *Queue) GetPrice() float64 {
+func (q *Queue) GetPrice() money.Money {
 	if q.data.Len() == 0 {
 		return 0
 	}
@@ -90,21 +137,26 @@ func (q *Queue) GetPrice() float64 {
 
 // PriceNodeMap Structure, where adding is added according to a logic system, and removing is based on a key system.
 // In truth, this is more going to resemble a hash map, with the key being the price.
+// By default the lowest price is the best price (asks). Set Descending for the highest price to be the best price (bids).
 type PriceNodeMap struct {
 	BaseOrderBookDataStructureInterface
-	data             map[float64]*PriceNode
-	currentBestPrice float64
+	data             map[money.Money]*PriceNode
+	currentBestPrice money.Money
+	descending       bool
+}
+
+// returns true if price a should be matched before price b.
+func (p *PriceNodeMap) isBetterPrice(a money.Money, b money.Money) bool {
+	if p.descending {
+		return a > b
+	}
+	return a < b
 }
 
-func (p *PriceNodeMap) ensureNodeExists(key float64) {
+func (p *PriceNodeMap) ensureNodeExists(key money.Money) {
 	if _, ok := p.data[key]; !ok {
-		p.data[key] = &PriceNode{
-			priceList: NewQueue(&NewQueueParams{
-				NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{},
-			}),
-			priceValue: key,
-		}
-		if key < p.currentBestPrice || p.currentBestPrice == 0 {
+		p.data[key] = newPriceNode(key)
+		if p.isBetterPrice(key, p.currentBestPrice) || len(p.data) == 1 {
 			p.currentBestPrice = key
 		}
 	}
@@ -112,13 +164,15 @@ func (p *PriceNodeMap) ensureNodeExists(key float64) {
 
 func (p *PriceNodeMap) validateNode(node *PriceNode) {
 	if node.priceList.Length() == 0 {
+		delete(p.data, node.priceValue)
 		p.currentBestPrice = 0
+		first := true
 		for key := range p.data {
-			if key < p.currentBestPrice || p.currentBestPrice == 0 {
+			if first || p.isBetterPrice(key, p.currentBestPrice) {
 				p.currentBestPrice = key
+				first = false
 			}
 		}
-		delete(p.data, node.priceValue)
 	}
 }
 
@@ -130,7 +184,7 @@ func (p *PriceNodeMap) Push(stockOrder order.StockOrderInterface) {
 }
 
 func (p *PriceNodeMap) PushFront(stockOrder order.StockOrderInterface) {
-	price := float64(stockOrder.GetPrice())
+	price := stockOrder.GetPrice()
 	p.ensureNodeExists(price)
 	p.data[price].priceList.PushFront(stockOrder)
 }
@@ -144,6 +198,13 @@ func (p *PriceNodeMap) PopNext() order.StockOrderInterface {
 	return nil
 }
 
+func (p *PriceNodeMap) Find(params *RemoveParams) order.StockOrderInterface {
+	if node, ok := p.data[params.PriceKey]; ok {
+		return node.priceList.Find(params)
+	}
+	return nil
+}
+
 func (p *PriceNodeMap) Remove(params *RemoveParams) order.StockOrderInterface {
 	price := params.PriceKey
 	if node, ok := p.data[price]; ok {
@@ -158,24 +219,402 @@ func (p *PriceNodeMap) Length() int {
 	return len(p.data)
 }
 
+func (p *PriceNodeMap) Orders() []order.StockOrderInterface {
+	var orders []order.StockOrderInterface
+	for _, node := range p.sortedNodes() {
+		orders = append(orders, node.priceList.Orders()...)
+	}
+	return orders
+}
+
+func (p *PriceNodeMap) BestLevel() []order.StockOrderInterface {
+	if node, ok := p.data[p.currentBestPrice]; ok {
+		return node.priceList.Orders()
+	}
+	return nil
+}
+
+func (p *PriceNodeMap) GetPriceLevels(depth int) []PriceLevel {
+	nodes := p.sortedNodes()
+	if depth > 0 && depth < len(nodes) {
+		nodes = nodes[:depth]
+	}
+	levels := make([]PriceLevel, len(nodes))
+	for i, node := range nodes {
+		levels[i] = node.summarize()
+	}
+	return levels
+}
+
+func (p *PriceNodeMap) sortedNodes() []*PriceNode {
+	nodes := make([]*PriceNode, 0, len(p.data))
+	for _, node := range p.data {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return p.isBetterPrice(nodes[i].priceValue, nodes[j].priceValue)
+	})
+	return nodes
+}
+
 type NewPriceNodeMapParams struct {
 	*NewOrderBookDataStructureParams
+	Descending bool // false for asks (lowest price first), true for bids (highest price first)
 }
 
 func NewPriceNodeMap(params *NewPriceNodeMapParams) OrderBookDataStructureInterface {
 	return &PriceNodeMap{
 		BaseOrderBookDataStructureInterface: NewBaseOrderBookDataStructure(params.NewOrderBookDataStructureParams),
-		data:                                make(map[float64]*PriceNode),
+		data:                                make(map[money.Money]*PriceNode),
 		currentBestPrice:                    0,
+		descending:                          params.Descending,
 	}
 }
 
-func (p *PriceNodeMap) GetPrice() float64 {
+func (p *PriceNodeMap) GetPrice() money.Money {
 	return p.currentBestPrice
 }
 
-// PriceNode Structure, for internal usage on the PriceNodeMap.
+// PriceNode Structure, for internal usage on the PriceNodeMap and PriceLevelHeap.
 type PriceNode struct {
 	priceList  OrderBookDataStructureInterface
-	priceValue float64
+	priceValue money.Money
+	heapIndex  int // position in the PriceLevelHeap. Unused by the PriceNodeMap.
+}
+
+func newPriceNode(price money.Money) *PriceNode {
+	return &PriceNode{
+		priceList: NewQueue(&NewQueueParams{
+			NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{},
+		}),
+		priceValue: price,
+	}
+}
+
+func (n *PriceNode) summarize() PriceLevel {
+	level := PriceLevel{Price: n.priceValue}
+	for _, stockOrder := range n.priceList.Orders() {
+		// an iceberg's hidden reserve isn't shown
+		level.Quantity += stockOrder.GetVisibleQuantity()
+		level.OrderCount++
+	}
+	return level
+}
+
+// PriceLevelHeap Structure, the same price keyed levels as the PriceNodeMap, with the levels also kept in a binary heap.
+// Adding a level and moving on to the next best price once a level empties are both O(log n), rather than a scan of every level.
+// By default the lowest price is the best price (asks). Set Descending for the highest price to be the best price (bids).
+type PriceLevelHeap struct {
+	BaseOrderBookDataStructureInterface
+	data   map[money.Money]*PriceNode
+	levels *priceNodeHeap
+}
+
+func (p *PriceLevelHeap) ensureNodeExists(key money.Money) *PriceNode {
+	node, ok := p.data[key]
+	if !ok {
+		node = newPriceNode(key)
+		p.data[key] = node
+		heap.Push(p.levels, node)
+	}
+	return node
+}
+
+func (p *PriceLevelHeap) validateNode(node *PriceNode) {
+	if node.priceList.Length() == 0 {
+		delete(p.data, node.priceValue)
+		heap.Remove(p.levels, node.heapIndex)
+	}
+}
+
+func (p *PriceLevelHeap) Push(stockOrder order.StockOrderInterface) {
+	p.ensureNodeExists(stockOrder.GetPrice()).priceList.Push(stockOrder)
+}
+
+func (p *PriceLevelHeap) PushFront(stockOrder order.StockOrderInterface) {
+	p.ensureNodeExists(stockOrder.GetPrice()).priceList.PushFront(stockOrder)
+}
+
+func (p *PriceLevelHeap) PopNext() order.StockOrderInterface {
+	if p.levels.Len() == 0 {
+		return nil
+	}
+	node := p.levels.nodes[0]
+	order := node.priceList.PopNext()
+	p.validateNode(node)
+	return order
+}
+
+// Builds every level first and heapifies them once, rather than pushing each new level onto the heap.
+func (p *PriceLevelHeap) Load(orders []order.StockOrderInterface) {
+	for _, stockOrder := range orders {
+		node, ok := p.data[stockOrder.GetPrice()]
+		if !ok {
+			node = newPriceNode(stockOrder.GetPrice())
+			p.data[stockOrder.GetPrice()] = node
+			p.levels.nodes = append(p.levels.nodes, node)
+		}
+		node.priceList.Push(stockOrder)
+	}
+	for i, node := range p.levels.nodes {
+		node.heapIndex = i
+	}
+	heap.Init(p.levels)
+}
+
+func (p *PriceLevelHeap) Find(params *RemoveParams) order.StockOrderInterface {
+	if node, ok := p.data[params.PriceKey]; ok {
+		return node.priceList.Find(params)
+	}
+	return nil
+}
+
+func (p *PriceLevelHeap) Remove(params *RemoveParams) order.StockOrderInterface {
+	if node, ok := p.data[params.PriceKey]; ok {
+		order := node.priceList.Remove(params)
+		p.validateNode(node)
+		return order
+	}
+	return nil
+}
+
+// Number of price levels, to match the PriceNodeMap.
+func (p *PriceLevelHeap) Length() int {
+	return p.levels.Len()
+}
+
+func (p *PriceLevelHeap) GetPrice() money.Money {
+	if p.levels.Len() == 0 {
+		return 0
+	}
+	return p.levels.nodes[0].priceValue
+}
+
+func (p *PriceLevelHeap) Orders() []order.StockOrderInterface {
+	var orders []order.StockOrderInterface
+	for _, node := range p.bestNodes(0) {
+		orders = append(orders, node.priceList.Orders()...)
+	}
+	return orders
+}
+
+func (p *PriceLevelHeap) BestLevel() []order.StockOrderInterface {
+	if p.levels.Len() == 0 {
+		return nil
+	}
+	return p.levels.nodes[0].priceList.Orders()
+}
+
+func (p *PriceLevelHeap) GetPriceLevels(depth int) []PriceLevel {
+	nodes := p.bestNodes(depth)
+	levels := make([]PriceLevel, len(nodes))
+	for i, node := range nodes {
+		levels[i] = node.summarize()
+	}
+	return levels
+}
+
+// Walks the heap best first without touching it. A level's children are never better than it, so the next best level
+// is always among the children of the levels already taken, and only those wait on a small scratch heap.
+func (p *PriceLevelHeap) bestNodes(depth int) []*PriceNode {
+	if depth <= 0 || depth > p.levels.Len() {
+		depth = p.levels.Len()
+	}
+	nodes := make([]*PriceNode, 0, depth)
+	if depth == 0 {
+		return nodes
+	}
+	next := &priceNodeHeap{
+		nodes:      []*PriceNode{p.levels.nodes[0]},
+		descending: p.levels.descending,
+		scratch:    true,
+	}
+	for len(nodes) < depth {
+		node := heap.Pop(next).(*PriceNode)
+		nodes = append(nodes, node)
+		for _, child := range []int{2*node.heapIndex + 1, 2*node.heapIndex + 2} {
+			if child < p.levels.Len() {
+				heap.Push(next, p.levels.nodes[child])
+			}
+		}
+	}
+	return nodes
+}
+
+type NewPriceLevelHeapParams struct {
+	*NewOrderBookDataStructureParams
+	Descending bool // false for asks (lowest price first), true for bids (highest price first)
+}
+
+func NewPriceLevelHeap(params *NewPriceLevelHeapParams) OrderBookDataStructureInterface {
+	return &PriceLevelHeap{
+		BaseOrderBookDataStructureInterface: NewBaseOrderBookDataStructure(params.NewOrderBookDataStructureParams),
+		data:                                make(map[money.Money]*PriceNode),
+		levels:                              &priceNodeHeap{descending: params.Descending},
+	}
+}
+
+// heap.Interface over the price nodes, best price at the root.
+// A scratch heap is a throwaway one for walking the book, and leaves the heapIndex of the nodes alone.
+type priceNodeHeap struct {
+	nodes      []*PriceNode
+	descending bool
+	scratch    bool
+}
+
+func (h *priceNodeHeap) Len() int { return len(h.nodes) }
+
+func (h *priceNodeHeap) Less(i, j int) bool {
+	if h.descending {
+		return h.nodes[i].priceValue > h.nodes[j].priceValue
+	}
+	return h.nodes[i].priceValue < h.nodes[j].priceValue
+}
+
+func (h *priceNodeHeap) Swap(i, j int) {
+	h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i]
+	if !h.scratch {
+		h.nodes[i].heapIndex = i
+		h.nodes[j].heapIndex = j
+	}
+}
+
+func (h *priceNodeHeap) Push(x any) {
+	node := x.(*PriceNode)
+	if !h.scratch {
+		node.heapIndex = len(h.nodes)
+	}
+	h.nodes = append(h.nodes, node)
+}
+
+func (h *priceNodeHeap) Pop() any {
+	last := len(h.nodes) - 1
+	node := h.nodes[last]
+	h.nodes[last] = nil
+	h.nodes = h.nodes[:last]
+	return node
+}
+
+// Picks the structure used for the price levels of the default order books.
+// Set ORDER_BOOK_PRICE_LEVELS=map to fall back to the PriceNodeMap, e.g. for comparison benchmarks. Anything else uses the PriceLevelHeap.
+func NewPriceLevels(descending bool) OrderBookDataStructureInterface {
+	if os.Getenv("ORDER_BOOK_PRICE_LEVELS") == "map" {
+		return NewPriceNodeMap(&NewPriceNodeMapParams{
+			NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{},
+			Descending:                      descending,
+		})
+	}
+	return NewPriceLevelHeap(&NewPriceLevelHeapParams{
+		NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{},
+		Descending:                      descending,
+	})
+}
+
+// MarketLimitNodeMap Structure, where market orders are held in a Queue ahead of the limit orders.
+// Market orders have no price, so they are always matched first, oldest to newest.
+// Limit orders are held in a price sorted structure (usually a PriceNodeMap), giving price-time priority.
+type MarketLimitNodeMap struct {
+	BaseOrderBookDataStructureInterface
+	marketOrders OrderBookDataStructureInterface
+	limitOrders  OrderBookDataStructureInterface
+}
+
+func (m *MarketLimitNodeMap) Push(stockOrder order.StockOrderInterface) {
+	if stockOrder.GetOrderType() == order.OrderTypeMarket {
+		m.marketOrders.Push(stockOrder)
+	} else {
+		m.limitOrders.Push(stockOrder)
+	}
+}
+
+func (m *MarketLimitNodeMap) PushFront(stockOrder order.StockOrderInterface) {
+	if stockOrder.GetOrderType() == order.OrderTypeMarket {
+		m.marketOrders.PushFront(stockOrder)
+	} else {
+		m.limitOrders.PushFront(stockOrder)
+	}
+}
+
+func (m *MarketLimitNodeMap) PopNext() order.StockOrderInterface {
+	if m.marketOrders.Length() > 0 {
+		return m.marketOrders.PopNext()
+	}
+	return m.limitOrders.PopNext()
+}
+
+func (m *MarketLimitNodeMap) Load(orders []order.StockOrderInterface) {
+	limitOrders := make([]order.StockOrderInterface, 0, len(orders))
+	for _, stockOrder := range orders {
+		if stockOrder.GetOrderType() == order.OrderTypeMarket {
+			m.marketOrders.Push(stockOrder)
+		} else {
+			limitOrders = append(limitOrders, stockOrder)
+		}
+	}
+	if loader, ok := m.limitOrders.(BulkLoadInterface); ok {
+		loader.Load(limitOrders)
+		return
+	}
+	for _, stockOrder := range limitOrders {
+		m.limitOrders.Push(stockOrder)
+	}
+}
+
+// Market orders are not keyed by price, so we check the market queue first.
+func (m *MarketLimitNodeMap) Remove(params *RemoveParams) order.StockOrderInterface {
+	if removed := m.marketOrders.Remove(params); removed != nil {
+		return removed
+	}
+	return m.limitOrders.Remove(params)
+}
+
+func (m *MarketLimitNodeMap) Find(params *RemoveParams) order.StockOrderInterface {
+	if found := m.marketOrders.Find(params); found != nil {
+		return found
+	}
+	return m.limitOrders.Find(params)
+}
+
+func (m *MarketLimitNodeMap) Length() int {
+	return m.marketOrders.Length() + m.limitOrders.Length()
+}
+
+// Returns the best limit price. Market orders do not carry a price.
+func (m *MarketLimitNodeMap) GetPrice() money.Money {
+	return m.limitOrders.GetPrice()
+}
+
+func (m *MarketLimitNodeMap) Orders() []order.StockOrderInterface {
+	return append(m.marketOrders.Orders(), m.limitOrders.Orders()...)
+}
+
+// The best limit price level. Market orders have no price, so they are never part of it.
+func (m *MarketLimitNodeMap) BestLevel() []order.StockOrderInterface {
+	return m.limitOrders.BestLevel()
+}
+
+// Only the limit orders have a price level. Market orders are left out.
+func (m *MarketLimitNodeMap) GetPriceLevels(depth int) []PriceLevel {
+	if levels, ok := m.limitOrders.(PriceLevelsInterface); ok {
+		return levels.GetPriceLevels(depth)
+	}
+	return []PriceLevel{}
+}
+
+type NewMarketLimitNodeMapParams struct {
+	*NewOrderBookDataStructureParams
+	LimitOrders OrderBookDataStructureInterface // leave nil for ascending price levels
+}
+
+func NewMarketLimitNodeMap(params *NewMarketLimitNodeMapParams) OrderBookDataStructureInterface {
+	if params.LimitOrders == nil {
+		params.LimitOrders = NewPriceLevels(false)
+	}
+	return &MarketLimitNodeMap{
+		BaseOrderBookDataStructureInterface: NewBaseOrderBookDataStructure(params.NewOrderBookDataStructureParams),
+		marketOrders: NewQueue(&NewQueueParams{
+			NewOrderBookDataStructureParams: params.NewOrderBookDataStructureParams,
+		}),
+		limitOrders: params.LimitOrders,
+	}
 }
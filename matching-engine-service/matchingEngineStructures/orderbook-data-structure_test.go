@@ -0,0 +1,137 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"fmt"
+	"testing"
+)
+
+func newTestOrder(id int, price money.Money) order.StockOrderInterface {
+	return order.New(order.NewStockOrderParams{
+		NewEntityParams: entity.NewEntityParams{ID: fmt.Sprint(id)},
+		OrderType:       order.OrderTypeLimit,
+		Quantity:        1,
+		Price:           price,
+	})
+}
+
+func TestPriceLevelStructuresPopInPriceOrder(t *testing.T) {
+	prices := []money.Money{1200, 1000, 1500, 1000, 1100}
+	structures := map[string]func(descending bool) OrderBookDataStructureInterface{
+		"map": func(descending bool) OrderBookDataStructureInterface {
+			return NewPriceNodeMap(&NewPriceNodeMapParams{NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{}, Descending: descending})
+		},
+		"heap": func(descending bool) OrderBookDataStructureInterface {
+			return NewPriceLevelHeap(&NewPriceLevelHeapParams{NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{}, Descending: descending})
+		},
+	}
+	for name, build := range structures {
+		asks := build(false)
+		bids := build(true)
+		for i, price := range prices {
+			asks.Push(newTestOrder(i, price))
+			bids.Push(newTestOrder(i, price))
+		}
+		levels := asks.(PriceLevelsInterface).GetPriceLevels(2)
+		if len(levels) != 2 || levels[0].Price != 1000 || levels[0].OrderCount != 2 || levels[1].Price != 1100 {
+			t.Errorf("%s: unexpected ask levels %v", name, levels)
+		}
+		// ids 1 and 3 share the best ask, and keep time priority
+		wantAsks := []string{"1", "3", "4", "0", "2"}
+		wantBids := []string{"2", "0", "4", "1", "3"}
+		if got := orderIDs(asks.Orders()); fmt.Sprint(got) != fmt.Sprint(wantAsks) {
+			t.Errorf("%s: asks listed as %v, wanted %v", name, got, wantAsks)
+		}
+		if got := orderIDs(bids.Orders()); fmt.Sprint(got) != fmt.Sprint(wantBids) {
+			t.Errorf("%s: bids listed as %v, wanted %v", name, got, wantBids)
+		}
+		for i := range prices {
+			if got := asks.PopNext().GetId(); got != wantAsks[i] {
+				t.Errorf("%s: ask %d was %s, wanted %s", name, i, got, wantAsks[i])
+			}
+			if got := bids.PopNext().GetId(); got != wantBids[i] {
+				t.Errorf("%s: bid %d was %s, wanted %s", name, i, got, wantBids[i])
+			}
+		}
+		if asks.PopNext() != nil || asks.GetPrice() != 0 {
+			t.Errorf("%s: expected an empty book", name)
+		}
+	}
+}
+
+func orderIDs(orders []order.StockOrderInterface) []string {
+	ids := []string{}
+	for _, stockOrder := range orders {
+		ids = append(ids, stockOrder.GetId())
+	}
+	return ids
+}
+
+func TestPriceLevelHeapListsLevelsBestFirstWithoutChangingIt(t *testing.T) {
+	bids := NewPriceLevelHeap(&NewPriceLevelHeapParams{NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{}, Descending: true})
+	for i := 0; i < 200; i++ {
+		bids.Push(newTestOrder(i, money.Money((i*7919)%97)+1))
+	}
+	levels := bids.(PriceLevelsInterface).GetPriceLevels(0)
+	if len(levels) != 97 {
+		t.Fatalf("got %d levels, wanted 97", len(levels))
+	}
+	for i, level := range levels {
+		if level.Price != money.Money(97-i) {
+			t.Fatalf("level %d is at %s, wanted %s", i, level.Price, money.Money(97-i))
+		}
+	}
+	listed := orderIDs(bids.Orders())
+	for i, id := range listed {
+		if got := bids.PopNext().GetId(); got != id {
+			t.Fatalf("order %d was listed as %s but popped as %s", i, id, got)
+		}
+	}
+}
+
+// go test -bench PriceLevels ./matchingEngineStructures
+func benchmarkPriceLevels(b *testing.B, build func() OrderBookDataStructureInterface, levels int) {
+	orders := make([]order.StockOrderInterface, levels)
+	for i := range orders {
+		orders[i] = newTestOrder(i, money.Money((i*7919)%levels)+1)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		data := build()
+		for _, o := range orders {
+			data.Push(o)
+		}
+		for data.PopNext() != nil {
+		}
+	}
+}
+
+func BenchmarkPriceLevelsMap(b *testing.B) {
+	benchmarkPriceLevels(b, func() OrderBookDataStructureInterface {
+		return NewPriceNodeMap(&NewPriceNodeMapParams{NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{}})
+	}, 5000)
+}
+
+func BenchmarkPriceLevelsHeap(b *testing.B) {
+	benchmarkPriceLevels(b, func() OrderBookDataStructureInterface {
+		return NewPriceLevelHeap(&NewPriceLevelHeapParams{NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{}})
+	}, 5000)
+}
+
+func TestPriceLevelsOnlyShowIcebergSlice(t *testing.T) {
+	asks := NewPriceLevels(false)
+	asks.Push(newTestOrder(1, 1000))
+	asks.Push(order.New(order.NewStockOrderParams{
+		NewEntityParams: entity.NewEntityParams{ID: "iceberg"},
+		OrderType:       order.OrderTypeLimit,
+		Quantity:        500,
+		DisplayQuantity: 20,
+		Price:           1000,
+	}))
+	levels := asks.(PriceLevelsInterface).GetPriceLevels(1)
+	if len(levels) != 1 || levels[0].Quantity != 21 || levels[0].OrderCount != 2 {
+		t.Errorf("expected the hidden reserve to be left out of the level, got %v", levels)
+	}
+}
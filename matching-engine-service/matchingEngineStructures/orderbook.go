@@ -2,7 +2,9 @@ package matchingEngineStructures
 
 //Methods partially implmeneted using Copilot and ChatGPT 03-mini (preview)
 import (
+	"Shared/entities/money"
 	"Shared/entities/order"
+	"Shared/entities/stock"
 	"sort"
 	"sync"
 )
@@ -13,14 +15,33 @@ type OrderBookInterface interface {
 	CompleteBestOrderExtraction()
 	AddOrder(stockOrder order.StockOrderInterface)
 	ReturnOrder(stockOrder order.StockOrderInterface)
+	RemoveOrder(params *RemoveParams) order.StockOrderInterface
 	GetMutex() *sync.Mutex
 	GetData() OrderBookDataStructureInterface
-	GetBestPrice() float64
+	GetBestPrice() money.Money
+	GetPriceLevels(depth int) []PriceLevel
+	GetOrders() []order.StockOrderInterface
+	ReduceOrder(params *RemoveParams, quantity int) order.StockOrderInterface
+	FindOrder(params *RemoveParams) order.StockOrderInterface
+	GetBestLevel() []order.StockOrderInterface
+	GetAllocationPolicy() string
 }
 
 type OrderBook struct {
-	data  OrderBookDataStructureInterface
-	mutex *sync.Mutex
+	data             OrderBookDataStructureInterface
+	allocationPolicy string
+	mutex            *sync.Mutex
+}
+
+func (o *OrderBook) GetAllocationPolicy() string {
+	return o.allocationPolicy
+}
+
+// A snapshot of the limit orders at the best price, oldest first.
+func (o *OrderBook) GetBestLevel() []order.StockOrderInterface {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.data.BestLevel()
 }
 
 func (o *OrderBook) GetData() OrderBookDataStructureInterface {
@@ -31,10 +52,45 @@ func (o *OrderBook) GetMutex() *sync.Mutex {
 	return o.mutex
 }
 
-func (o *OrderBook) GetBestPrice() float64 {
+func (o *OrderBook) GetBestPrice() money.Money {
 	return o.GetData().GetPrice()
 }
 
+// Aggregated price levels, best price first, read under the book's mutex so a level is never half updated.
+func (o *OrderBook) GetPriceLevels(depth int) []PriceLevel {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if levels, ok := o.data.(PriceLevelsInterface); ok {
+		return levels.GetPriceLevels(depth)
+	}
+	return []PriceLevel{}
+}
+
+// A snapshot of every order in the book, in the order they would be matched.
+func (o *OrderBook) GetOrders() []order.StockOrderInterface {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.data.Orders()
+}
+
+func (o *OrderBook) FindOrder(params *RemoveParams) order.StockOrderInterface {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.data.Find(params)
+}
+
+// Lowers the quantity of a resting order without moving it, so it keeps its place in the queue.
+// Returns nil if the order isn't in the book.
+func (o *OrderBook) ReduceOrder(params *RemoveParams, quantity int) order.StockOrderInterface {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	found := o.data.Find(params)
+	if found != nil {
+		found.SetQuantity(quantity)
+	}
+	return found
+}
+
 // potential race condition here. if we need to actually put the order back due to complications, while it was extracted, other orders could have been extracted.
 // so current half solution is to only unlock after the order is extracted and we are sure we are done with it.
 func (o *OrderBook) GetBestOrder() order.StockOrderInterface {
@@ -62,15 +118,30 @@ func (o *OrderBook) ReturnOrder(stockOrder order.StockOrderInterface) {
 	o.data.PushFront(stockOrder)
 }
 
+func (o *OrderBook) RemoveOrder(params *RemoveParams) order.StockOrderInterface {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.data.Remove(params)
+}
+
 type NewOrderBookParams struct {
-	dataStructure OrderBookDataStructureInterface
-	InitalOrders  *[]order.StockOrderInterface
+	dataStructure    OrderBookDataStructureInterface
+	InitalOrders     *[]order.StockOrderInterface // in the order they should be matched
+	AllocationPolicy string                       // how a price level is shared out between its orders. leave empty for FIFO
 }
 
 func NewOrderBook(params *NewOrderBookParams) OrderBookInterface {
+	if params.AllocationPolicy == "" {
+		params.AllocationPolicy = stock.AllocationFIFO
+	}
 	ob := &OrderBook{
-		data:  params.dataStructure,
-		mutex: &sync.Mutex{},
+		data:             params.dataStructure,
+		allocationPolicy: params.AllocationPolicy,
+		mutex:            &sync.Mutex{},
+	}
+	if loader, ok := params.dataStructure.(BulkLoadInterface); ok {
+		loader.Load(*params.InitalOrders)
+		return ob
 	}
 	for _, order := range *params.InitalOrders {
 		ob.AddOrder(order)
@@ -96,36 +167,37 @@ func NewBuyOrderBook(params *NewBuyOrderBookParams) BuyOrderBookInterface {
 	}
 }
 
-func DefaultBuyOrderBook(initalOrders *[]order.StockOrderInterface) BuyOrderBookInterface {
-	//sort initial orders by date created, Oldest to newest
-	sort.SliceStable((*initalOrders), func(i, j int) bool {
-		return (*initalOrders)[i].GetDateCreated().Before((*initalOrders)[j].GetDateCreated())
-	})
+// Market buys are matched first, then limit buys from the highest bid down.
+// The initial orders must already be in time priority, see SortByTimePriority.
+func DefaultBuyOrderBook(initalOrders *[]order.StockOrderInterface, allocationPolicy string) BuyOrderBookInterface {
 	return NewBuyOrderBook(&NewBuyOrderBookParams{
 		&NewOrderBookParams{
-			dataStructure: NewQueue(&NewQueueParams{
-				&NewOrderBookDataStructureParams{},
+			dataStructure: NewMarketLimitNodeMap(&NewMarketLimitNodeMapParams{
+				NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{},
+				LimitOrders:                     NewPriceLevels(true),
 			}),
-			InitalOrders: initalOrders,
+			InitalOrders:     initalOrders,
+			AllocationPolicy: allocationPolicy,
 		},
 	})
 }
 
+// sort initial orders by date created, Oldest to newest, so each price level keeps time priority.
+// Orders from a snapshot are already in the exact order they were queued in, and shouldn't be sorted.
+func SortByTimePriority(initalOrders *[]order.StockOrderInterface) {
+	sort.SliceStable((*initalOrders), func(i, j int) bool {
+		return (*initalOrders)[i].GetTimePriority().Before((*initalOrders)[j].GetTimePriority())
+	})
+}
+
 type SellOrderBookInterface interface {
 	OrderBookInterface
-	RemoveOrder(params *RemoveParams) order.StockOrderInterface
 }
 
 type SellOrderBook struct {
 	OrderBookInterface
 }
 
-func (s *SellOrderBook) RemoveOrder(params *RemoveParams) order.StockOrderInterface {
-	s.GetMutex().Lock()
-	defer s.GetMutex().Unlock()
-	return s.GetData().Remove(params)
-}
-
 type NewSellOrderBookParams struct {
 	*NewOrderBookParams // Leave empty for default
 }
@@ -136,13 +208,16 @@ func NewSellOrderBook(params *NewSellOrderBookParams) SellOrderBookInterface {
 	}
 }
 
-func DefaultSellOrderBook(initalOrders *[]order.StockOrderInterface) SellOrderBookInterface {
+// Market sells are matched first, then limit sells from the lowest ask up.
+// The initial orders must already be in time priority, see SortByTimePriority.
+func DefaultSellOrderBook(initalOrders *[]order.StockOrderInterface, allocationPolicy string) SellOrderBookInterface {
 	return NewSellOrderBook(&NewSellOrderBookParams{
 		&NewOrderBookParams{
-			dataStructure: NewPriceNodeMap(&NewPriceNodeMapParams{
-				&NewOrderBookDataStructureParams{},
+			dataStructure: NewMarketLimitNodeMap(&NewMarketLimitNodeMapParams{
+				NewOrderBookDataStructureParams: &NewOrderBookDataStructureParams{},
 			}),
-			InitalOrders: initalOrders,
+			InitalOrders:     initalOrders,
+			AllocationPolicy: allocationPolicy,
 		},
 	})
 }
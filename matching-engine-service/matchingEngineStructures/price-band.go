@@ -0,0 +1,89 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"sync"
+	"time"
+)
+
+type PriceBandInterface interface {
+	Breached(price money.Money, now time.Time) bool
+	Record(price money.Money, now time.Time)
+	Reset()
+	GetReferencePrice() money.Money
+	GetPercent() float64
+	GetWindow() time.Duration
+}
+
+// PriceBand Structure, the circuit breaker band around a stock's reference price.
+// The reference is the first trade in each window, so a trade more than Percent away from it means the price moved too far too fast.
+type PriceBand struct {
+	percent        float64
+	window         time.Duration
+	referencePrice money.Money
+	referenceSetAt time.Time
+	mutex          *sync.Mutex
+}
+
+// A band with no reference yet, or whose window has run out, can't be breached. The trade becomes the next reference instead.
+func (b *PriceBand) Breached(price money.Money, now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.percent <= 0 || b.referencePrice == 0 || now.Sub(b.referenceSetAt) >= b.window {
+		return false
+	}
+	difference := price - b.referencePrice
+	if difference < 0 {
+		difference = -difference
+	}
+	return float64(difference)*100 > float64(b.referencePrice)*b.percent
+}
+
+// Call after every trade.
+func (b *PriceBand) Record(price money.Money, now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.referencePrice == 0 || now.Sub(b.referenceSetAt) >= b.window {
+		b.referencePrice = price
+		b.referenceSetAt = now
+	}
+}
+
+// Forgets the reference, the next trade starts a new window.
+func (b *PriceBand) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.referencePrice = 0
+}
+
+func (b *PriceBand) GetReferencePrice() money.Money {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.referencePrice
+}
+
+func (b *PriceBand) GetPercent() float64 {
+	return b.percent
+}
+
+func (b *PriceBand) GetWindow() time.Duration {
+	return b.window
+}
+
+type NewPriceBandParams struct {
+	Percent float64       // how far from the reference a trade may be, e.g. 10 for 10%. 0 turns the band off
+	Window  time.Duration // how long a reference price lasts. leave 0 for the default
+}
+
+const DefaultPriceBandWindow = 5 * time.Minute
+
+func NewPriceBand(params *NewPriceBandParams) PriceBandInterface {
+	if params.Window <= 0 {
+		params.Window = DefaultPriceBandWindow
+	}
+	return &PriceBand{
+		percent: params.Percent,
+		window:  params.Window,
+		mutex:   &sync.Mutex{},
+	}
+}
@@ -0,0 +1,29 @@
+package matchingEngineStructures
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceBandBreachesWithinWindow(t *testing.T) {
+	band := NewPriceBand(&NewPriceBandParams{Percent: 10, Window: time.Minute})
+	start := time.Now()
+	if band.Breached(5000, start) {
+		t.Errorf("a band without a reference can't be breached")
+	}
+	band.Record(1000, start)
+	if band.Breached(1100, start.Add(time.Second)) || band.Breached(900, start.Add(time.Second)) {
+		t.Errorf("a move to the edge of the band is not a breach")
+	}
+	if !band.Breached(1101, start.Add(time.Second)) || !band.Breached(899, start.Add(time.Second)) {
+		t.Errorf("expected a move past the band to breach")
+	}
+	// once the window is over the next trade becomes the reference
+	if band.Breached(2000, start.Add(time.Minute)) {
+		t.Errorf("expected the reference to have expired")
+	}
+	band.Record(2000, start.Add(time.Minute))
+	if band.GetReferencePrice() != 2000 {
+		t.Errorf("reference was %d", band.GetReferencePrice())
+	}
+}
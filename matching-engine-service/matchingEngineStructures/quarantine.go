@@ -0,0 +1,166 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Retries of a quarantined match start a second apart and double each time, up to a minute.
+// After QuarantineMaxAttempts it is left for an operator.
+const (
+	QuarantineRetryDelay    = time.Second
+	QuarantineMaxRetryDelay = time.Minute
+	QuarantineMaxAttempts   = 10
+)
+
+// A match the executor didn't answer. Its quantity is held here, out of the book, until the executor answers a retry or an operator releases it.
+// The orders are kept as they were sent, a child order for a side the match didn't finish, so the match can be sent again exactly.
+type QuarantinedMatch struct {
+	ID            string                    `json:"id"` // sent to the executor, which answers a match it has seen before without trading it again
+	BuyOrder      order.NewStockOrderParams `json:"buy_order"`
+	SellOrder     order.NewStockOrderParams `json:"sell_order"`
+	Quantity      int                       `json:"quantity"`
+	Price         money.Money               `json:"price"`
+	Attempts      int                       `json:"attempts"`
+	LastError     string                    `json:"last_error"`
+	QuarantinedAt time.Time                 `json:"quarantined_at"`
+	NextAttemptAt time.Time                 `json:"next_attempt_at"` // zero once it is waiting for an operator
+	Attempting    bool                      `json:"-"`               // a retry or release is under way, so nothing else may touch it
+}
+
+// Counts a failed attempt and works out when to try next.
+func (m *QuarantinedMatch) Failed(err string, now time.Time) {
+	m.Attempts++
+	m.LastError = err
+	if m.Attempts >= QuarantineMaxAttempts {
+		m.NextAttemptAt = time.Time{}
+		return
+	}
+	delay := QuarantineRetryDelay << (m.Attempts - 1)
+	if delay > QuarantineMaxRetryDelay || delay <= 0 {
+		delay = QuarantineMaxRetryDelay
+	}
+	m.NextAttemptAt = now.Add(delay)
+}
+
+type QuarantineInterface interface {
+	Add(match QuarantinedMatch)
+	Get(id string) (QuarantinedMatch, bool)
+	Remove(id string) (QuarantinedMatch, bool)
+	GetMatches() []QuarantinedMatch
+	Begin(id string) (QuarantinedMatch, bool)
+	Fail(id string, err string, now time.Time)
+	Retry(id string, now time.Time) bool
+	Due(now time.Time) []QuarantinedMatch
+}
+
+// Quarantine Structure, one stock's quarantined matches by ID.
+type Quarantine struct {
+	matches map[string]*QuarantinedMatch
+	mutex   *sync.Mutex
+}
+
+func (q *Quarantine) Add(match QuarantinedMatch) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.matches[match.ID] = &match
+}
+
+func (q *Quarantine) Get(id string) (QuarantinedMatch, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	match, ok := q.matches[id]
+	if !ok {
+		return QuarantinedMatch{}, false
+	}
+	return *match, true
+}
+
+func (q *Quarantine) Remove(id string) (QuarantinedMatch, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	match, ok := q.matches[id]
+	if !ok {
+		return QuarantinedMatch{}, false
+	}
+	delete(q.matches, id)
+	return *match, true
+}
+
+// Oldest first.
+func (q *Quarantine) GetMatches() []QuarantinedMatch {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	matches := make([]QuarantinedMatch, 0, len(q.matches))
+	for _, match := range q.matches {
+		matches = append(matches, *match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].QuarantinedAt.Equal(matches[j].QuarantinedAt) {
+			return matches[i].ID < matches[j].ID
+		}
+		return matches[i].QuarantinedAt.Before(matches[j].QuarantinedAt)
+	})
+	return matches
+}
+
+// Marks the match as being attempted. Returns false if it is gone or already being attempted.
+func (q *Quarantine) Begin(id string) (QuarantinedMatch, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	match, ok := q.matches[id]
+	if !ok || match.Attempting {
+		return QuarantinedMatch{}, false
+	}
+	match.Attempting = true
+	return *match, true
+}
+
+// Ends an attempt that didn't resolve the match.
+func (q *Quarantine) Fail(id string, err string, now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	match, ok := q.matches[id]
+	if !ok {
+		return
+	}
+	match.Attempting = false
+	match.Failed(err, now)
+}
+
+// Starts the retries over, from now.
+func (q *Quarantine) Retry(id string, now time.Time) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	match, ok := q.matches[id]
+	if !ok {
+		return false
+	}
+	match.Attempts = 0
+	match.NextAttemptAt = now
+	return true
+}
+
+// The matches whose next retry is due and that nothing is attempting, oldest first.
+func (q *Quarantine) Due(now time.Time) []QuarantinedMatch {
+	due := []QuarantinedMatch{}
+	for _, match := range q.GetMatches() {
+		if !match.Attempting && !match.NextAttemptAt.IsZero() && !match.NextAttemptAt.After(now) {
+			due = append(due, match)
+		}
+	}
+	return due
+}
+
+type NewQuarantineParams struct {
+}
+
+func NewQuarantine(params *NewQuarantineParams) QuarantineInterface {
+	return &Quarantine{
+		matches: map[string]*QuarantinedMatch{},
+		mutex:   &sync.Mutex{},
+	}
+}
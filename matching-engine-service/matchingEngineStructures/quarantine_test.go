@@ -0,0 +1,37 @@
+package matchingEngineStructures
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuarantineBacksOffUntilAnOperatorIsNeeded(t *testing.T) {
+	quarantine := NewQuarantine(&NewQuarantineParams{})
+	now := time.Now()
+	match := QuarantinedMatch{ID: "match", QuarantinedAt: now}
+	match.Failed("timeout", now)
+	quarantine.Add(match)
+	if len(quarantine.Due(now)) != 0 || len(quarantine.Due(now.Add(QuarantineRetryDelay))) != 1 {
+		t.Errorf("expected the first retry a second later")
+	}
+	if _, ok := quarantine.Begin("match"); !ok {
+		t.Fatal("expected to begin a retry")
+	}
+	if len(quarantine.Due(now.Add(time.Hour))) != 0 {
+		t.Errorf("a match being attempted shouldn't be due")
+	}
+	quarantine.Fail("match", "timeout", now)
+	if got, _ := quarantine.Get("match"); got.NextAttemptAt != now.Add(2*QuarantineRetryDelay) {
+		t.Errorf("expected the delay to double, next attempt at %v", got.NextAttemptAt.Sub(now))
+	}
+	for i := 2; i < QuarantineMaxAttempts; i++ {
+		quarantine.Fail("match", "timeout", now)
+	}
+	if got, _ := quarantine.Get("match"); !got.NextAttemptAt.IsZero() || len(quarantine.Due(now.Add(time.Hour))) != 0 {
+		t.Errorf("expected retries to stop after %d attempts, got %d", QuarantineMaxAttempts, got.Attempts)
+	}
+	quarantine.Retry("match", now)
+	if len(quarantine.Due(now)) != 1 {
+		t.Errorf("expected an operator retry to be due straight away")
+	}
+}
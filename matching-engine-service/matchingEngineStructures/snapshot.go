@@ -0,0 +1,99 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/order"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Everything needed to build a stock's engine again: how it is set up, and every order in its books.
+// The orders are kept in the order they would be matched, so loading them back needs no sorting and keeps exact queue positions.
+type BookState struct {
+	BuyOrders           []order.NewStockOrderParams `json:"buy_orders"`
+	SellOrders          []order.NewStockOrderParams `json:"sell_orders"`
+	StopOrders          []order.NewStockOrderParams `json:"stop_orders"`
+	Quarantined         []QuarantinedMatch          `json:"quarantined,omitempty"` // their orders are in none of the books
+	Halted              bool                        `json:"halted"`
+	HaltReason          string                      `json:"halt_reason,omitempty"`
+	InAuction           bool                        `json:"in_auction"`
+	AllocationPolicy    string                      `json:"allocation_policy"`
+	SelfTradePrevention string                      `json:"self_trade_prevention"`
+	PriceBandPercent    float64                     `json:"price_band_percent"`
+	PriceBandWindow     time.Duration               `json:"price_band_window"`
+}
+
+// Every order in the state, buys then sells then stops.
+func (s *BookState) Orders() []order.StockOrderInterface {
+	orders := make([]order.StockOrderInterface, 0, len(s.BuyOrders)+len(s.SellOrders)+len(s.StopOrders))
+	for _, side := range [][]order.NewStockOrderParams{s.BuyOrders, s.SellOrders, s.StopOrders} {
+		for _, orderParams := range side {
+			orders = append(orders, order.New(orderParams))
+		}
+	}
+	return orders
+}
+
+// A stock's book as of a journal sequence number. Only journal entries after Sequence need applying on top of it.
+type Snapshot struct {
+	StockID  string    `json:"stock_id"`
+	Sequence uint64    `json:"sequence"`
+	TakenAt  time.Time `json:"taken_at"`
+	BookState
+}
+
+func SnapshotPath(directory string, stockID string) string {
+	return filepath.Join(directory, stockID+".snapshot.gz")
+}
+
+// Gzipped JSON. Written to a temporary file and renamed over the last one, so a crash never leaves a half written snapshot behind.
+func WriteSnapshot(directory string, snapshot *Snapshot) error {
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return err
+	}
+	path := SnapshotPath(directory, snapshot.StockID)
+	file, err := os.CreateTemp(directory, snapshot.StockID+".snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	writer := gzip.NewWriter(file)
+	err = json.NewEncoder(writer).Encode(snapshot)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// The latest snapshot of the stock. Returns an os.IsNotExist error if it has never had one.
+func ReadSnapshot(directory string, stockID string) (*Snapshot, error) {
+	file, err := os.Open(SnapshotPath(directory, stockID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var snapshot Snapshot
+	err = json.NewDecoder(reader).Decode(&snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,44 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/order"
+	"testing"
+	"time"
+)
+
+func TestSnapshotLoadsInQueueOrder(t *testing.T) {
+	directory := t.TempDir()
+	now := time.Now()
+	snapshot := &Snapshot{StockID: "stock", Sequence: 7}
+	// a partly filled order returned to the front of its level is ahead of an older one
+	for _, id := range []string{"3", "1", "2"} {
+		snapshot.BuyOrders = append(snapshot.BuyOrders, order.NewStockOrderParams{
+			NewEntityParams: entity.NewEntityParams{ID: id, DateCreated: now.Add(-time.Duration(len(snapshot.BuyOrders)) * time.Second)},
+			IsBuy:           true,
+			OrderType:       order.OrderTypeLimit,
+			Quantity:        1,
+			Price:           100,
+		})
+	}
+	err := WriteSnapshot(directory, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSnapshot(directory, "stock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Sequence != 7 {
+		t.Errorf("expected sequence 7, got %d", read.Sequence)
+	}
+	orders := read.Orders()
+	book := DefaultBuyOrderBook(&orders, "")
+	ids := ""
+	for _, stockOrder := range book.GetOrders() {
+		ids += stockOrder.GetId()
+	}
+	if ids != "312" {
+		t.Errorf("expected the snapshot's queue order 312, got %s", ids)
+	}
+}
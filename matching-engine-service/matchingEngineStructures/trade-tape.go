@@ -0,0 +1,93 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"sync"
+	"time"
+)
+
+const (
+	AggressorBuy  = "BUY"
+	AggressorSell = "SELL"
+)
+
+// A single execution, as market data.
+type Trade struct {
+	Price         money.Money
+	Quantity      int
+	Timestamp     time.Time
+	AggressorSide string // BUY or SELL. The side of the order that arrived last and took liquidity.
+}
+
+type TradeTapeInterface interface {
+	Record(trade Trade)
+	GetRecentTrades(limit int) []Trade
+	GetLastTrade() (Trade, bool)
+	Adjust(adjust func(trade Trade) Trade)
+}
+
+// TradeTape Structure, a fixed size ring buffer of the most recent trades. Once full, the oldest trade is overwritten.
+type TradeTape struct {
+	trades []Trade
+	next   int // index the next trade is written to
+	count  int
+	mutex  *sync.Mutex
+}
+
+func (t *TradeTape) Record(trade Trade) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.trades[t.next] = trade
+	t.next = (t.next + 1) % len(t.trades)
+	if t.count < len(t.trades) {
+		t.count++
+	}
+}
+
+// Newest trade first. limit <= 0 returns everything held.
+func (t *TradeTape) GetRecentTrades(limit int) []Trade {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if limit <= 0 || limit > t.count {
+		limit = t.count
+	}
+	trades := make([]Trade, limit)
+	for i := 0; i < limit; i++ {
+		trades[i] = t.trades[(t.next-1-i+len(t.trades))%len(t.trades)]
+	}
+	return trades
+}
+
+func (t *TradeTape) GetLastTrade() (Trade, bool) {
+	trades := t.GetRecentTrades(1)
+	if len(trades) == 0 {
+		return Trade{}, false
+	}
+	return trades[0], true
+}
+
+// Rewrites every trade held, like a split does so the prices before it compare with those after.
+func (t *TradeTape) Adjust(adjust func(trade Trade) Trade) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i := 0; i < t.count; i++ {
+		index := (t.next - 1 - i + len(t.trades)) % len(t.trades)
+		t.trades[index] = adjust(t.trades[index])
+	}
+}
+
+type NewTradeTapeParams struct {
+	Capacity int // leave 0 for the default
+}
+
+const DefaultTradeTapeCapacity = 200
+
+func NewTradeTape(params *NewTradeTapeParams) TradeTapeInterface {
+	if params.Capacity <= 0 {
+		params.Capacity = DefaultTradeTapeCapacity
+	}
+	return &TradeTape{
+		trades: make([]Trade, params.Capacity),
+		mutex:  &sync.Mutex{},
+	}
+}
@@ -0,0 +1,34 @@
+package matchingEngineStructures
+
+import "testing"
+
+func TestTradeTapeKeepsNewestTrades(t *testing.T) {
+	tape := NewTradeTape(&NewTradeTapeParams{Capacity: 3})
+	if _, ok := tape.GetLastTrade(); ok {
+		t.Errorf("expected an empty tape")
+	}
+	for quantity := 1; quantity <= 5; quantity++ {
+		tape.Record(Trade{Quantity: quantity})
+	}
+	trades := tape.GetRecentTrades(0)
+	if len(trades) != 3 || trades[0].Quantity != 5 || trades[2].Quantity != 3 {
+		t.Errorf("unexpected trades %v", trades)
+	}
+	if last, _ := tape.GetLastTrade(); last.Quantity != 5 {
+		t.Errorf("last trade was %v", last)
+	}
+}
+
+func TestTradeTapeAdjust(t *testing.T) {
+	tape := NewTradeTape(&NewTradeTapeParams{Capacity: 3})
+	tape.Record(Trade{Quantity: 1})
+	tape.Record(Trade{Quantity: 2})
+	tape.Adjust(func(trade Trade) Trade {
+		trade.Quantity *= 10
+		return trade
+	})
+	trades := tape.GetRecentTrades(0)
+	if len(trades) != 2 || trades[0].Quantity != 20 || trades[1].Quantity != 10 {
+		t.Errorf("unexpected trades %v", trades)
+	}
+}
@@ -0,0 +1,113 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"sort"
+	"sync"
+)
+
+type TriggerBookInterface interface {
+	AddOrder(stockOrder order.StockOrderInterface)
+	RemoveOrder(orderID string) order.StockOrderInterface
+	PopTriggered(lastPrice money.Money) []order.StockOrderInterface
+	GetOrders() []order.StockOrderInterface
+}
+
+// TriggerBook Structure, holds stop orders until the last trade price reaches their stop price.
+// Buy stops trigger when the price rises to the stop, sell stops when it falls to it.
+// Each side is kept in trigger order: nearest stop first, then oldest, then by ID, so the same trades always release the same orders in the same order.
+type TriggerBook struct {
+	buyStops  []order.StockOrderInterface // lowest stop price first
+	sellStops []order.StockOrderInterface // highest stop price first
+	mutex     *sync.Mutex
+}
+
+func triggersBefore(a order.StockOrderInterface, b order.StockOrderInterface) bool {
+	if a.GetStopPrice() != b.GetStopPrice() {
+		if a.GetIsBuy() {
+			return a.GetStopPrice() < b.GetStopPrice()
+		}
+		return a.GetStopPrice() > b.GetStopPrice()
+	}
+	if !a.GetDateCreated().Equal(b.GetDateCreated()) {
+		return a.GetDateCreated().Before(b.GetDateCreated())
+	}
+	return a.GetId() < b.GetId()
+}
+
+func insertStop(stops []order.StockOrderInterface, stockOrder order.StockOrderInterface) []order.StockOrderInterface {
+	i := sort.Search(len(stops), func(i int) bool {
+		return triggersBefore(stockOrder, stops[i])
+	})
+	stops = append(stops, nil)
+	copy(stops[i+1:], stops[i:])
+	stops[i] = stockOrder
+	return stops
+}
+
+func (t *TriggerBook) AddOrder(stockOrder order.StockOrderInterface) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if stockOrder.GetIsBuy() {
+		t.buyStops = insertStop(t.buyStops, stockOrder)
+	} else {
+		t.sellStops = insertStop(t.sellStops, stockOrder)
+	}
+}
+
+func (t *TriggerBook) RemoveOrder(orderID string) order.StockOrderInterface {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, stops := range []*[]order.StockOrderInterface{&t.buyStops, &t.sellStops} {
+		for i, stockOrder := range *stops {
+			if stockOrder.GetId() == orderID {
+				*stops = append((*stops)[:i], (*stops)[i+1:]...)
+				return stockOrder
+			}
+		}
+	}
+	return nil
+}
+
+// Removes and returns every stop the last trade price has reached, in trigger order. Buys come before sells.
+func (t *TriggerBook) PopTriggered(lastPrice money.Money) []order.StockOrderInterface {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	buys := sort.Search(len(t.buyStops), func(i int) bool {
+		return t.buyStops[i].GetStopPrice() > lastPrice
+	})
+	sells := sort.Search(len(t.sellStops), func(i int) bool {
+		return t.sellStops[i].GetStopPrice() < lastPrice
+	})
+	triggered := make([]order.StockOrderInterface, 0, buys+sells)
+	triggered = append(triggered, t.buyStops[:buys]...)
+	triggered = append(triggered, t.sellStops[:sells]...)
+	t.buyStops = t.buyStops[buys:]
+	t.sellStops = t.sellStops[sells:]
+	return triggered
+}
+
+func (t *TriggerBook) GetOrders() []order.StockOrderInterface {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	orders := make([]order.StockOrderInterface, 0, len(t.buyStops)+len(t.sellStops))
+	orders = append(orders, t.buyStops...)
+	return append(orders, t.sellStops...)
+}
+
+type NewTriggerBookParams struct {
+	InitalOrders *[]order.StockOrderInterface // untriggered stop orders. leave nil for an empty book
+}
+
+func NewTriggerBook(params *NewTriggerBookParams) TriggerBookInterface {
+	t := &TriggerBook{
+		mutex: &sync.Mutex{},
+	}
+	if params.InitalOrders != nil {
+		for _, stockOrder := range *params.InitalOrders {
+			t.AddOrder(stockOrder)
+		}
+	}
+	return t
+}
@@ -0,0 +1,41 @@
+package matchingEngineStructures
+
+import (
+	"Shared/entities/entity"
+	"Shared/entities/money"
+	"Shared/entities/order"
+	"testing"
+)
+
+func newTestStop(id string, isBuy bool, stopPrice money.Money) order.StockOrderInterface {
+	return order.New(order.NewStockOrderParams{
+		NewEntityParams: entity.NewEntityParams{ID: id},
+		OrderType:       order.OrderTypeStop,
+		IsBuy:           isBuy,
+		Quantity:        1,
+		StopPrice:       stopPrice,
+	})
+}
+
+func TestTriggerBookReleasesReachedStopsInOrder(t *testing.T) {
+	book := NewTriggerBook(&NewTriggerBookParams{})
+	book.AddOrder(newTestStop("buy-12", true, 1200))
+	book.AddOrder(newTestStop("buy-10b", true, 1000))
+	book.AddOrder(newTestStop("buy-10a", true, 1000))
+	book.AddOrder(newTestStop("sell-9", false, 900))
+	book.AddOrder(newTestStop("sell-11", false, 1100))
+
+	want := []string{"buy-10a", "buy-10b", "sell-11"}
+	triggered := book.PopTriggered(1100)
+	if len(triggered) != len(want) {
+		t.Fatalf("triggered %d orders, wanted %d", len(triggered), len(want))
+	}
+	for i, stockOrder := range triggered {
+		if stockOrder.GetId() != want[i] {
+			t.Errorf("order %d was %s, wanted %s", i, stockOrder.GetId(), want[i])
+		}
+	}
+	if book.RemoveOrder("sell-9") == nil || len(book.GetOrders()) != 1 {
+		t.Errorf("expected only buy-12 to be left")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/order"
 	"Shared/entities/stock"
 	userStock "Shared/entities/user-stock"
@@ -37,7 +38,7 @@ func main() {
 			DateModified: time.Now(),
 		},
 		UserID:  "6fd2fc6b-9142-4777-8b30-575ff6fa2460",
-		Balance: 100000,
+		Balance: money.FromCents(10000000),
 	}))
 	if err != nil {
 		println("Error creating wallet: ", err)
@@ -124,7 +125,7 @@ func main() {
 		},
 		StockID:   newStock1.GetId(),
 		Quantity:  4,
-		Price:     7.5,
+		Price:     money.FromCents(750),
 		OrderType: "LIMIT",
 		IsBuy:     false,
 	})
@@ -0,0 +1,60 @@
+package orderExecutorService
+
+import (
+	"Shared/entities/transaction"
+	"Shared/network"
+	"fmt"
+)
+
+// The match's record in the transaction database, nil if it has never been sent.
+func storedExecution(matchID string) (transaction.MatchExecutionInterface, error) {
+	executions, err := _databaseAccessTransact.MatchExecution().GetByForeignID("match_id", matchID)
+	if err != nil {
+		return nil, err
+	}
+	if len(*executions) == 0 {
+		return nil, nil
+	}
+	return (*executions)[0], nil
+}
+
+// Records that the match is running before it touches anything, so a second executor or a retry can't run it alongside.
+// Only one record can have the match ID, so if two race to start it one of them fails here.
+func beginExecution(orderData network.MatchingEngineToExecutionJSON) (transaction.MatchExecutionInterface, error) {
+	return _databaseAccessTransact.MatchExecution().Create(transaction.NewMatchExecution(transaction.NewMatchExecutionParams{
+		MatchID: orderData.MatchID,
+		StockID: orderData.StockID,
+		Status:  transaction.MatchExecutionRunning,
+	}))
+}
+
+// A match that errored is forgotten, so sending it again runs it again.
+func finishExecution(execution transaction.MatchExecutionInterface, result network.ExecutorToMatchingEngineJSON, err error) {
+	if err != nil {
+		deleteErr := _databaseAccessTransact.MatchExecution().Delete(execution.GetId())
+		if deleteErr != nil {
+			println("Error forgetting failed match ", execution.GetMatchID(), ": ", deleteErr.Error())
+		}
+		return
+	}
+	execution.SetStatus(transaction.MatchExecutionCompleted)
+	execution.SetIsBuyFailure(result.IsBuyFailure)
+	execution.SetIsSellFailure(result.IsSellFailure)
+	updateErr := _databaseAccessTransact.MatchExecution().Update(execution)
+	if updateErr != nil {
+		// the trade is done. A retry finds the match still running and is refused, so it can't trade again
+		println("Error recording the answer to match ", execution.GetMatchID(), ": ", updateErr.Error())
+	}
+}
+
+// What a match that already ran answered. One still running, or left running by an executor that stopped part way
+// through it, is an error: the engine asks again later, and quarantines it for an operator if it never finishes.
+func storedResult(execution transaction.MatchExecutionInterface) (network.ExecutorToMatchingEngineJSON, error) {
+	if execution.GetStatus() != transaction.MatchExecutionCompleted {
+		return network.ExecutorToMatchingEngineJSON{}, fmt.Errorf("match %s is already running", execution.GetMatchID())
+	}
+	return network.ExecutorToMatchingEngineJSON{
+		IsBuyFailure:  execution.GetIsBuyFailure(),
+		IsSellFailure: execution.GetIsSellFailure(),
+	}, nil
+}
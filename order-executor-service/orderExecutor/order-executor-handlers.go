@@ -22,6 +22,7 @@ func InitalizeExecutorHandlers(
 	_databaseAccessUser = databaseAccessUser
 
 	networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "executor", Handler: executorHandler})
+	networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "executeBatch", Handler: executeBatchHandler})
 
 	http.HandleFunc("/health", healthHandler)
 }
@@ -39,21 +40,11 @@ func executorHandler(responseWriter network.ResponseWriter, data []byte, queryPa
 		return
 	}
 
-	// Process the orderData (transferEntity) from the Matching Engine
-	buySuccess, sellSuccess, err := ProcessTrade(orderData, _databaseAccessTransact, _databaseAccessUser)
+	responseEntity, err := executeOnce(orderData)
 	if err != nil {
 		responseWriter.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	println(fmt.Sprintf("Done ProcessTrade - buySuccess: %t, sellSuccess: %t", buySuccess, sellSuccess))
-	// Independent failure flags //
-	// If the match was successful, both IsBuyFailure and IsSellFailure will be false
-	// If the match was unsuccessful, only one of IsBuyFailure and IsSellFailure will be true
-	// If there was an error, both IsBuyFailure and IsSellFailure will be true
-	responseEntity := network.ExecutorToMatchingEngineJSON{
-		IsBuyFailure:  !buySuccess,
-		IsSellFailure: !sellSuccess,
-	}
 
 	println(fmt.Sprintf("IsBuyFailure: %t, IsSellFailure: %t", responseEntity.IsBuyFailure, responseEntity.IsSellFailure))
 	jsonResponseToMatchingEngine, err := json.Marshal(responseEntity)
@@ -67,3 +58,81 @@ func executorHandler(responseWriter network.ResponseWriter, data []byte, queryPa
 	responseWriter.Write(jsonResponseToMatchingEngine)
 
 }
+
+// Expects a list of network.MatchingEngineToExecutionJSON for one stock, in the order they were matched, and answers with a
+// network.MatchExecutionResult for each. They run one after another. Once one errors the rest aren't run, so nothing matched
+// after it trades ahead of it. The engine sends those again.
+func executeBatchHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	var batch []network.MatchingEngineToExecutionJSON
+	err := json.Unmarshal(data, &batch)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	println(fmt.Sprintf("Executing batch of %d matches", len(batch)))
+
+	results := make([]network.MatchExecutionResult, len(batch))
+	var failed error
+	for i, orderData := range batch {
+		results[i].MatchID = orderData.MatchID
+		if failed != nil {
+			results[i].Error = fmt.Sprintf("not run, an earlier match failed: %v", failed)
+			continue
+		}
+		responseEntity, err := executeOnce(orderData)
+		if err != nil {
+			println("Error: ", err.Error())
+			failed = err
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].ExecutorToMatchingEngineJSON = responseEntity
+	}
+
+	jsonResponseToMatchingEngine, err := json.Marshal(results)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(jsonResponseToMatchingEngine)
+}
+
+// A match the engine sends again, because it timed out waiting, gets the answer stored when it first ran rather than trading again.
+// Matches without an ID are run every time.
+func executeOnce(orderData network.MatchingEngineToExecutionJSON) (network.ExecutorToMatchingEngineJSON, error) {
+	if orderData.MatchID == "" {
+		return executeTrade(orderData)
+	}
+	execution, err := storedExecution(orderData.MatchID)
+	if err != nil {
+		return network.ExecutorToMatchingEngineJSON{}, err
+	}
+	if execution != nil {
+		println("Match already sent: ", orderData.MatchID, ". Answering with its stored result")
+		return storedResult(execution)
+	}
+	execution, err = beginExecution(orderData)
+	if err != nil {
+		return network.ExecutorToMatchingEngineJSON{}, fmt.Errorf("couldn't start match %s: %v", orderData.MatchID, err)
+	}
+	result, err := executeTrade(orderData)
+	finishExecution(execution, result, err)
+	return result, err
+}
+
+func executeTrade(orderData network.MatchingEngineToExecutionJSON) (network.ExecutorToMatchingEngineJSON, error) {
+	// Process the orderData (transferEntity) from the Matching Engine
+	buySuccess, sellSuccess, err := ProcessTrade(orderData, _databaseAccessTransact, _databaseAccessUser)
+	if err != nil {
+		return network.ExecutorToMatchingEngineJSON{}, err
+	}
+	println(fmt.Sprintf("Done ProcessTrade - buySuccess: %t, sellSuccess: %t", buySuccess, sellSuccess))
+	// Independent failure flags //
+	// If the match was successful, both IsBuyFailure and IsSellFailure will be false
+	// If the match was unsuccessful, only one of IsBuyFailure and IsSellFailure will be true
+	// If there was an error, both IsBuyFailure and IsSellFailure will be true
+	return network.ExecutorToMatchingEngineJSON{
+		IsBuyFailure:  !buySuccess,
+		IsSellFailure: !sellSuccess,
+	}, nil
+}
@@ -2,6 +2,7 @@ package orderExecutorService
 
 import (
 	//"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/transaction"
 	//"Shared/entities/user-stock"
 	"Shared/entities/wallet"
@@ -34,9 +35,9 @@ func ProcessTrade(orderData network.MatchingEngineToExecutionJSON, databaseAcces
 	Sell Order ID: %s
 	Is Buy Partial: %t
 	Is Sell Partial: %t
-	Stock Price: %.2f
+	Stock Price: %s
 	Quantity: %d
-	Total Cost: %.2f`,
+	Total Cost: %s`,
 		buyerID,
 		sellerID,
 		stockID,
@@ -143,19 +144,19 @@ func ProcessTrade(orderData network.MatchingEngineToExecutionJSON, databaseAcces
 func updateUserWallets(
 	buyerWallet wallet.WalletInterface,
 	sellerWallet wallet.WalletInterface,
-	totalCost float64,
+	totalCost money.Money,
 	stockTransaction transaction.StockTransactionInterface,
 	databaseAccessUser databaseAccessUserManagement.DatabaseAccessInterface,
 	databaseAccessTransact databaseAccessTransaction.DatabaseAccessInterface,
 ) error {
 
-	println(fmt.Sprintf("Initial balances - Buyer: %.2f, Seller: %.2f", buyerWallet.GetBalance(), sellerWallet.GetBalance()))
+	println(fmt.Sprintf("Initial balances - Buyer: %s, Seller: %s", buyerWallet.GetBalance(), sellerWallet.GetBalance()))
 
 	// Step 1: Update buyer's wallet (debit)
 	if err := updateWalletBalance(buyerWallet, totalCost, true, databaseAccessUser); err != nil {
 		return fmt.Errorf("buyer wallet update failed: %v", err)
 	}
-	println(fmt.Sprintf("Buyer wallet updated - New balance: %.2f (deducted %.2f)",
+	println(fmt.Sprintf("Buyer wallet updated - New balance: %s (deducted %s)",
 		buyerWallet.GetBalance(), totalCost))
 
 	// Step 2: Update seller's wallet (credit)
@@ -164,7 +165,7 @@ func updateUserWallets(
 		updateWalletBalance(buyerWallet, totalCost, false, databaseAccessUser)
 		return fmt.Errorf("seller wallet update failed: %v", err)
 	}
-	println(fmt.Sprintf("Seller wallet updated - New balance: %.2f (added %.2f)",
+	println(fmt.Sprintf("Seller wallet updated - New balance: %s (added %s)",
 		sellerWallet.GetBalance(), totalCost))
 
 	// Step 3: Create wallet transactions for buyer
@@ -172,7 +173,7 @@ func updateUserWallets(
 	if err != nil {
 		return fmt.Errorf("buyer wallet transaction failed: %v", err)
 	}
-	println(fmt.Sprintf("Created wallet transaction for buyer (ID: %s, UserID: %s) - Amount: %.2f (debit)",
+	println(fmt.Sprintf("Created wallet transaction for buyer (ID: %s, UserID: %s) - Amount: %s (debit)",
 		buyerWalletTxID, buyerWallet.GetUserID(), totalCost))
 
 	// Step 4: Create wallet transactions for seller
@@ -180,11 +181,11 @@ func updateUserWallets(
 	if err != nil {
 		return fmt.Errorf("seller wallet transaction failed: %v", err)
 	}
-	println(fmt.Sprintf("Created wallet transaction for seller (ID: %s, UserID: %s) - Amount: %.2f (credit)",
+	println(fmt.Sprintf("Created wallet transaction for seller (ID: %s, UserID: %s) - Amount: %s (credit)",
 		sellerWalletTxID, sellerWallet.GetUserID(), totalCost))
 
 	// Wallets balances should now be updated and wallet transactions created //
-	println(fmt.Sprintf("Final balances - Buyer: %.2f, Seller: %.2f",
+	println(fmt.Sprintf("Final balances - Buyer: %s, Seller: %s",
 		buyerWallet.GetBalance(),
 		sellerWallet.GetBalance()))
 
@@ -202,7 +203,7 @@ func updateUserStocks(
 	databaseAccessTransact databaseAccessTransaction.DatabaseAccessInterface,
 	isBuyPartial bool,
 	isSellPartial bool,
-	stockPrice float64,
+	stockPrice money.Money,
 ) error {
 
 	// Step 1: Finds and validates user stock portfolios
@@ -2,6 +2,7 @@ package orderExecutorService
 
 import (
 	//"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/transaction"
 	userStock "Shared/entities/user-stock"
 	"databaseAccessTransaction"
@@ -11,8 +12,8 @@ import (
 )
 
 // Calculates the total cost of a transaction given the quantity and stock price.
-func calculateTotalTransactionCost(quantity int, stockPrice float64) float64 {
-	return float64(quantity) * stockPrice
+func calculateTotalTransactionCost(quantity int, stockPrice money.Money) money.Money {
+	return stockPrice.Mul(quantity)
 }
 
 // Finds and validates user stock portfolios
@@ -139,7 +140,7 @@ func updateTransactionStatus(
 	stockTx transaction.StockTransactionInterface,
 	isBuyPartial bool,
 	isSellPartial bool,
-	stockPrice float64,
+	stockPrice money.Money,
 	databaseAccessTransact databaseAccessTransaction.DatabaseAccessInterface,
 ) error {
 
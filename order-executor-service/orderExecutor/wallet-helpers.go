@@ -2,6 +2,7 @@ package orderExecutorService
 
 import (
     "Shared/entities/entity"
+    "Shared/entities/money"
     "Shared/entities/transaction"
     "Shared/entities/wallet"
     "databaseAccessTransaction"
@@ -15,7 +16,7 @@ import (
 
 // Check if buyer has enough funds to afford the quantity*stockprice
 // If they don't, return to matching engine that the match was unsuccessful.
-func validateBuyerWalletBalance(buyerWallet wallet.WalletInterface, totalCost float64) (bool, error) {
+func validateBuyerWalletBalance(buyerWallet wallet.WalletInterface, totalCost money.Money) (bool, error) {
     if buyerWallet == nil {
         return false, errors.New("buyer wallet not found")
     }
@@ -32,7 +33,7 @@ func validateBuyerWalletBalance(buyerWallet wallet.WalletInterface, totalCost fl
 // Updates the balance of a single wallet and handles errors
 func updateWalletBalance(
     wallet wallet.WalletInterface,
-    amount float64,
+    amount money.Money,
     isDebit bool,
     databaseAccessUser databaseAccessUserManagement.DatabaseAccessInterface,
 ) error {
@@ -61,7 +62,7 @@ func createWalletTransaction(
     wallet wallet.WalletInterface,
     stockTransaction transaction.StockTransactionInterface,
     isDebit bool,
-    amount float64,
+    amount money.Money,
     databaseAccessTransact databaseAccessTransaction.DatabaseAccessInterface,
 ) (string, error) {
     walletTx := transaction.NewWalletTransaction(transaction.NewWalletTransactionParams{
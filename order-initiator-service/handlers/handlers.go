@@ -5,6 +5,7 @@ import (
 	"Shared/entities/transaction"
 	userStock "Shared/entities/user-stock"
 	"Shared/network"
+	"databaseAccessStock"
 	"databaseAccessTransaction"
 	"databaseAccessUserManagement"
 	"encoding/json"
@@ -20,13 +21,15 @@ import (
 
 var _databaseAccess databaseAccessTransaction.DatabaseAccessInterface
 var _databaseAccessUser databaseAccessUserManagement.DatabaseAccessInterface
+var _databaseAccessStock databaseAccessStock.DatabaseAccessInterface
 var _networkHttpManager network.NetworkInterface
 var _networkQueueManager network.NetworkInterface
 
 func InitalizeHandlers(
-	networkHttpManager network.NetworkInterface, networkQueueManager network.NetworkInterface, databaseAccess databaseAccessTransaction.DatabaseAccessInterface, databaseAccessUser databaseAccessUserManagement.DatabaseAccessInterface) {
+	networkHttpManager network.NetworkInterface, networkQueueManager network.NetworkInterface, databaseAccess databaseAccessTransaction.DatabaseAccessInterface, databaseAccessUser databaseAccessUserManagement.DatabaseAccessInterface, databaseAccessStock databaseAccessStock.DatabaseAccessInterface) {
 	_databaseAccess = databaseAccess
 	_databaseAccessUser = databaseAccessUser
+	_databaseAccessStock = databaseAccessStock
 	_networkHttpManager = networkHttpManager
 	_networkQueueManager = networkQueueManager
 
@@ -36,6 +39,7 @@ func InitalizeHandlers(
 	//Add handlers
 	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("engine_route") + "/placeStockOrder", Handler: placeStockOrderHandler})
 	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("engine_route") + "/cancelStockTransaction", Handler: cancelStockTransactionHandler})
+	_networkHttpManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("engine_route") + "/amendStockOrder", Handler: amendStockOrderHandler})
 	http.HandleFunc("/health", healthHandler)
 }
 
@@ -54,15 +58,50 @@ func placeStockOrderHandler(responseWriter network.ResponseWriter, data []byte,
 		return
 	}
 	stockOrder.SetUserID(queryParams.Get("userID"))
-	err = placeStockOrder(stockOrder)
+	// only the matching engine splits orders
+	stockOrder.SetParentStockOrderID("")
+	err = order.ValidateTimeInForce(stockOrder, time.Now())
+	if err == nil {
+		err = order.ValidateStopOrder(stockOrder)
+	}
+	if err == nil {
+		err = order.ValidateDisplayQuantity(stockOrder)
+	}
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// a suspended or delisted stock takes no new orders
+	listedStock, err := _databaseAccessStock.GetByID(stockOrder.GetStockID())
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !listedStock.IsActive() {
+		println("Error: stock ", stockOrder.GetStockID(), " is ", listedStock.GetStatus())
+		responseWriter.WriteHeader(http.StatusConflict)
+		return
+	}
+	// an iceberg always starts by showing one full slice
+	stockOrder.SetVisibleQuantity(stockOrder.GetDisplayQuantity())
+	haltStatus, err := placeStockOrder(stockOrder)
+	if errors.Is(err, errEngineBusy) {
+		// nothing was placed, the user can try again
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		println("Error: ", err.Error())
 		responseWriter.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	// the order is accepted either way, but a halted stock won't fill it until trading resumes
 	returnVal := network.ReturnJSON{
 		Success: true,
-		Data:    nil,
+		Data:    haltStatus,
 	}
 	returnValJSON, err := json.Marshal(returnVal)
 	if err != nil {
@@ -72,14 +111,17 @@ func placeStockOrderHandler(responseWriter network.ResponseWriter, data []byte,
 	responseWriter.Write(returnValJSON)
 }
 
-func placeStockOrder(stockOrder order.StockOrderInterface) error {
+var errEngineBusy = errors.New("matching engine is busy")
+
+// The matching engine turns the order away with errEngineBusy when its queue is full. It cancels the transaction and gives the escrow back itself.
+func placeStockOrder(stockOrder order.StockOrderInterface) (*network.HaltStatus, error) {
 	var err error
 
 	if !stockOrder.GetIsBuy() {
 		// Get seller's current stock holdings
 		sellerStockPortfolio, err := _databaseAccessUser.UserStock().GetUserStocks(stockOrder.GetUserID())
 		if err != nil {
-			return fmt.Errorf("failed to get seller stocks: %v", err)
+			return nil, fmt.Errorf("failed to get seller stocks: %v", err)
 		}
 
 		// Find the stock in the seller's portfolio
@@ -93,10 +135,10 @@ func placeStockOrder(stockOrder order.StockOrderInterface) error {
 
 		// Verify seller has the stock and sufficient quantity
 		if sellerStock == nil {
-			return fmt.Errorf("seller does not own stock %s", stockOrder.GetStockID())
+			return nil, fmt.Errorf("seller does not own stock %s", stockOrder.GetStockID())
 		}
 		if sellerStock.GetQuantity() < stockOrder.GetQuantity() {
-			return fmt.Errorf("insufficient stock quantity: has %d, wants to sell %d",
+			return nil, fmt.Errorf("insufficient stock quantity: has %d, wants to sell %d",
 				sellerStock.GetQuantity(), stockOrder.GetQuantity())
 		}
 
@@ -105,7 +147,7 @@ func placeStockOrder(stockOrder order.StockOrderInterface) error {
 		sellerStock.SetQuantity(newQuantity)
 		err = _databaseAccessUser.UserStock().Update(sellerStock)
 		if err != nil {
-			return fmt.Errorf("failed to update seller stock quantity: %v", err)
+			return nil, fmt.Errorf("failed to update seller stock quantity: %v", err)
 		}
 	}
 
@@ -118,12 +160,34 @@ func placeStockOrder(stockOrder order.StockOrderInterface) error {
 	createdTransaction, err := _databaseAccess.StockTransaction().Create(transaction)
 	if err != nil {
 		println("Error: ", err.Error())
-		return err
+		return nil, err
 	}
 	stockOrder.SetId(createdTransaction.GetId())
 	//pass to matching engine
-	_, err = _networkQueueManager.MatchingEngine().Post("placeStockOrder", stockOrder)
-	return err
+	response, err := _networkQueueManager.MatchingEngine().Post(network.StockRoute("placeStockOrder", stockOrder.GetStockID()), stockOrder)
+	if err != nil {
+		return nil, err
+	}
+	var returnVal struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(response, &returnVal)
+	if err == nil && !returnVal.Success {
+		var reason string
+		json.Unmarshal(returnVal.Data, &reason)
+		return nil, fmt.Errorf("%w: %s", errEngineBusy, reason)
+	}
+	var haltStatus network.HaltStatus
+	if err == nil {
+		err = json.Unmarshal(returnVal.Data, &haltStatus)
+	}
+	if err != nil {
+		// the order is in, only the halt status is missing
+		println("Error: ", err.Error())
+		return nil, nil
+	}
+	return &haltStatus, nil
 }
 
 func cancelStockTransactionHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
@@ -158,14 +222,19 @@ func cancelStockTransactionHandler(responseWriter network.ResponseWriter, data [
 }
 
 func cancelStockTransaction(id string) error {
+	// the stock picks the matching engine instance that has the order
+	stockTransaction, err := _databaseAccess.StockTransaction().GetByID(id)
+	if err != nil {
+		return err
+	}
 	//pass to matching engine
-	_, err := _networkHttpManager.Transactions().Put("cancelStockTransaction/"+id, nil)
+	_, err = _networkHttpManager.Transactions().Put("cancelStockTransaction/"+id, nil)
 	if err != nil {
 		println("Error: ", err.Error())
 		return err
 	}
 
-	_, err = _networkQueueManager.MatchingEngine().Delete("deleteOrder/" + id)
+	_, err = _networkQueueManager.MatchingEngine().Delete(network.StockRoute("deleteOrder/", stockTransaction.GetStockID()) + id)
 	if err != nil {
 		println("Error: ", err.Error())
 		return err
@@ -174,6 +243,88 @@ func cancelStockTransaction(id string) error {
 
 }
 
+// Expected input is a network.AmendStockOrder: {"stock_tx_id": <id>, "quantity": <new open quantity>, "price": <new limit price>}
+// Leave quantity or price out to keep it. Lowering the quantity keeps the order's place in the queue, anything else re-queues it.
+func amendStockOrderHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	println("Amending stock order")
+	var amend network.AmendStockOrder
+	err := json.Unmarshal(data, &amend)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	result, err := amendStockOrder(queryParams.Get("userID"), amend)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	returnVal := network.ReturnJSON{
+		Success: true,
+		Data:    result,
+	}
+	returnValJSON, err := json.Marshal(returnVal)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+func amendStockOrder(userID string, amend network.AmendStockOrder) (*network.AmendStockOrderResult, error) {
+	stockTransaction, err := _databaseAccess.StockTransaction().GetByID(amend.StockTxID)
+	if err != nil {
+		return nil, err
+	}
+	// someone else's order is treated as not found
+	if stockTransaction.GetUserID() != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if stockTransaction.GetOrderStatus() != "IN_PROGRESS" && stockTransaction.GetOrderStatus() != "PARTIALLY_COMPLETE" {
+		return nil, fmt.Errorf("a %s order can't be amended", stockTransaction.GetOrderStatus())
+	}
+
+	// the matching engine applies the amend and moves any escrowed shares
+	response, err := _networkQueueManager.MatchingEngine().Post(network.StockRoute("amendOrder", stockTransaction.GetStockID()), amend)
+	if err != nil {
+		return nil, err
+	}
+	var returnVal struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(response, &returnVal)
+	if err != nil {
+		return nil, err
+	}
+	if !returnVal.Success {
+		var reason string
+		json.Unmarshal(returnVal.Data, &reason)
+		return nil, fmt.Errorf("amend rejected: %s", reason)
+	}
+	var result network.AmendStockOrderResult
+	err = json.Unmarshal(returnVal.Data, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the transaction record in step with the order
+	stockTransaction.SetQuantity(stockTransaction.GetQuantity() + result.QuantityDelta)
+	if stockTransaction.GetOrderStatus() == "IN_PROGRESS" && stockTransaction.GetOrderType() != order.OrderTypeMarket {
+		stockTransaction.SetStockPrice(result.Price)
+	}
+	err = _databaseAccess.StockTransaction().Update(stockTransaction)
+	if err != nil {
+		println("Error: ", err.Error())
+	}
+	return &result, nil
+}
+
 /*
 func cancelStockTransaction(id string) error {
     // Get the transaction details first
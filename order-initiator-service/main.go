@@ -4,6 +4,7 @@ import (
 	OrderInitiatorService "OrderInitiatorService/handlers"
 	networkHttp "Shared/network/http"
 	networkQueue "Shared/network/queue"
+	"databaseAccessStock"
 	"databaseAccessTransaction"
 	"databaseAccessUserManagement"
 	"fmt"
@@ -26,7 +27,11 @@ func main() {
 		Network: networkHttpManager,
 	})
 
-	go OrderInitiatorService.InitalizeHandlers(networkHttpManager, networkQueueManager, databaseAccess, databaseAccessUserManagement)
+	databaseAccessStock := databaseAccessStock.NewDatabaseAccess(&databaseAccessStock.NewDatabaseAccessParams{
+		Network: networkHttpManager,
+	})
+
+	go OrderInitiatorService.InitalizeHandlers(networkHttpManager, networkQueueManager, databaseAccess, databaseAccessUserManagement, databaseAccessStock)
 	fmt.Println("Matching Engine Service Started")
 
 	networkHttpManager.Listen()
@@ -54,11 +54,14 @@ func NewDatabaseAccess(params *NewDatabaseAccessParams) DatabaseAccessInterface
 	return dba
 }
 
+// Delisted stocks are left out, they have no matching engine.
 func (d *DatabaseAccess) GetStockIDs() (*[]string, error) {
 	stocks, err := d.GetAll()
-	stockIDs := make([]string, len(*stocks))
-	for i, stock := range *stocks {
-		stockIDs[i] = stock.GetId()
+	stockIDs := make([]string, 0, len(*stocks))
+	for _, listed := range *stocks {
+		if listed.GetStatus() != stock.StatusDelisted {
+			stockIDs = append(stockIDs, listed.GetId())
+		}
 	}
 	return &stockIDs, err
 }
@@ -3,15 +3,28 @@ package databaseServiceStock
 import (
 	databaseService "Shared/database/database-service"
 	"Shared/entities/stock"
+	"time"
 )
 
 type DatabaseServiceInterface interface {
 	databaseService.EntityDataInterface[*stock.Stock]
+	Candles() CandleDataServiceInterface
 }
 
 type DatabaseService struct {
 	databaseService.EntityDataInterface[*stock.Stock]
 	Stocks *[]stock.StockInterface
+	Candle CandleDataServiceInterface
+}
+
+type CandleDataServiceInterface interface {
+	databaseService.EntityDataInterface[*stock.Candle]
+	GetCandles(stockID string, interval string, from time.Time, to time.Time) (*[]*stock.Candle, error)
+}
+
+// Candles live in the stock database, in their own table.
+type CandleDataService struct {
+	databaseService.EntityDataInterface[*stock.Candle]
 }
 
 type NewDatabaseServiceParams struct {
@@ -35,15 +48,34 @@ func NewDatabaseService(params *NewDatabaseServiceParams) DatabaseServiceInterfa
 		EntityDataInterface: cachedStock,
 	} */
 
+	stockData := databaseService.NewEntityData[*stock.Stock](params.NewEntityDataParams)
 	db := &DatabaseService{
-		EntityDataInterface: databaseService.NewEntityData[*stock.Stock](params.NewEntityDataParams),
+		EntityDataInterface: stockData,
+		Candle: &CandleDataService{
+			EntityDataInterface: databaseService.NewEntityData[*stock.Candle](&databaseService.NewEntityDataParams{Existing: stockData}),
+		},
 	}
 
 	db.Connect()
 	db.GetDatabaseSession().AutoMigrate(&stock.Stock{})
+	db.GetDatabaseSession().AutoMigrate(&stock.Candle{})
 	return db
 }
 
+func (d *DatabaseService) Candles() CandleDataServiceInterface {
+	return d.Candle
+}
+
+// Candles starting in [from, to), oldest first.
+func (d *CandleDataService) GetCandles(stockID string, interval string, from time.Time, to time.Time) (*[]*stock.Candle, error) {
+	var candles []*stock.Candle
+	result := d.GetDatabaseSession().Where("stock_id = ? AND candle_interval = ? AND start_time >= ? AND start_time < ?", stockID, interval, from, to).Order("start_time").Find(&candles)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &candles, nil
+}
+
 func (d *DatabaseService) Connect() {
 	d.EntityDataInterface.Connect()
 }
@@ -8,11 +8,19 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 var _databaseManager databaseServiceStock.DatabaseServiceInterface
 var _networkManager network.NetworkInterface
 
+// Trades for the same candle have to be applied one at a time
+var _candleMutex sync.Mutex
+
 func InitalizeHandlers(
 	networkManager network.NetworkInterface, databaseManager databaseServiceStock.DatabaseServiceInterface) {
 	_databaseManager = databaseManager
@@ -20,7 +28,12 @@ func InitalizeHandlers(
 
 	//Add handlers
 	_networkManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/createStock", Handler: AddNewStockHandler})
+	_networkManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/delistStock", Handler: DelistStockHandler})
+	_networkManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/suspendStock", Handler: SuspendStockHandler})
+	_networkManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("setup_route") + "/reinstateStock", Handler: ReinstateStockHandler})
 	_networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "getStockIDs", Handler: GetStockIDsHandler})
+	_networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "recordTrade", Handler: RecordTradeHandler})
+	_networkManager.AddHandleFuncProtected(network.HandlerParams{Pattern: os.Getenv("transaction_route") + "/getStockPriceHistory", Handler: GetStockPriceHistoryHandler})
 	network.CreateNetworkEntityHandlers[*stock.Stock](_networkManager, os.Getenv("STOCK_DATABASE_SERVICE_ROUTE"), _databaseManager, stock.Parse, stock.ParseList)
 	http.HandleFunc("/health", healthHandler)
 
@@ -39,9 +52,11 @@ func GetStockIDsHandler(responseWriter network.ResponseWriter, data []byte, quer
 		responseWriter.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	stockIDs := make([]string, len(*stocks))
-	for i, stock := range *stocks {
-		stockIDs[i] = stock.GetId()
+	stockIDs := make([]string, 0, len(*stocks))
+	for _, listed := range *stocks {
+		if listed.GetStatus() != stock.StatusDelisted {
+			stockIDs = append(stockIDs, listed.GetId())
+		}
 	}
 	stockIDsJSON, err := json.Marshal(stockIDs)
 	if err != nil {
@@ -56,6 +71,10 @@ func AddNewStockHandler(responseWriter network.ResponseWriter, data []byte, quer
 	newStock, err := stock.Parse(data)
 
 	println("Parsed Stock: ", newStock.GetId())
+	if err == nil {
+		err = stock.ValidateAllocationPolicy(newStock.GetAllocationPolicy())
+		newStock.SetStatus(stock.StatusActive)
+	}
 	if err != nil {
 		println("Error: ", err.Error())
 		responseWriter.WriteHeader(http.StatusBadRequest)
@@ -86,3 +105,225 @@ func AddNewStockHandler(responseWriter network.ResponseWriter, data []byte, quer
 	}
 	responseWriter.Write(returnValJSON)
 }
+
+// Expected input is a network.StockID. The stock is marked delisted, then the matching engine cancels every order resting in
+// its books and stops its engine. Answers with the engine's network.DelistedStock. There is no undoing it, so the status is saved
+// before the engine is told, and put back if the engine refuses.
+func DelistStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	listedStock, ok := stockToChange(responseWriter, data, stock.StatusDelisted, stock.StatusActive, stock.StatusSuspended)
+	if !ok {
+		return
+	}
+	previousStatus := listedStock.GetStatus()
+	listedStock.SetStatus(stock.StatusDelisted)
+	err := _databaseManager.Update(listedStock)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	response, err := _networkManager.MatchingEngine().Post("delistStock", network.StockID{StockID: listedStock.GetId()})
+	if err != nil {
+		println("Error: ", err.Error())
+		listedStock.SetStatus(previousStatus)
+		rollbackErr := _databaseManager.Update(listedStock)
+		if rollbackErr != nil {
+			println("Error: matching engine didn't delist stock ", listedStock.GetId(), " but it couldn't be made ", previousStatus, " again: ", rollbackErr.Error())
+		}
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(response)
+}
+
+// Expected input is a network.StockID. Halts trading in an active stock and stops it taking new orders. Its resting orders are kept.
+// Answers with a network.StockStatus.
+func SuspendStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	stockStatus, _, ok := changeStockStatus(responseWriter, data, "suspendStock", stock.StatusSuspended, stock.StatusActive)
+	if ok {
+		writeStockStatus(responseWriter, stockStatus)
+	}
+}
+
+// Expected input is a network.StockID. Trading in a suspended stock carries on. Answers with a network.StockStatus.
+func ReinstateStockHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	stockStatus, _, ok := changeStockStatus(responseWriter, data, "reinstateStock", stock.StatusActive, stock.StatusSuspended)
+	if ok {
+		writeStockStatus(responseWriter, stockStatus)
+	}
+}
+
+// Tells the matching engine with pattern, then moves the stock in data to status if it is in one of from.
+// The engine goes first, so a stock it refuses keeps its status. Returns what the engine answered, or writes the error status.
+func changeStockStatus(responseWriter network.ResponseWriter, data []byte, pattern string, status string, from ...string) (network.StockStatus, []byte, bool) {
+	listedStock, ok := stockToChange(responseWriter, data, status, from...)
+	if !ok {
+		return network.StockStatus{}, nil, false
+	}
+	stockID := network.StockID{StockID: listedStock.GetId()}
+	response, err := _networkManager.MatchingEngine().Post(pattern, stockID)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return network.StockStatus{}, nil, false
+	}
+	listedStock.SetStatus(status)
+	err = _databaseManager.Update(listedStock)
+	if err != nil {
+		println("Error: matching engine has made stock ", stockID.StockID, " ", status, " but it couldn't be saved: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return network.StockStatus{}, nil, false
+	}
+	return network.StockStatus{StockID: stockID.StockID, Status: status}, response, true
+}
+
+// The stock in data, if it is in one of from and so can be made status. Writes the error status if it can't.
+func stockToChange(responseWriter network.ResponseWriter, data []byte, status string, from ...string) (*stock.Stock, bool) {
+	var stockID network.StockID
+	err := json.Unmarshal(data, &stockID)
+	if err != nil || stockID.StockID == "" {
+		println("Error: expected the stock ID")
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	listedStock, err := _databaseManager.GetByID(stockID.StockID)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if !slices.Contains(from, listedStock.GetStatus()) {
+		println("Error: stock ", stockID.StockID, " is ", listedStock.GetStatus(), ", it can't be made ", status)
+		responseWriter.WriteHeader(http.StatusConflict)
+		return nil, false
+	}
+	return listedStock, true
+}
+
+func writeStockStatus(responseWriter network.ResponseWriter, stockStatus network.StockStatus) {
+	returnValJSON, err := json.Marshal(network.ReturnJSON{
+		Success: true,
+		Data:    stockStatus,
+	})
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+// Expected input is a network.StockTrade from the matching engine. The trade is added to its 1m, 5m, 1h and 1d candles.
+func RecordTradeHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	var stockTrade network.StockTrade
+	err := json.Unmarshal(data, &stockTrade)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = RecordTrade(stockTrade)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+// Every interval is updated in one transaction, so a failure part way leaves none of them with the trade.
+func RecordTrade(stockTrade network.StockTrade) error {
+	_candleMutex.Lock()
+	defer _candleMutex.Unlock()
+	return _databaseManager.Candles().GetDatabaseSession().Transaction(func(tx *gorm.DB) error {
+		for interval := range stock.CandleIntervals {
+			startTime := stock.CandleStart(interval, stockTrade.Trade.Timestamp)
+			var candles []*stock.Candle
+			err := tx.Where("stock_id = ? AND candle_interval = ? AND start_time = ?", stockTrade.StockID, interval, startTime).Limit(1).Find(&candles).Error
+			if err != nil {
+				return err
+			}
+			if len(candles) == 0 {
+				candle := stock.NewCandle(stock.NewCandleParams{
+					StockID:   stockTrade.StockID,
+					Interval:  interval,
+					StartTime: startTime,
+				})
+				candle.AddTrade(stockTrade.Trade.Price, stockTrade.Trade.Quantity)
+				err = tx.Create(candle).Error
+			} else {
+				candles[0].AddTrade(stockTrade.Trade.Price, stockTrade.Trade.Quantity)
+				err = tx.Save(candles[0]).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+const defaultPriceHistoryCandles = 100
+
+// Query params: stock_id, interval (1m, 5m, 1h or 1d) and an optional from and to in RFC3339.
+// to defaults to now, from defaults to 100 candles before to.
+func GetStockPriceHistoryHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	stockID := queryParams.Get("stock_id")
+	interval := queryParams.Get("interval")
+	intervalLength, ok := stock.CandleIntervals[interval]
+	if stockID == "" || !ok {
+		println("Error: stock_id and a valid interval are required")
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	to := time.Now()
+	if queryParams.Get("to") != "" {
+		parsedTo, err := time.Parse(time.RFC3339, queryParams.Get("to"))
+		if err != nil {
+			println("Error: ", err.Error())
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		to = parsedTo
+	}
+	from := to.Add(-defaultPriceHistoryCandles * intervalLength)
+	if queryParams.Get("from") != "" {
+		parsedFrom, err := time.Parse(time.RFC3339, queryParams.Get("from"))
+		if err != nil {
+			println("Error: ", err.Error())
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		from = parsedFrom
+	}
+	candles, err := _databaseManager.Candles().GetCandles(stockID, interval, stock.CandleStart(interval, from), to)
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	history := network.StockPriceHistory{
+		StockID:  stockID,
+		Interval: interval,
+		Candles:  make([]network.Candle, len(*candles)),
+	}
+	for i, candle := range *candles {
+		history.Candles[i] = network.Candle{
+			StartTime: candle.GetStartTime(),
+			Open:      candle.GetOpen(),
+			High:      candle.GetHigh(),
+			Low:       candle.GetLow(),
+			Close:     candle.GetClose(),
+			Volume:    candle.GetVolume(),
+		}
+	}
+	returnVal := network.ReturnJSON{
+		Success: true,
+		Data:    history,
+	}
+	historyJSON, err := json.Marshal(returnVal)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(historyJSON)
+}
@@ -3,5 +3,7 @@ CREATE TABLE stocks (
     ID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     DateCreated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     DateModified TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    Name TEXT NOT NULL
+    Name TEXT NOT NULL,
+    AllocationPolicy TEXT NOT NULL DEFAULT 'FIFO',
+    Status TEXT NOT NULL DEFAULT 'ACTIVE'
 );
\ No newline at end of file
@@ -7,8 +7,14 @@ CREATE TABLE stockOrder (
     DateModified TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     IsBuy BOOLEAN NOT NULL,
     OrderType TEXT NOT NULL,
-    Price DECIMAL NOT NULL,
+    Price DECIMAL(18, 2) NOT NULL,
     Quantity INT NOT NULL,
     UserID UUID NOT NULL,
+    TimeInForce TEXT,
+    ExpiresAt TIMESTAMP,
+    StopPrice DECIMAL(18, 2),
+    QueuedAt TIMESTAMP,
+    DisplayQuantity INT DEFAULT 0,
+    VisibleQuantity INT DEFAULT 0,
     FOREIGN KEY (ParentStockOrderID) REFERENCES stockOrder(ID)
 );
\ No newline at end of file
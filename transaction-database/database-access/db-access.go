@@ -9,22 +9,30 @@ import (
 
 type StockTransactionDataAccessInterface = databaseAccess.EntityDataAccessInterface[*transaction.StockTransaction, transaction.StockTransactionInterface]
 type WalletTransactionDataAccessInterface = databaseAccess.EntityDataAccessInterface[*transaction.WalletTransaction, transaction.WalletTransactionInterface]
+type CorporateActionDataAccessInterface = databaseAccess.EntityDataAccessInterface[*transaction.CorporateAction, transaction.CorporateActionInterface]
+type MatchExecutionDataAccessInterface = databaseAccess.EntityDataAccessInterface[*transaction.MatchExecution, transaction.MatchExecutionInterface]
 
 type DatabaseAccessInterface interface {
 	databaseAccess.DatabaseAccessInterface
 	StockTransaction() StockTransactionDataAccessInterface
 	WalletTransaction() WalletTransactionDataAccessInterface
+	CorporateAction() CorporateActionDataAccessInterface
+	MatchExecution() MatchExecutionDataAccessInterface
 }
 
 type DatabaseAccess struct {
 	StockTransactionDataAccessInterface
 	WalletTransactionDataAccessInterface
-	_networkManager network.NetworkInterface
+	corporateActions CorporateActionDataAccessInterface
+	matchExecutions  MatchExecutionDataAccessInterface
+	_networkManager  network.NetworkInterface
 }
 
 type NewDatabaseAccessParams struct {
 	StockTransactionParams  *databaseAccess.NewEntityDataAccessHTTPParams[*transaction.StockTransaction]
 	WalletTransactionParams *databaseAccess.NewEntityDataAccessHTTPParams[*transaction.WalletTransaction]
+	CorporateActionParams   *databaseAccess.NewEntityDataAccessHTTPParams[*transaction.CorporateAction] // leave nil for default
+	MatchExecutionParams    *databaseAccess.NewEntityDataAccessHTTPParams[*transaction.MatchExecution]  // leave nil for default
 	Network                 network.NetworkInterface
 }
 
@@ -37,6 +45,14 @@ func NewDatabaseAccess(params *NewDatabaseAccessParams) DatabaseAccessInterface
 		params.WalletTransactionParams = &databaseAccess.NewEntityDataAccessHTTPParams[*transaction.WalletTransaction]{}
 	}
 
+	if params.CorporateActionParams == nil {
+		params.CorporateActionParams = &databaseAccess.NewEntityDataAccessHTTPParams[*transaction.CorporateAction]{}
+	}
+
+	if params.MatchExecutionParams == nil {
+		params.MatchExecutionParams = &databaseAccess.NewEntityDataAccessHTTPParams[*transaction.MatchExecution]{}
+	}
+
 	if params.Network == nil {
 		panic("No network provided")
 	}
@@ -66,10 +82,36 @@ func NewDatabaseAccess(params *NewDatabaseAccessParams) DatabaseAccessInterface
 	if params.WalletTransactionParams.ParserList == nil {
 		params.WalletTransactionParams.ParserList = transaction.ParseWalletTransactionList
 	}
+	if params.CorporateActionParams.Client == nil {
+		params.CorporateActionParams.Client = params.Network.Transactions()
+	}
+	if params.CorporateActionParams.DefaultRoute == "" {
+		params.CorporateActionParams.DefaultRoute = os.Getenv("TRANSACTION_DATABASE_SERVICE_CORPORATE_ACTION_ROUTE")
+	}
+	if params.CorporateActionParams.Parser == nil {
+		params.CorporateActionParams.Parser = transaction.ParseCorporateAction
+	}
+	if params.CorporateActionParams.ParserList == nil {
+		params.CorporateActionParams.ParserList = transaction.ParseCorporateActionList
+	}
+	if params.MatchExecutionParams.Client == nil {
+		params.MatchExecutionParams.Client = params.Network.Transactions()
+	}
+	if params.MatchExecutionParams.DefaultRoute == "" {
+		params.MatchExecutionParams.DefaultRoute = os.Getenv("TRANSACTION_DATABASE_SERVICE_MATCH_EXECUTION_ROUTE")
+	}
+	if params.MatchExecutionParams.Parser == nil {
+		params.MatchExecutionParams.Parser = transaction.ParseMatchExecution
+	}
+	if params.MatchExecutionParams.ParserList == nil {
+		params.MatchExecutionParams.ParserList = transaction.ParseMatchExecutionList
+	}
 
 	dba := &DatabaseAccess{
 		StockTransactionDataAccessInterface:  databaseAccess.NewEntityDataAccessHTTP[*transaction.StockTransaction, transaction.StockTransactionInterface](params.StockTransactionParams),
 		WalletTransactionDataAccessInterface: databaseAccess.NewEntityDataAccessHTTP[*transaction.WalletTransaction, transaction.WalletTransactionInterface](params.WalletTransactionParams),
+		corporateActions:                     databaseAccess.NewEntityDataAccessHTTP[*transaction.CorporateAction, transaction.CorporateActionInterface](params.CorporateActionParams),
+		matchExecutions:                      databaseAccess.NewEntityDataAccessHTTP[*transaction.MatchExecution, transaction.MatchExecutionInterface](params.MatchExecutionParams),
 		_networkManager:                      params.Network,
 	}
 
@@ -90,3 +132,11 @@ func (d *DatabaseAccess) StockTransaction() StockTransactionDataAccessInterface
 func (d *DatabaseAccess) WalletTransaction() WalletTransactionDataAccessInterface {
 	return d.WalletTransactionDataAccessInterface
 }
+
+func (d *DatabaseAccess) CorporateAction() CorporateActionDataAccessInterface {
+	return d.corporateActions
+}
+
+func (d *DatabaseAccess) MatchExecution() MatchExecutionDataAccessInterface {
+	return d.matchExecutions
+}
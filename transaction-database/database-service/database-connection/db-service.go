@@ -7,16 +7,22 @@ import (
 
 type StockTransactionDataServiceInterface = databaseService.EntityDataInterface[*transaction.StockTransaction]
 type WalletTransactionDataServiceInterface = databaseService.EntityDataInterface[*transaction.WalletTransaction]
+type CorporateActionDataServiceInterface = databaseService.EntityDataInterface[*transaction.CorporateAction]
+type MatchExecutionDataServiceInterface = databaseService.EntityDataInterface[*transaction.MatchExecution]
 
 type DatabaseServiceInterface interface {
 	databaseService.DatabaseInterface
 	StockTransactions() StockTransactionDataServiceInterface
 	WalletTransactions() WalletTransactionDataServiceInterface
+	CorporateActions() CorporateActionDataServiceInterface
+	MatchExecutions() MatchExecutionDataServiceInterface
 }
 
 type DatabaseService struct {
 	StockTransaction  StockTransactionDataServiceInterface
 	WalletTransaction WalletTransactionDataServiceInterface
+	CorporateAction   CorporateActionDataServiceInterface
+	MatchExecution    MatchExecutionDataServiceInterface
 	databaseService.DatabaseInterface
 }
 
@@ -76,11 +82,20 @@ func NewDatabaseService(params *NewDatabaseServiceParams) DatabaseServiceInterfa
 	db := &DatabaseService{
 		StockTransaction:  databaseService.NewEntityData[*transaction.StockTransaction](params.StockTransactionParams),
 		WalletTransaction: databaseService.NewEntityData[*transaction.WalletTransaction](params.WalletTransactionParams),
+		// audit records, kept in the same database
+		CorporateAction: databaseService.NewEntityData[*transaction.CorporateAction](&databaseService.NewEntityDataParams{
+			Existing: newDBConnection,
+		}),
+		MatchExecution: databaseService.NewEntityData[*transaction.MatchExecution](&databaseService.NewEntityDataParams{
+			Existing: newDBConnection,
+		}),
 		DatabaseInterface: newDBConnection,
 	}
 	db.Connect()
 	db.StockTransactions().GetDatabaseSession().AutoMigrate(&transaction.StockTransaction{})
 	db.WalletTransactions().GetDatabaseSession().AutoMigrate(&transaction.WalletTransaction{})
+	db.CorporateActions().GetDatabaseSession().AutoMigrate(&transaction.CorporateAction{})
+	db.MatchExecutions().GetDatabaseSession().AutoMigrate(&transaction.MatchExecution{})
 	return db
 }
 
@@ -92,6 +107,14 @@ func (d *DatabaseService) WalletTransactions() WalletTransactionDataServiceInter
 	return d.WalletTransaction
 }
 
+func (d *DatabaseService) CorporateActions() CorporateActionDataServiceInterface {
+	return d.CorporateAction
+}
+
+func (d *DatabaseService) MatchExecutions() MatchExecutionDataServiceInterface {
+	return d.MatchExecution
+}
+
 func (d *DatabaseService) Connect() {
 	d.StockTransactions().Connect()
 	d.StockTransactions().Connect()
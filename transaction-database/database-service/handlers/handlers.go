@@ -1,6 +1,7 @@
 package transactionDatabaseHandlers
 
 import (
+	"Shared/entities/money"
 	"Shared/entities/transaction"
 	"Shared/network"
 	databaseServiceTransaction "databaseServiceTransaction/database-connection"
@@ -30,6 +31,8 @@ func InitalizeHandlers(
 	networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "cancelStockTransaction/", Handler: cancelStockTransactionHandler})
 	network.CreateNetworkEntityHandlers[*transaction.StockTransaction](_networkManager, os.Getenv("TRANSACTION_DATABASE_SERVICE_STOCK_ROUTE"), _databaseManager.StockTransactions(), transaction.ParseStockTransaction, transaction.ParseStockTransactionList)
 	network.CreateNetworkEntityHandlers[*transaction.WalletTransaction](_networkManager, os.Getenv("TRANSACTION_DATABASE_SERVICE_WALLET_ROUTE"), _databaseManager.WalletTransactions(), transaction.ParseWalletTransaction, transaction.ParseWalletTransactionList)
+	network.CreateNetworkEntityHandlers[*transaction.CorporateAction](_networkManager, os.Getenv("TRANSACTION_DATABASE_SERVICE_CORPORATE_ACTION_ROUTE"), _databaseManager.CorporateActions(), transaction.ParseCorporateAction, transaction.ParseCorporateActionList)
+	network.CreateNetworkEntityHandlers[*transaction.MatchExecution](_networkManager, os.Getenv("TRANSACTION_DATABASE_SERVICE_MATCH_EXECUTION_ROUTE"), _databaseManager.MatchExecutions(), transaction.ParseMatchExecution, transaction.ParseMatchExecutionList)
 	http.HandleFunc("/health", healthHandler)
 }
 
@@ -67,7 +70,7 @@ func GetStockTransactions(responseWriter network.ResponseWriter, data []byte, qu
 		OrderStatus     string    `json:"order_status"`
 		IsBuy           bool      `json:"is_buy"`
 		OrderType       string    `json:"order_type"`
-		StockPrice      float64   `json:"stock_price"`
+		StockPrice      money.Money `json:"stock_price"`
 		Quantity        int       `json:"quantity"`
 		Timestamp       time.Time `json:"time_stamp"`
 	}
@@ -156,13 +159,19 @@ func getWalletTransactions(responseWriter network.ResponseWriter, data []byte, q
         WalletTxID string   `json:"wallet_tx_id"`
         StockTxID  string    `json:"stock_tx_id"`
         IsDebit    bool      `json:"is_debit"`
-        Amount     float64   `json:"amount"`
+        Amount     money.Money `json:"amount"`
         Timestamp  time.Time `json:"time_stamp"`
+        Type       string    `json:"type"` // trades, and money paid out by corporate actions
+        CorporateActionID string `json:"corporate_action_id,omitempty"`
     }
 
     // Format transactions
     formattedTransactions := make([]FormattedWalletTransaction, 0)
     for _, tx := range *walletTransactions {
+        // ?type=DIVIDEND lists only dividends, and so on
+        if queryParams.Get("type") != "" && tx.GetType() != queryParams.Get("type") {
+            continue
+        }
         tx.SetWalletTXID() // ensure the wallet_tx_id is set
         
 
@@ -173,6 +182,8 @@ func getWalletTransactions(responseWriter network.ResponseWriter, data []byte, q
             IsDebit:    tx.GetIsDebit(),
             Amount:     tx.GetAmount(),
             Timestamp:  tx.GetTimestamp(),
+            Type:       tx.GetType(),
+            CorporateActionID: tx.GetCorporateActionID(),
         }
 
         formattedTransactions = append(formattedTransactions, formatted)
@@ -204,6 +215,12 @@ func cancelStockTransactionHandler(responseWriter network.ResponseWriter, data [
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
 	}
+	// a user cancel and the engine cancelling an unfilled remainder both come here. Either way what already filled stands,
+	// so a partly filled order stays PARTIALLY_COMPLETE and only an order that never traded becomes CANCELLED
+	if stockTransaction.GetOrderStatus() == "PARTIALLY_COMPLETE" {
+		responseWriter.WriteHeader(http.StatusOK)
+		return
+	}
 	stockTransaction.SetOrderStatus("CANCELLED")
 	err = _databaseManager.StockTransactions().Update(stockTransaction)
 	if err != nil {
@@ -10,10 +10,13 @@ CREATE TABLE stockTransactions (
     OrderStatus TEXT NOT NULL,
     IsBuy BOOLEAN NOT NULL,
     OrderType TEXT NOT NULL,
-    StockPrice DECIMAL NOT NULL,
+    StockPrice DECIMAL(18, 2) NOT NULL,
     Quantity INT NOT NULL,
     UserID UUID NOT NULL,
     Timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    TimeInForce TEXT,
+    ExpiresAt TIMESTAMP,
+    DisplayQuantity INT DEFAULT 0,
     FOREIGN KEY (ParentStockTransactionID) REFERENCES stockTransactions(ID)
 );
 
@@ -25,8 +28,37 @@ CREATE TABLE walletTransactions (
     DateCreated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     DateModified TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     IsDebit BOOLEAN NOT NULL,
-    Amount DECIMAL NOT NULL,
+    Amount DECIMAL(18, 2) NOT NULL,
     UserID UUID NOT NULL,
     Timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    Type TEXT NOT NULL DEFAULT 'TRADE',
+    CorporateActionID UUID,
     FOREIGN KEY (StockTransactionID) REFERENCES stockTransactions(ID)
 );
+
+CREATE TABLE corporateActions (
+    ID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    StockID UUID NOT NULL,
+    ActionType TEXT NOT NULL,
+    NewShares INT DEFAULT 0,
+    OldShares INT DEFAULT 0,
+    Price DECIMAL(18, 2) DEFAULT 0.00,
+    CashPaid DECIMAL(18, 2) DEFAULT 0.00,
+    Holders INT DEFAULT 0,
+    RecordDate TIMESTAMP,
+    Status TEXT,
+    DateCreated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    DateModified TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    Timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE matchExecutions (
+    ID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    MatchID TEXT NOT NULL UNIQUE,
+    StockID UUID,
+    Status TEXT NOT NULL,
+    IsBuyFailure BOOLEAN NOT NULL DEFAULT FALSE,
+    IsSellFailure BOOLEAN NOT NULL DEFAULT FALSE,
+    DateCreated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    DateModified TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
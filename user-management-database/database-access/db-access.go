@@ -2,9 +2,11 @@ package databaseAccessUserManagement
 
 import (
 	databaseAccess "Shared/database/database-access"
+	"Shared/entities/money"
 	userStock "Shared/entities/user-stock"
 	"Shared/entities/wallet"
 	"Shared/network"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -13,16 +15,19 @@ import (
 type UserStocksDataAccessInterface interface {
 	databaseAccess.EntityDataAccessInterface[*userStock.UserStock, userStock.UserStockInterface]
 	GetUserStocks(userID string) (*[]userStock.UserStockInterface, error)
+	SplitStock(split *network.UserStockSplit) ([]network.SplitHolding, error)
+	PayDividend(payout *network.DividendPayout) ([]network.DividendPayment, error)
 }
 
 type UserStocksDataAccess struct {
 	databaseAccess.EntityDataAccessInterface[*userStock.UserStock, userStock.UserStockInterface]
+	client network.ClientInterface
 }
 
 type WalletDataAccessInterface interface {
 	databaseAccess.EntityDataAccessInterface[*wallet.Wallet, wallet.WalletInterface]
-	AddMoneyToWallet(userID string, amount float64) error
-	GetWalletBalance(userID string) (float64, error)
+	AddMoneyToWallet(userID string, amount money.Money) error
+	GetWalletBalance(userID string) (money.Money, error)
 }
 
 type WalletDataAccess struct {
@@ -89,6 +94,7 @@ func NewDatabaseAccess(params *NewDatabaseAccessParams) DatabaseAccessInterface
 	dba := &DatabaseAccess{
 		UserStocksDataAccessInterface: &UserStocksDataAccess{
 			EntityDataAccessInterface: databaseAccess.NewEntityDataAccessHTTP[*userStock.UserStock, userStock.UserStockInterface](params.UserStockParams),
+			client:                    params.UserStockParams.Client,
 		},
 		WalletDataAccessInterface: &WalletDataAccess{
 			EntityDataAccessInterface: databaseAccess.NewEntityDataAccessHTTP[*wallet.Wallet, wallet.WalletInterface](params.WalletParams),
@@ -123,8 +129,40 @@ func (d *UserStocksDataAccess) GetUserStocks(userID string) (*[]userStock.UserSt
 	return userStocks, nil
 }
 
-func (d *WalletDataAccess) AddMoneyToWallet(userID string, amount float64) error {
-	fmt.Printf("DEBUG: AddMoneyToWallet called for userID=%s with amount=%f\n", userID, amount)
+// Rescales every holding of the stock and pays holders for the parts of a share left over, all or nothing.
+func (d *UserStocksDataAccess) SplitStock(split *network.UserStockSplit) ([]network.SplitHolding, error) {
+	data, err := d.client.Post("splitUserStocks", split)
+	if err != nil {
+		return nil, err
+	}
+	var holdings struct {
+		Data []network.SplitHolding `json:"data"`
+	}
+	err = json.Unmarshal(data, &holdings)
+	if err != nil {
+		return nil, err
+	}
+	return holdings.Data, nil
+}
+
+// Credits every holder of the stock, all or nothing.
+func (d *UserStocksDataAccess) PayDividend(payout *network.DividendPayout) ([]network.DividendPayment, error) {
+	data, err := d.client.Post("payDividend", payout)
+	if err != nil {
+		return nil, err
+	}
+	var payments struct {
+		Data []network.DividendPayment `json:"data"`
+	}
+	err = json.Unmarshal(data, &payments)
+	if err != nil {
+		return nil, err
+	}
+	return payments.Data, nil
+}
+
+func (d *WalletDataAccess) AddMoneyToWallet(userID string, amount money.Money) error {
+	fmt.Printf("DEBUG: AddMoneyToWallet called for userID=%s with amount=%s\n", userID, amount)
 
 	walletList, err := d.GetByForeignID("user_id", userID)
 	if err != nil {
@@ -141,7 +179,7 @@ func (d *WalletDataAccess) AddMoneyToWallet(userID string, amount float64) error
 	wallet := (*walletList)[0]
 	oldBalance := wallet.GetBalance()
 	newBalance := oldBalance + amount
-	fmt.Printf("DEBUG: Updating wallet for userID=%s: old balance=%f, new balance=%f\n", userID, oldBalance, newBalance)
+	fmt.Printf("DEBUG: Updating wallet for userID=%s: old balance=%s, new balance=%s\n", userID, oldBalance, newBalance)
 
 	wallet.SetBalance(newBalance)
 	err = d.Update(wallet)
@@ -154,7 +192,7 @@ func (d *WalletDataAccess) AddMoneyToWallet(userID string, amount float64) error
 	return nil
 }
 
-func (d *WalletDataAccess) GetWalletBalance(userID string) (float64, error) {
+func (d *WalletDataAccess) GetWalletBalance(userID string) (money.Money, error) {
 	walletList, err := d.GetByForeignID("user_id", userID)
 	if err != nil {
 		fmt.Printf("[DEBUG] Error fetching wallet by foreign ID for userID %s: %v\n", userID, err)
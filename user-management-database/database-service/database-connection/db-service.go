@@ -2,6 +2,8 @@ package databaseServiceUserManagement
 
 import (
 	databaseService "Shared/database/database-service"
+	"Shared/entities/entity"
+	"Shared/entities/money"
 	userStock "Shared/entities/user-stock"
 	"Shared/entities/wallet"
 )
@@ -26,6 +28,28 @@ type DatabaseService struct {
 	databaseService.DatabaseInterface
 }
 
+// One holder's share of a dividend, written with the wallet credit. A dividend and user are unique, so nobody is paid the same dividend twice.
+type PaidDividend struct {
+	CorporateActionID string      `gorm:"not null;index:idx_paid_dividend,unique"`
+	UserID            string      `gorm:"not null;index:idx_paid_dividend,unique"`
+	WalletID          string      `gorm:"not null"`
+	Shares            int         `gorm:"not null"`
+	Cash              money.Money `gorm:"not null"`
+	entity.Entity     `gorm:"embedded"`
+}
+
+// One holding as a split left it, written with the holding. A split and holding are unique, so no holding is split twice by the same split.
+type SplitHolding struct {
+	CorporateActionID string      `gorm:"not null;index:idx_split_holding,unique"`
+	UserStockID       string      `gorm:"not null;index:idx_split_holding,unique"`
+	UserID            string      `gorm:"not null"`
+	WalletID          string      `gorm:"not null"`
+	Before            int         `gorm:"not null"`
+	After             int         `gorm:"not null"`
+	Cash              money.Money `gorm:"not null"`
+	entity.Entity     `gorm:"embedded"`
+}
+
 type NewDatabaseServiceParams struct {
 	UserStockParams *databaseService.NewEntityDataParams // leave nil for default
 	WalletParams    *databaseService.NewEntityDataParams // leave nil for default
@@ -89,6 +113,8 @@ func NewDatabaseService(params *NewDatabaseServiceParams) DatabaseServiceInterfa
 	db.Connect()
 	db.UserStocks().GetDatabaseSession().AutoMigrate(&userStock.UserStock{})
 	db.Wallets().GetDatabaseSession().AutoMigrate(&wallet.Wallet{})
+	db.Wallets().GetDatabaseSession().AutoMigrate(&PaidDividend{})
+	db.Wallets().GetDatabaseSession().AutoMigrate(&SplitHolding{})
 
 	return db
 }
@@ -5,8 +5,13 @@ import (
 	"Shared/entities/wallet"
 	"Shared/network"
 	databaseServiceUserManagement "databaseServiceUserManagement/database-connection"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+
+	"gorm.io/gorm"
 )
 
 var _databaseManager databaseServiceUserManagement.DatabaseServiceInterface
@@ -20,6 +25,8 @@ func InitalizeHandlers(
 	//Add handlers
 	network.CreateNetworkEntityHandlers[*userStock.UserStock](_networkManager, os.Getenv("USER_MANAGEMENT_SERVICE_USER_STOCK_ROUTE"), _databaseManager.UserStocks(), userStock.Parse, userStock.ParseList)
 	network.CreateNetworkEntityHandlers[*wallet.Wallet](_networkManager, os.Getenv("USER_MANAGEMENT_SERVICE_WALLET_ROUTE"), _databaseManager.Wallets(), wallet.Parse, wallet.ParseList)
+	_networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "splitUserStocks", Handler: splitUserStocksHandler})
+	_networkManager.AddHandleFuncUnprotected(network.HandlerParams{Pattern: "payDividend", Handler: payDividendHandler})
 	http.HandleFunc("/health", healthHandler)
 }
 
@@ -28,3 +35,276 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	//fmt.Println(w, "OK")
 }
+
+// Expected input is a network.UserStockSplit. Rescales every holding of the stock and pays for the parts of a share left over
+// into the holders' wallets, in one database transaction so a failure leaves every holding and wallet as it was.
+// Answers with a network.ReturnJSON holding the []network.SplitHolding.
+// A split that was already made answers with what it did then, without splitting the holdings again.
+func splitUserStocksHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	var split network.UserStockSplit
+	err := json.Unmarshal(data, &split)
+	if err == nil && (split.CorporateActionID == "" || split.StockID == "") {
+		err = fmt.Errorf("expected a corporate action ID and a stock ID")
+	}
+	if err == nil {
+		err = split.Split.Validate()
+	}
+	if err != nil {
+		println("Error: ", err.Error())
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var holdings []network.SplitHolding
+	err = _databaseManager.UserStocks().GetDatabaseSession().Transaction(func(tx *gorm.DB) error {
+		var err error
+		holdings, err = splitHoldings(tx, &split)
+		return err
+	})
+	if err != nil {
+		println("Error splitting holdings of stock: ", split.StockID, " ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	returnValJSON, err := json.Marshal(network.ReturnJSON{
+		Success: true,
+		Data:    holdings,
+	})
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+func splitHoldings(tx *gorm.DB, split *network.UserStockSplit) ([]network.SplitHolding, error) {
+	var made []databaseServiceUserManagement.SplitHolding
+	err := tx.Where("corporate_action_id = ?", split.CorporateActionID).Find(&made).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(made) > 0 {
+		println("Split: ", split.CorporateActionID, " was already made")
+		holdings := make([]network.SplitHolding, 0, len(made))
+		for _, m := range made {
+			holdings = append(holdings, network.SplitHolding{
+				UserID:   m.UserID,
+				WalletID: m.WalletID,
+				Before:   m.Before,
+				After:    m.After,
+				Cash:     m.Cash,
+			})
+		}
+		return holdings, nil
+	}
+
+	var userStocks []*userStock.UserStock
+	err = tx.Where("stock_id = ?", split.StockID).Find(&userStocks).Error
+	if err != nil {
+		return nil, err
+	}
+	userStocks, holdings, err := splitQuantities(userStocks, split)
+	if err != nil {
+		return nil, err
+	}
+	for i, holding := range userStocks {
+		err := tx.Save(holding).Error
+		if err != nil {
+			return nil, err
+		}
+		if holdings[i].Cash > 0 {
+			var userWallet wallet.Wallet
+			err := tx.Where("user_id = ?", holdings[i].UserID).First(&userWallet).Error
+			if err != nil {
+				return nil, fmt.Errorf("no wallet to pay user %s for part of a share: %w", holdings[i].UserID, err)
+			}
+			userWallet.SetBalance(userWallet.GetBalance() + holdings[i].Cash)
+			err = tx.Save(&userWallet).Error
+			if err != nil {
+				return nil, err
+			}
+			holdings[i].WalletID = userWallet.GetId()
+		}
+		err = tx.Create(&databaseServiceUserManagement.SplitHolding{
+			CorporateActionID: split.CorporateActionID,
+			UserStockID:       holding.GetId(),
+			UserID:            holdings[i].UserID,
+			WalletID:          holdings[i].WalletID,
+			Before:            holdings[i].Before,
+			After:             holdings[i].After,
+			Cash:              holdings[i].Cash,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	println("Split holdings of stock: ", split.StockID, " Holders: ", len(holdings))
+	return holdings, nil
+}
+
+// A holding is split together with its shares in escrow, the shares left in escrow after the split are then taken back out of it.
+// Sets the new quantity of every holding, adding one for a seller with none left, and gives what each is paid for part of a share.
+func splitQuantities(userStocks []*userStock.UserStock, split *network.UserStockSplit) ([]*userStock.UserStock, []network.SplitHolding, error) {
+	stockName := ""
+	if len(userStocks) > 0 {
+		stockName = userStocks[0].GetStockName()
+	}
+	// the holding is normally kept at zero while everything is escrowed, but a seller whose holding has gone still gets one
+	for userID := range split.Escrowed {
+		found := false
+		for _, holding := range userStocks {
+			found = found || holding.GetUserID() == userID
+		}
+		if !found {
+			userStocks = append(userStocks, userStock.New(userStock.NewUserStockParams{
+				UserID:    userID,
+				StockID:   split.StockID,
+				StockName: stockName,
+			}))
+		}
+	}
+
+	holdings := make([]network.SplitHolding, 0, len(userStocks))
+	splitUsers := map[string]bool{}
+	for _, holding := range userStocks {
+		userID := holding.GetUserID()
+		before := holding.GetQuantity()
+		if !splitUsers[userID] {
+			// only counted with the user's first holding of the stock
+			before += split.Escrowed[userID]
+		}
+		whole, leftover := split.Split.Shares(before)
+		after := whole
+		if !splitUsers[userID] {
+			after -= split.EscrowedAfter[userID]
+		}
+		splitUsers[userID] = true
+		if after < 0 {
+			return nil, nil, fmt.Errorf("user %s has %d shares after the split, fewer than the %d left in escrow", userID, whole, split.EscrowedAfter[userID])
+		}
+		holding.SetQuantity(after)
+		holdings = append(holdings, network.SplitHolding{
+			UserID: userID,
+			Before: before,
+			After:  whole,
+			Cash:   split.Split.CashInLieu(leftover, split.CashPrice),
+		})
+	}
+	return userStocks, holdings, nil
+}
+
+// Expected input is a network.DividendPayout. Credits every holder of the stock with the amount for each share they hold, escrowed ones included,
+// in one database transaction so either everyone is paid or nobody is. Answers with a network.ReturnJSON holding the []network.DividendPayment.
+// A dividend that was already paid answers with what was paid then, without paying it again.
+func payDividendHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
+	var payout network.DividendPayout
+	err := json.Unmarshal(data, &payout)
+	if err != nil || payout.CorporateActionID == "" || payout.StockID == "" || payout.Amount <= 0 {
+		println("Error: expected a corporate action ID, a stock ID and an amount per share")
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var payments []network.DividendPayment
+	err = _databaseManager.UserStocks().GetDatabaseSession().Transaction(func(tx *gorm.DB) error {
+		var err error
+		payments, err = payDividend(tx, &payout)
+		return err
+	})
+	if err != nil {
+		println("Error paying dividend of stock: ", payout.StockID, " ", err.Error())
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	returnValJSON, err := json.Marshal(network.ReturnJSON{
+		Success: true,
+		Data:    payments,
+	})
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Write(returnValJSON)
+}
+
+func payDividend(tx *gorm.DB, payout *network.DividendPayout) ([]network.DividendPayment, error) {
+	var paid []databaseServiceUserManagement.PaidDividend
+	err := tx.Where("corporate_action_id = ?", payout.CorporateActionID).Find(&paid).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(paid) > 0 {
+		println("Dividend: ", payout.CorporateActionID, " was already paid")
+		payments := make([]network.DividendPayment, 0, len(paid))
+		for _, p := range paid {
+			payments = append(payments, network.DividendPayment{
+				UserID:   p.UserID,
+				WalletID: p.WalletID,
+				Shares:   p.Shares,
+				Cash:     p.Cash,
+			})
+		}
+		return payments, nil
+	}
+
+	var userStocks []*userStock.UserStock
+	err = tx.Where("stock_id = ?", payout.StockID).Find(&userStocks).Error
+	if err != nil {
+		return nil, err
+	}
+	payments := dividendPayments(userStocks, payout)
+	for i, payment := range payments {
+		var userWallet wallet.Wallet
+		err := tx.Where("user_id = ?", payment.UserID).First(&userWallet).Error
+		if err != nil {
+			return nil, fmt.Errorf("no wallet to pay user %s a dividend: %w", payment.UserID, err)
+		}
+		userWallet.SetBalance(userWallet.GetBalance() + payment.Cash)
+		err = tx.Save(&userWallet).Error
+		if err != nil {
+			return nil, err
+		}
+		payments[i].WalletID = userWallet.GetId()
+		err = tx.Create(&databaseServiceUserManagement.PaidDividend{
+			CorporateActionID: payout.CorporateActionID,
+			UserID:            payment.UserID,
+			WalletID:          payments[i].WalletID,
+			Shares:            payment.Shares,
+			Cash:              payment.Cash,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	println("Paid dividend of stock: ", payout.StockID, " Holders: ", len(payments))
+	return payments, nil
+}
+
+// What each holder is owed, for the shares they hold and the ones they have in escrow. Holders with no shares get nothing.
+func dividendPayments(userStocks []*userStock.UserStock, payout *network.DividendPayout) []network.DividendPayment {
+	shares := map[string]int{}
+	holders := []string{}
+	for _, holding := range userStocks {
+		if _, ok := shares[holding.GetUserID()]; !ok {
+			holders = append(holders, holding.GetUserID())
+		}
+		shares[holding.GetUserID()] += holding.GetQuantity()
+	}
+	// a seller with everything in escrow may have no holding left
+	for userID, escrowed := range payout.Escrowed {
+		if _, ok := shares[userID]; !ok {
+			holders = append(holders, userID)
+		}
+		shares[userID] += escrowed
+	}
+	payments := make([]network.DividendPayment, 0, len(holders))
+	for _, userID := range holders {
+		if shares[userID] <= 0 {
+			continue
+		}
+		payments = append(payments, network.DividendPayment{
+			UserID: userID,
+			Shares: shares[userID],
+			Cash:   payout.Amount.Mul(shares[userID]),
+		})
+	}
+	return payments
+}
@@ -0,0 +1,113 @@
+package userManagementDatabaseHandlers
+
+import (
+	"Shared/entities/money"
+	"Shared/entities/stock"
+	userStock "Shared/entities/user-stock"
+	"Shared/network"
+	"testing"
+)
+
+func holding(userID string, quantity int) *userStock.UserStock {
+	return userStock.New(userStock.NewUserStockParams{
+		UserID:    userID,
+		StockID:   "stock",
+		StockName: "Apple",
+		Quantity:  quantity,
+	})
+}
+
+func TestSplitQuantities(t *testing.T) {
+	// 3 for 2, parts of a share paid at 10.00 an old share. bob and carol have shares in sell orders,
+	// 3 of bob's become 4 and carol's 1 stays 1, rounded down like the orders are
+	split := &network.UserStockSplit{
+		StockID:       "stock",
+		Split:         stock.Split{NewShares: 3, OldShares: 2},
+		CashPrice:     money.FromCents(1000),
+		Escrowed:      map[string]int{"bob": 3, "carol": 1},
+		EscrowedAfter: map[string]int{"bob": 4, "carol": 1},
+	}
+	userStocks, holdings, err := splitQuantities([]*userStock.UserStock{holding("alice", 5), holding("bob", 2)}, split)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userStocks) != 3 || len(holdings) != 3 {
+		t.Fatalf("got %d holdings, wanted alice, bob and a new one for carol", len(userStocks))
+	}
+	want := map[string]struct {
+		before, after, kept int
+		cash                money.Money
+	}{
+		// 7.5 new shares. Half a new share is a third of an old one, 3.33
+		"alice": {before: 5, after: 7, kept: 7, cash: money.FromCents(333)},
+		// 5 with the escrowed ones, 4 of the 7 stay in escrow
+		"bob": {before: 5, after: 7, kept: 3, cash: money.FromCents(333)},
+		// everything is in escrow
+		"carol": {before: 1, after: 1, kept: 0, cash: money.FromCents(333)},
+	}
+	for i, result := range holdings {
+		w := want[result.UserID]
+		if result.Before != w.before || result.After != w.after || result.Cash != w.cash {
+			t.Errorf("%s: got %d to %d and %s, wanted %d to %d and %s", result.UserID, result.Before, result.After, result.Cash, w.before, w.after, w.cash)
+		}
+		if userStocks[i].GetUserID() != result.UserID || userStocks[i].GetQuantity() != w.kept {
+			t.Errorf("%s: holding has %d, wanted %d", result.UserID, userStocks[i].GetQuantity(), w.kept)
+		}
+	}
+	if userStocks[2].GetStockName() != "Apple" {
+		t.Errorf("carol's new holding should be named after the stock, got %q", userStocks[2].GetStockName())
+	}
+}
+
+func TestReverseSplitQuantities(t *testing.T) {
+	// 1 for 4 at 2.00 an old share. The 2 old shares left over are paid out whole, 4.00
+	split := &network.UserStockSplit{
+		StockID:   "stock",
+		Split:     stock.Split{NewShares: 1, OldShares: 4},
+		CashPrice: money.FromCents(200),
+	}
+	userStocks, holdings, err := splitQuantities([]*userStock.UserStock{holding("erin", 10)}, split)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userStocks[0].GetQuantity() != 2 || holdings[0].Cash != money.FromCents(400) {
+		t.Errorf("got %d shares and %s, wanted 2 and 4.00", userStocks[0].GetQuantity(), holdings[0].Cash)
+	}
+}
+
+func TestSplitQuantitiesEscrowShortfall(t *testing.T) {
+	split := &network.UserStockSplit{
+		StockID:       "stock",
+		Split:         stock.Split{NewShares: 2, OldShares: 1},
+		Escrowed:      map[string]int{"bob": 1},
+		EscrowedAfter: map[string]int{"bob": 5},
+	}
+	_, _, err := splitQuantities([]*userStock.UserStock{holding("bob", 0)}, split)
+	if err == nil {
+		t.Error("more shares left in escrow than bob has after the split should be refused")
+	}
+}
+
+func TestDividendPayments(t *testing.T) {
+	payout := &network.DividendPayout{
+		CorporateActionID: "dividend",
+		StockID:           "stock",
+		Amount:            money.FromCents(25),
+		Escrowed:          map[string]int{"bob": 3, "carol": 1},
+	}
+	userStocks := []*userStock.UserStock{holding("alice", 4), holding("bob", 2), holding("alice", 1), holding("zed", 0)}
+	want := map[string]money.Money{
+		"alice": money.FromCents(125), // both holdings
+		"bob":   money.FromCents(125), // escrowed shares included
+		"carol": money.FromCents(25),  // only escrowed shares
+	}
+	payments := dividendPayments(userStocks, payout)
+	if len(payments) != len(want) {
+		t.Fatalf("got %d payments, wanted %d. zed holds nothing", len(payments), len(want))
+	}
+	for _, payment := range payments {
+		if payment.Cash != want[payment.UserID] {
+			t.Errorf("%s: got %s, wanted %s", payment.UserID, payment.Cash, want[payment.UserID])
+		}
+	}
+}
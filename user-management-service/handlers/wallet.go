@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"Shared/entities/entity"
+	"Shared/entities/money"
 	"Shared/entities/wallet"
 	"Shared/network"
 	"databaseAccessUserManagement"
@@ -12,7 +13,7 @@ import (
 )
 
 type WalletBalance struct {
-	Balance float64 `json:"balance"`
+	Balance money.Money `json:"balance"`
 }
 
 var _walletAccess databaseAccessUserManagement.WalletDataAccessInterface
@@ -34,7 +35,7 @@ func InitializeWallet(walletAccess databaseAccessUserManagement.WalletDataAccess
 	params := wallet.NewWalletParams{
 		NewEntityParams: entity.NewEntityParams{},
 		UserID:          userID,
-		Balance:         100.0,
+		Balance:         money.FromCents(10000),
 	}
 	newWallet := wallet.New(params)
 
@@ -42,7 +43,7 @@ func InitializeWallet(walletAccess databaseAccessUserManagement.WalletDataAccess
 	if err != nil {
 		log.Fatalf("Failed to create wallet: %v", err)
 	}
-	fmt.Printf("Created wallet for user %s with balance: %.2f\n", createdWallet.GetUserID(), createdWallet.GetBalance())
+	fmt.Printf("Created wallet for user %s with balance: %s\n", createdWallet.GetUserID(), createdWallet.GetBalance())
 }*/
 
 func getWalletBalanceHandler(responseWriter network.ResponseWriter, data []byte, queryParams url.Values, requestType string) {
@@ -105,7 +106,7 @@ func addMoneyToWalletHandler(responseWriter network.ResponseWriter, data []byte,
 
 	fmt.Printf("DEBUG: Raw request data: %s\n", string(data))
 	var request struct {
-		Amount float64 `json:"amount"`
+		Amount money.Money `json:"amount"`
 	}
 
 	if err := json.Unmarshal(data, &request); err != nil {
@@ -114,7 +115,7 @@ func addMoneyToWalletHandler(responseWriter network.ResponseWriter, data []byte,
 		responseWriter.Write([]byte("Invalid request body"))
 		return
 	}
-	fmt.Printf("DEBUG: Parsed request amount: %f\n", request.Amount)
+	fmt.Printf("DEBUG: Parsed request amount: %s\n", request.Amount)
 
 	if request.Amount <= 0 {
 		fmt.Println("DEBUG: Request amount is invalid (<= 0), returning 400 Bad Request")
@@ -123,7 +124,7 @@ func addMoneyToWalletHandler(responseWriter network.ResponseWriter, data []byte,
 		return
 	}
 
-	fmt.Printf("DEBUG: Calling _walletAccess.AddMoneyToWallet for userID %s with amount %f\n", userID, request.Amount)
+	fmt.Printf("DEBUG: Calling _walletAccess.AddMoneyToWallet for userID %s with amount %s\n", userID, request.Amount)
 	if err := _walletAccess.AddMoneyToWallet(userID, request.Amount); err != nil {
 		fmt.Printf("DEBUG: Error adding money to wallet: %v\n", err)
 		responseWriter.WriteHeader(http.StatusInternalServerError)
@@ -161,7 +162,7 @@ func createWalletHandler(responseWriter network.ResponseWriter, data []byte, que
 	params := wallet.NewWalletParams{
 		NewEntityParams: entity.NewEntityParams{},
 		UserID:          userID,
-		Balance:         0.0,
+		Balance:         money.FromCents(0),
 	}
 	newWallet := wallet.New(params)
 	fmt.Printf("DEBUG: Created wallet object for userID: %s\n", userID)